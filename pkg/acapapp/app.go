@@ -5,13 +5,15 @@
 package acapapp
 
 import (
+	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"os"
 	"reflect"
 	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Cacsjep/goxis/pkg/axevent"
 	"github.com/Cacsjep/goxis/pkg/axlarod"
@@ -19,7 +21,6 @@ import (
 	"github.com/Cacsjep/goxis/pkg/axmanifest"
 	"github.com/Cacsjep/goxis/pkg/axparameter"
 	"github.com/Cacsjep/goxis/pkg/axsyslog"
-	"github.com/Cacsjep/goxis/pkg/axvdo"
 	"github.com/Cacsjep/goxis/pkg/glib"
 	"github.com/Cacsjep/goxis/pkg/utils"
 )
@@ -33,53 +34,41 @@ type AcapApplication struct {
 	Syslog              *axsyslog.Syslog
 	ParamHandler        *axparameter.AXParameter
 	EventHandler        *axevent.AXEventHandler
-	FrameProvider       *FrameProvider
+	FrameProvider       *FrameProvider // The default FrameProvider created by NewFrameProvider.
 	StorageProvider     *StorageProvider
 	Mainloop            *glib.GMainLoop
-	OnCloseCleaners     []func()
+	OnCloseCleaners     []func()      // Functions called on shutdown after all cleaners added with AddCleaner, prefer AddCleaner.
+	ShutdownTimeout     time.Duration // Overall timeout of a shutdown triggered by Run, DefaultShutdownTimeout if 0.
 	eventDeclarationIds []int
 	Larod               *axlarod.Larod
+	frameProviders      map[string]*FrameProvider
+	frameProviderNames  []string
+	frameProvidersMu    sync.Mutex
+	cleaners            []Cleaner
+	cleanersMu          sync.Mutex
+	shuttingDown        atomic.Bool   // Set by the first Shutdown, calls during the shutdown return immediately.
+	shutdownDone        chan struct{} // Closed when the first Shutdown has finished.
+	shutdownErr         error
+	signaled            atomic.Bool
+	sources             []*glib.Source
+	sourcesMu           sync.Mutex
 }
 
 // NewAcapApplication initializes a new AcapApplication instance, loading the application's manifest,
 // setting up the syslog, parameter handler, event handler, and main loop. It returns an initialized AcapApplication instance.
+// It parses the command line flags with flag.Parse, use NewAcapApplicationWithOptions for custom flags or to skip subsystems.
 //
 // ! Note: Since this is the entry point, it panic in case of an error,
 // this could happen if manifest could not loaded or parameter instance could not be created
 func NewAcapApplication() *AcapApplication {
-	m, err := axmanifest.LoadManifest("manifest.json")
-	if err != nil {
-		panic(err)
-	}
-
-	pApp, err := axparameter.AXParameterNew(m.ACAPPackageConf.Setup.AppName)
+	app, err := NewAcapApplicationWithOptions()
 	if err != nil {
+		if isHelpRequested(err) {
+			os.Exit(1)
+		}
 		panic(err)
 	}
-
-	app := AcapApplication{
-		Manifest:        m,
-		Syslog:          axsyslog.NewSyslog(m.ACAPPackageConf.Setup.AppName, axsyslog.LOG_PID|axsyslog.LOG_CONS, axsyslog.LOG_USER),
-		ParamHandler:    pApp,
-		EventHandler:    axevent.NewEventHandler(),
-		Mainloop:        glib.NewMainLoop(),
-		OnCloseCleaners: []func(){},
-	}
-
-	showHelp := flag.Bool("h", false, "Displays this help message.")
-	consoleLog := flag.Bool("consoleLog", false, "Enable console logging")
-	flag.Parse()
-
-	if *showHelp {
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	if *consoleLog {
-		app.Syslog.EnableConsole()
-	}
-
-	return &app
+	return app
 }
 
 func (a *AcapApplication) InitalizeLarod() error {
@@ -105,70 +94,48 @@ func (a *AcapApplication) IsLicenseValid(major_version int, minor_version int) (
 	), nil
 }
 
-// Start initiates the main event loop of the application, beginning its execution.
-func (a *AcapApplication) Run() {
-	SignalHandler(a.Close)
-	a.Mainloop.Run()
-}
-
+// RunInBackground runs the main loop in a goroutine, see Run.
+// On SIGTERM, SIGINT or SIGABRT the application is shut down and the process exits with the ExitCode of the shutdown.
 func (a *AcapApplication) RunInBackground() {
-	go a.Run()
+	go func() {
+		a.exitAfterSignal(a.Run(context.Background()))
+	}()
 }
 
-// Add close or clean functions to app so in case of signals these are correct handled
-func (a *AcapApplication) AddCloseCleanFunc(f func()) {
-	a.OnCloseCleaners = append(a.OnCloseCleaners, f)
+// Add close or clean functions to app so in case of signals these are correct handled.
+// The function runs as a Cleaner without ordering constraints, see AddCleaner for the returned error.
+func (a *AcapApplication) AddCloseCleanFunc(f func()) error {
+	return a.AddCleaner(Cleaner{Clean: func(ctx context.Context) error {
+		f()
+		return nil
+	}})
 }
 
-func (a *AcapApplication) AddModelCleaner(m *axlarod.LarodModel) {
-	a.AddCloseCleanFunc(func() {
-		err := a.Larod.DestroyModel(m)
-		if err != nil {
-			a.Syslog.Errorf("Failed to destroy model: %s, %s", m.Name, err.Error())
+// AddModelCleaner destroys the model on shutdown before larod is disconnected.
+func (a *AcapApplication) AddModelCleaner(m *axlarod.LarodModel) error {
+	return a.AddCleaner(Cleaner{Before: []string{CleanerLarod}, Clean: func(ctx context.Context) error {
+		if err := a.Larod.DestroyModel(m); err != nil {
+			return fmt.Errorf("Failed to destroy model: %s, %w", m.Name, err)
 		}
-	})
+		return nil
+	}})
 }
 
 // Close terminates the application's main event loop and releases resources associated with the syslog, parameter handler,
-// event handler, and main loop. This should be called to cleanly shut down the application.
+// event handler and main loop. This should be called to cleanly shut down the application.
+// Errors of the cleaners are logged, use Shutdown to receive them.
 func (a *AcapApplication) Close() {
-	for _, declaration_id := range a.eventDeclarationIds {
-		a.EventHandler.Undeclare(declaration_id)
-	}
-	if a.FrameProvider != nil {
-		a.FrameProvider.Stop()
-	}
-	if a.StorageProvider != nil {
-		a.StorageProvider.Close()
-	}
-	for _, f := range a.OnCloseCleaners {
-		f()
-	}
-	if a.Larod != nil {
-		a.Larod.Disconnect()
-	}
-	a.Mainloop.Quit()     // Terminate the main loop.
-	a.ParamHandler.Free() // Release the parameter handler.
-	a.EventHandler.Free() // Release the event handler.
-	a.Syslog.Info(fmt.Sprintf("%s has shut down gracefully.", a.Manifest.ACAPPackageConf.Setup.AppName))
-	a.Syslog.Close() // Close the syslog.
+	a.Shutdown(context.Background())
 }
 
 // GetSnapshot captures a JPEG snapshot from the specified video channel and returns it as a byte slice.
-// It sets up the required settings for capturing the snapshot, captures it, and then returns the snapshot data or an error if the capture fails.
+// Use GetSnapshotWithOptions to set the resolution, compression, rotation, crop area or format of the snapshot.
 func (a *AcapApplication) GetSnapshot(video_channel int) ([]byte, error) {
-	settings := axvdo.NewVdoMap()                             // Create a new settings map for the snapshot.
-	settings.SetUint32("channel", uint32(video_channel))      // Set the video channel.
-	settings.SetUint32("format", uint32(axvdo.VdoFormatJPEG)) // Set the snapshot format to JPEG.
-	defer settings.Unref()                                    // Ensure settings are unreferenced after use.
-
-	snapshotBuffer, err := axvdo.Snapshot(settings) // Capture the snapshot.
+	snap, err := a.snapshot(SnapshotOptions{Channel: video_channel}, false)
 	if err != nil {
 		return nil, err
 	}
-	defer snapshotBuffer.Unref() // Ensure the snapshot buffer is unreferenced after use.
-
-	return snapshotBuffer.GetBytes() // Return the snapshot data.
+	return snap.Data, nil
 }
 
 // AcapWebBaseUri returns the base path for an webserver that is used with reverse proxy
@@ -185,6 +152,9 @@ func (a *AcapApplication) AcapWebBaseUri() (string, error) {
 // AddCameraPlatformEvent adds the event to the application.
 // AcapApplication undaclare the added events on closing or exit signals.
 func (a *AcapApplication) AddCameraPlatformEvent(cpe *CameraPlatformEvent) (int, error) {
+	if a.EventHandler == nil {
+		return 0, ErrNoEventHandler
+	}
 	event, err := NewCameraApplicationPlatformEvent(
 		a.Manifest.ACAPPackageConf.Setup,
 		cpe.Name,
@@ -205,6 +175,9 @@ func (a *AcapApplication) AddCameraPlatformEvent(cpe *CameraPlatformEvent) (int,
 
 // SendPlatformEvent sends a platform event with the specified event ID and event creation function.
 func (a *AcapApplication) SendPlatformEvent(eventID int, createEventFunc func() (*axevent.AXEvent, error)) error {
+	if a.EventHandler == nil {
+		return ErrNoEventHandler
+	}
 	event, err := createEventFunc()
 	if err != nil {
 		return err
@@ -277,6 +250,7 @@ type KeyValueMap map[string]interface{}
 
 // NewEvent creates a new AXEvent based on predefined keys and dynamic values.
 // It returns the new AXEvent or an error if the values provided do not match the expected types.
+// Integer keys accept all integer types and double keys all float types.
 // The valuesMap parameter should contain the values for each key in the KeyValueSet.
 // The keys in the valuesMap should match the keys in the KeyValueSet of the CameraPlatformEvent.
 func (cpe *CameraPlatformEvent) NewEvent(valuesMap KeyValueMap) (*axevent.AXEvent, error) {
@@ -284,23 +258,26 @@ func (cpe *CameraPlatformEvent) NewEvent(valuesMap KeyValueMap) (*axevent.AXEven
 
 	for _, entry := range cpe.Entries {
 		value, exists := valuesMap[entry.Key]
-		if !exists {
+		if !exists || value == nil {
 			return nil, fmt.Errorf("no value provided for key: %s", entry.Key)
 		}
 
 		switch entry.ValueType {
 		case axevent.AXValueTypeInt:
-			if intValue, ok := value.(int); ok {
-				kvsEntries = append(kvsEntries, axevent.KeyValueEntrie{Key: entry.Key, Value: intValue, ValueType: entry.ValueType, Namespace: entry.Namespace})
-			} else {
-				return nil, fmt.Errorf("type mismatch for key %s: expected int", entry.Key)
+			if vt, err := eventValueType(reflect.TypeOf(value)); err != nil || vt != axevent.AXValueTypeInt {
+				return nil, fmt.Errorf("type mismatch for key %s: expected integer", entry.Key)
 			}
+			intValue, err := eventValue(reflect.ValueOf(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for key %s: %w", entry.Key, err)
+			}
+			kvsEntries = append(kvsEntries, axevent.KeyValueEntrie{Key: entry.Key, Value: intValue, ValueType: entry.ValueType, Namespace: entry.Namespace})
 		case axevent.AXValueTypeDouble:
-			if floatValue, ok := value.(float64); ok {
-				kvsEntries = append(kvsEntries, axevent.KeyValueEntrie{Key: entry.Key, Value: floatValue, ValueType: entry.ValueType, Namespace: entry.Namespace})
-			} else {
-				return nil, fmt.Errorf("type mismatch for key %s: expected float64", entry.Key)
+			if vt, err := eventValueType(reflect.TypeOf(value)); err != nil || vt != axevent.AXValueTypeDouble {
+				return nil, fmt.Errorf("type mismatch for key %s: expected float", entry.Key)
 			}
+			floatValue := reflect.ValueOf(value).Float()
+			kvsEntries = append(kvsEntries, axevent.KeyValueEntrie{Key: entry.Key, Value: floatValue, ValueType: entry.ValueType, Namespace: entry.Namespace})
 		case axevent.AXValueTypeString:
 			if stringValue, ok := value.(string); ok {
 				kvsEntries = append(kvsEntries, axevent.KeyValueEntrie{Key: entry.Key, Value: stringValue, ValueType: entry.ValueType, Namespace: entry.Namespace})
@@ -396,61 +373,8 @@ func NewCameraApplicationPlatformEvent(app_setup axmanifest.Setup, event_name st
 
 // OnEvent creates a subscription callback for the given event key value set.
 func (a *AcapApplication) OnEvent(kvs *axevent.AXEventKeyValueSet, callback func(*axevent.Event)) (subscription int, err error) {
-	return a.EventHandler.OnEvent(kvs, callback)
-}
-
-// UnmarshalEvent unmarshals the given event into the provided struct.
-func UnmarshalEvent(e *axevent.Event, v interface{}) error {
-	val := reflect.ValueOf(v)
-	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
-		return fmt.Errorf("value must be a pointer to a struct")
-	}
-
-	for i := 0; i < val.Elem().NumField(); i++ {
-		field := val.Elem().Field(i)
-		if !field.CanSet() {
-			continue
-		}
-		fieldType := val.Elem().Type().Field(i)
-
-		key := fieldType.Tag.Get("eventKey")
-		if key == "" {
-			key = strings.ToLower(fieldType.Name)
-		}
-
-		valueType, err := e.Kvs.GetValueType(key, nil)
-
-		if err != nil {
-			return fmt.Errorf("error getting value type for key %s: %v", key, err)
-		}
-
-		switch field.Kind() {
-		case reflect.Int:
-			if intValue, err := e.Kvs.GetInteger(key, nil); err == nil {
-				field.SetInt(int64(intValue))
-			} else {
-				return fmt.Errorf("error getting integer for key %s: %v, Value Type: %d", key, err, valueType)
-			}
-		case reflect.Float64:
-			if fValue, err := e.Kvs.GetDouble(key, nil); err == nil {
-				field.SetFloat(fValue)
-			} else {
-				return fmt.Errorf("error getting double for key %s: %v, Value Type: %d", key, err, valueType)
-			}
-		case reflect.String:
-			if sValue, err := e.Kvs.GetString(key, nil); err == nil {
-				field.SetString(sValue)
-			} else {
-				return fmt.Errorf("error getting string for key %s: %v, Value Type: %d", key, err, valueType)
-			}
-		case reflect.Bool:
-			if boolValue, err := e.Kvs.GetBoolean(key, nil); err == nil {
-				field.SetBool(boolValue)
-			} else {
-				return fmt.Errorf("error getting boolean for key %s: %v, Value Type: %d", key, err, valueType)
-			}
-		}
+	if a.EventHandler == nil {
+		return 0, ErrNoEventHandler
 	}
-
-	return nil
+	return a.EventHandler.OnEvent(kvs, callback)
 }
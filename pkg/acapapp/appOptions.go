@@ -0,0 +1,255 @@
+package acapapp
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Cacsjep/goxis/pkg/axevent"
+	"github.com/Cacsjep/goxis/pkg/axlarod"
+	"github.com/Cacsjep/goxis/pkg/axmanifest"
+	"github.com/Cacsjep/goxis/pkg/axparameter"
+	"github.com/Cacsjep/goxis/pkg/axsyslog"
+	"github.com/Cacsjep/goxis/pkg/glib"
+)
+
+// DefaultManifestPath is the manifest loaded by NewAcapApplication, relative to the application directory.
+const DefaultManifestPath = "manifest.json"
+
+// ErrNoEventHandler is returned by event related methods of an AcapApplication created WithoutEvents.
+var ErrNoEventHandler = errors.New("Event handler is not initialized")
+
+// AppOption configures an AcapApplication created by NewAcapApplicationWithOptions.
+type AppOption func(*appOptions)
+
+// subsystemMode decides if a subsystem is created, skipped or replaced by a given instance.
+type subsystemMode int
+
+const (
+	subsystemDefault subsystemMode = iota
+	subsystemSkip
+	subsystemCustom
+)
+
+type appOptions struct {
+	manifestPath   string
+	flagSet        *flag.FlagSet
+	flagArgs       []string
+	noFlags        bool
+	consoleLog     bool
+	syslogOption   int
+	syslogFacility int
+	paramMode      subsystemMode
+	paramHandler   *axparameter.AXParameter
+	eventMode      subsystemMode
+	eventHandler   *axevent.AXEventHandler
+	larodMode      subsystemMode
+	larod          *axlarod.Larod
+}
+
+// WithManifestPath loads the manifest from the given path instead of DefaultManifestPath.
+func WithManifestPath(path string) AppOption {
+	return func(o *appOptions) {
+		o.manifestPath = path
+	}
+}
+
+// WithFlagSet registers the application flags -h and -consoleLog on the given FlagSet and parses it with args.
+// If the FlagSet was already parsed by the caller, the flags must be registered beforehand and only their values are read.
+func WithFlagSet(fs *flag.FlagSet, args []string) AppOption {
+	return func(o *appOptions) {
+		o.flagSet = fs
+		o.flagArgs = args
+		o.noFlags = false
+	}
+}
+
+// WithoutFlags disables the flag handling, the command line is left to the application.
+func WithoutFlags() AppOption {
+	return func(o *appOptions) {
+		o.noFlags = true
+	}
+}
+
+// WithConsoleLog enables the console logging of the syslog, like the -consoleLog flag.
+func WithConsoleLog() AppOption {
+	return func(o *appOptions) {
+		o.consoleLog = true
+	}
+}
+
+// WithSyslog sets the openlog options (e.g. axsyslog.LOG_PID) and facility (e.g. axsyslog.LOG_LOCAL0) of the syslog.
+func WithSyslog(option int, facility int) AppOption {
+	return func(o *appOptions) {
+		o.syslogOption = option
+		o.syslogFacility = facility
+	}
+}
+
+// WithoutParameters skips the creation of the parameter handler, ParamHandler stays nil.
+func WithoutParameters() AppOption {
+	return func(o *appOptions) {
+		o.paramMode = subsystemSkip
+	}
+}
+
+// WithParameterHandler uses the given parameter handler instead of creating one.
+func WithParameterHandler(handler *axparameter.AXParameter) AppOption {
+	return func(o *appOptions) {
+		o.paramMode = subsystemCustom
+		o.paramHandler = handler
+	}
+}
+
+// WithoutEvents skips the creation of the event handler, EventHandler stays nil.
+func WithoutEvents() AppOption {
+	return func(o *appOptions) {
+		o.eventMode = subsystemSkip
+	}
+}
+
+// WithEventHandler uses the given event handler instead of creating one.
+func WithEventHandler(handler *axevent.AXEventHandler) AppOption {
+	return func(o *appOptions) {
+		o.eventMode = subsystemCustom
+		o.eventHandler = handler
+	}
+}
+
+// WithLarod connects to larod while creating the application, like InitalizeLarod.
+func WithLarod() AppOption {
+	return func(o *appOptions) {
+		o.larodMode = subsystemDefault
+		o.larod = nil
+	}
+}
+
+// WithLarodInstance uses the given, already initialized larod connection.
+func WithLarodInstance(larod *axlarod.Larod) AppOption {
+	return func(o *appOptions) {
+		o.larodMode = subsystemCustom
+		o.larod = larod
+	}
+}
+
+// NewAcapApplicationWithOptions creates an AcapApplication like NewAcapApplication, but returns an error instead of panicking
+// and lets the caller decide about the manifest, flag handling, syslog and the subsystems to initialize.
+// If -h is given on the command line, the usage is printed and flag.ErrHelp is returned.
+//
+// Without options it behaves like NewAcapApplication, except that larod is only initialized with WithLarod or WithLarodInstance.
+func NewAcapApplicationWithOptions(opts ...AppOption) (*AcapApplication, error) {
+	o := &appOptions{
+		manifestPath:   DefaultManifestPath,
+		syslogOption:   axsyslog.LOG_PID | axsyslog.LOG_CONS,
+		syslogFacility: axsyslog.LOG_USER,
+		larodMode:      subsystemSkip,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	consoleLog := o.consoleLog
+	if !o.noFlags {
+		enabled, err := parseAppFlags(o.flagSet, o.flagArgs)
+		if err != nil {
+			return nil, err
+		}
+		consoleLog = consoleLog || enabled
+	}
+
+	m, err := axmanifest.LoadManifest(o.manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load manifest %s: %w", o.manifestPath, err)
+	}
+	appName := m.ACAPPackageConf.Setup.AppName
+
+	app := &AcapApplication{
+		Manifest:        m,
+		Syslog:          axsyslog.NewSyslog(appName, o.syslogOption, o.syslogFacility),
+		OnCloseCleaners: []func(){},
+		frameProviders:  make(map[string]*FrameProvider),
+		shutdownDone:    make(chan struct{}),
+	}
+	if consoleLog {
+		app.Syslog.EnableConsole()
+	}
+
+	switch o.paramMode {
+	case subsystemDefault:
+		if app.ParamHandler, err = axparameter.AXParameterNew(appName); err != nil {
+			app.Syslog.Close()
+			return nil, fmt.Errorf("Unable to create parameter handler: %w", err)
+		}
+	case subsystemCustom:
+		app.ParamHandler = o.paramHandler
+	}
+
+	switch o.eventMode {
+	case subsystemDefault:
+		app.EventHandler = axevent.NewEventHandler()
+	case subsystemCustom:
+		app.EventHandler = o.eventHandler
+	}
+
+	switch o.larodMode {
+	case subsystemDefault:
+		if err := app.InitalizeLarod(); err != nil {
+			if app.EventHandler != nil && o.eventMode == subsystemDefault {
+				app.EventHandler.Free()
+			}
+			if app.ParamHandler != nil && o.paramMode == subsystemDefault {
+				app.ParamHandler.Free()
+			}
+			app.Syslog.Close()
+			return nil, fmt.Errorf("Unable to initialize larod: %w", err)
+		}
+	case subsystemCustom:
+		app.Larod = o.larod
+	}
+
+	// Created last, so none of the error returns above leaks it.
+	app.Mainloop = glib.NewMainLoop()
+	return app, nil
+}
+
+// parseAppFlags registers and parses the application flags, it returns whether console logging is requested.
+func parseAppFlags(fs *flag.FlagSet, args []string) (bool, error) {
+	if fs == nil {
+		fs = flag.CommandLine
+		args = os.Args[1:]
+	}
+
+	if fs.Lookup("h") == nil {
+		fs.Bool("h", false, "Displays this help message.")
+	}
+	if fs.Lookup("consoleLog") == nil {
+		fs.Bool("consoleLog", false, "Enable console logging")
+	}
+	if !fs.Parsed() {
+		if err := fs.Parse(args); err != nil {
+			return false, err
+		}
+	}
+
+	if boolFlag(fs, "h") {
+		fs.Usage()
+		return false, flag.ErrHelp
+	}
+	return boolFlag(fs, "consoleLog"), nil
+}
+
+func boolFlag(fs *flag.FlagSet, name string) bool {
+	f := fs.Lookup(name)
+	if f == nil {
+		return false
+	}
+	enabled, _ := strconv.ParseBool(f.Value.String())
+	return enabled
+}
+
+// isHelpRequested reports whether the error of NewAcapApplicationWithOptions was caused by the -h flag.
+func isHelpRequested(err error) bool {
+	return errors.Is(err, flag.ErrHelp)
+}
@@ -0,0 +1,182 @@
+package acapapp
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/Cacsjep/goxis/pkg/axevent"
+)
+
+// newKeyValueSet creates an AXEventKeyValueSet from the entries and frees it again if an entry can not be added.
+func newKeyValueSet(entries []axevent.KeyValueEntrie) (*axevent.AXEventKeyValueSet, error) {
+	kvs := axevent.NewAXEventKeyValueSet()
+	for _, entry := range entries {
+		if err := kvs.AddKeyValue(entry.Key, entry.Namespace, entry.Value, entry.ValueType); err != nil {
+			kvs.Free()
+			return nil, fmt.Errorf("error adding key %s: %w", entry.Key, err)
+		}
+	}
+	return kvs, nil
+}
+
+// MarshalEvent builds an AXEventKeyValueSet from the struct v, for example to subscribe to an event with OnEvent.
+// Keys are described with the same eventKey tags as in UnmarshalEvent. Value fields always match their value,
+// nil pointer fields are added without a value, which matches any value. If v implements axevent.EventTopicProvider,
+// like the event structs of axevent, the topics of the event are added first.
+//
+// The event structs of axevent have value fields only, use a filter struct with pointer fields to leave keys open.
+//
+// Example:
+//
+//	type virtualInputFilter struct {
+//		Port   *int  `eventKey:"port"`
+//		Active *bool `eventKey:"active"`
+//	}
+//
+//	func (virtualInputFilter) EventTopics() []axevent.KeyValueEntrie {
+//		return axevent.DeviceIoVirtualInputEvent{}.EventTopics()
+//	}
+//
+//	port := 3
+//	kvs, err := acapapp.MarshalEvent(virtualInputFilter{Port: &port})
+func MarshalEvent(v interface{}) (*axevent.AXEventKeyValueSet, error) {
+	entries, err := marshalEventEntries(v)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyValueSet(entries)
+}
+
+// marshalEventEntries returns the key value entries of MarshalEvent, topics first.
+func marshalEventEntries(v interface{}) ([]axevent.KeyValueEntrie, error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil, fmt.Errorf("value must not be nil")
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("value must be a struct or a pointer to a struct")
+	}
+
+	fields, err := parseEventFields(val.Type())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := eventEntries(fields, val, true)
+	if err != nil {
+		return nil, err
+	}
+	if tp, ok := v.(axevent.EventTopicProvider); ok {
+		entries = append(tp.EventTopics(), entries...)
+	}
+	return entries, nil
+}
+
+// UnmarshalEvent unmarshals the given event into the provided struct.
+//
+// Keys are taken from the eventKey tag, see MarshalEvent, a namespace is given as prefix like eventKey:"tnsaxis:port".
+// Fields may be of any bool, string, int, uint or float type or a pointer to one of them,
+// a time.Time field receives the timestamp of the event. A missing key is an error unless the field
+// is a pointer, which stays nil, or has the omitempty option, which keeps its value.
+func UnmarshalEvent(e *axevent.Event, v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("value must be a pointer to a struct")
+	}
+
+	fields, err := parseEventFields(val.Elem().Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		field := val.Elem().Field(f.index)
+		if f.timestamp {
+			if f.pointer {
+				ts := e.Timestamp
+				field.Set(reflect.ValueOf(&ts))
+			} else {
+				field.Set(reflect.ValueOf(e.Timestamp))
+			}
+			continue
+		}
+
+		valueType, err := e.Kvs.GetValueType(f.key, f.namespace)
+		if err != nil {
+			if f.pointer || f.omitempty {
+				continue
+			}
+			return fmt.Errorf("error getting value type for key %s: %v", f.key, err)
+		}
+
+		target := field
+		if f.pointer {
+			target = reflect.New(field.Type().Elem()).Elem()
+		}
+		if err := unmarshalEventValue(e.Kvs, f, valueType, target); err != nil {
+			return err
+		}
+		if f.pointer {
+			field.Set(target.Addr())
+		}
+	}
+
+	return nil
+}
+
+// unmarshalEventValue reads the key of the field from kvs into target, integers and floats are range checked for the target width.
+func unmarshalEventValue(kvs *axevent.AXEventKeyValueSet, f eventField, valueType axevent.AXEventValueType, target reflect.Value) error {
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intValue, err := kvs.GetInteger(f.key, f.namespace)
+		if err != nil {
+			return fmt.Errorf("error getting integer for key %s: %v, Value Type: %d", f.key, err, valueType)
+		}
+		if target.OverflowInt(int64(intValue)) {
+			return fmt.Errorf("value %d of key %s overflows %s", intValue, f.key, target.Type())
+		}
+		target.SetInt(int64(intValue))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		intValue, err := kvs.GetInteger(f.key, f.namespace)
+		if err != nil {
+			return fmt.Errorf("error getting integer for key %s: %v, Value Type: %d", f.key, err, valueType)
+		}
+		if intValue < 0 || target.OverflowUint(uint64(intValue)) {
+			return fmt.Errorf("value %d of key %s overflows %s", intValue, f.key, target.Type())
+		}
+		target.SetUint(uint64(intValue))
+	case reflect.Float32, reflect.Float64:
+		var fValue float64
+		if valueType == axevent.AXValueTypeInt {
+			intValue, err := kvs.GetInteger(f.key, f.namespace)
+			if err != nil {
+				return fmt.Errorf("error getting integer for key %s: %v, Value Type: %d", f.key, err, valueType)
+			}
+			fValue = float64(intValue)
+		} else {
+			var err error
+			if fValue, err = kvs.GetDouble(f.key, f.namespace); err != nil {
+				return fmt.Errorf("error getting double for key %s: %v, Value Type: %d", f.key, err, valueType)
+			}
+		}
+		if target.OverflowFloat(fValue) {
+			return fmt.Errorf("value %g of key %s overflows %s", fValue, f.key, target.Type())
+		}
+		target.SetFloat(fValue)
+	case reflect.String:
+		sValue, err := kvs.GetString(f.key, f.namespace)
+		if err != nil {
+			return fmt.Errorf("error getting string for key %s: %v, Value Type: %d", f.key, err, valueType)
+		}
+		target.SetString(sValue)
+	case reflect.Bool:
+		boolValue, err := kvs.GetBoolean(f.key, f.namespace)
+		if err != nil {
+			return fmt.Errorf("error getting boolean for key %s: %v, Value Type: %d", f.key, err, valueType)
+		}
+		target.SetBool(boolValue)
+	}
+	return nil
+}
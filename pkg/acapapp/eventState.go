@@ -0,0 +1,268 @@
+package acapapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axevent"
+	"github.com/Cacsjep/goxis/pkg/glib"
+)
+
+// ErrEventStateClosed is returned when the state of an EventState is changed after the application started to shut down.
+var ErrEventStateClosed = errors.New("Event state is closed")
+
+// EventState is a handle to a stateful Camera Application Platform event whose boolean data key represents an active state.
+// It only sends an event when the state changes. A debounce delays changes until the state was stable for the debounce duration,
+// and a minimum active duration keeps the state active for at least that long, so flickering detections do not spam action rules.
+// Delayed sends run on the main loop and are cancelled when the application shuts down, before the event handler is freed.
+type EventState struct {
+	ID        int // The declaration id of the event.
+	app       *AcapApplication
+	cpe       *CameraPlatformEvent
+	entry     *EventEntry
+	stateKey  string
+	debounce  time.Duration
+	minActive time.Duration
+	mu        sync.Mutex
+	desired   bool         // Last requested state.
+	sent      bool         // Last sent state.
+	changedAt time.Time    // Time the desired state started to differ from the sent state.
+	activeAt  time.Time    // Time the active state was sent.
+	timer     *glib.Source // Pending evaluation of the debounce and minimum active duration.
+	pulse     *glib.Source // Pending end of a pulse.
+	closed    bool
+}
+
+// EventStateOption configures an EventState created by AddCameraPlatformStateEvent.
+type EventStateOption func(*EventState)
+
+// WithStateKey selects the boolean key that holds the state, default is the single key marked as data.
+func WithStateKey(key string) EventStateOption {
+	return func(es *EventState) {
+		es.stateKey = key
+	}
+}
+
+// WithDebounce only sends a state change after the state was stable for the given duration.
+func WithDebounce(d time.Duration) EventStateOption {
+	return func(es *EventState) {
+		es.debounce = d
+	}
+}
+
+// WithMinActive keeps the state active for at least the given duration after it was sent as active.
+func WithMinActive(d time.Duration) EventStateOption {
+	return func(es *EventState) {
+		es.minActive = d
+	}
+}
+
+// AddCameraPlatformStateEvent declares a stateful event like AddCameraPlatformEvent and returns a handle to its active state.
+// The state key must be of type AXValueTypeBool, its declared value is the initial state. All other keys are sent
+// with their declared values, so they must not be declared without a value.
+func (a *AcapApplication) AddCameraPlatformStateEvent(cpe *CameraPlatformEvent, opts ...EventStateOption) (*EventState, error) {
+	if cpe.Stateless {
+		return nil, errors.New("State events must be declared stateful")
+	}
+
+	es := &EventState{app: a, cpe: cpe}
+	for _, opt := range opts {
+		opt(es)
+	}
+	for _, entry := range cpe.Entries {
+		if es.stateKey != "" {
+			if entry.Key == es.stateKey {
+				es.entry = entry
+			}
+		} else if entry.IsData != nil && *entry.IsData {
+			if es.entry != nil {
+				return nil, errors.New("Event has more than one data key, select the state key with WithStateKey")
+			}
+			es.entry = entry
+		}
+	}
+	if es.entry == nil {
+		return nil, errors.New("Event has no state key, mark a key as data or select it with WithStateKey")
+	}
+	if es.entry.ValueType != axevent.AXValueTypeBool {
+		return nil, fmt.Errorf("State key %s must be of type bool", es.entry.Key)
+	}
+	for _, entry := range cpe.Entries {
+		if entry != es.entry && entry.Value == nil {
+			return nil, fmt.Errorf("Key %s needs a declared value, state events send all keys with their declared values", entry.Key)
+		}
+	}
+	if initial, ok := es.entry.Value.(bool); ok {
+		es.desired, es.sent = initial, initial
+	}
+
+	id, err := a.AddCameraPlatformEvent(cpe)
+	if err != nil {
+		return nil, err
+	}
+	es.ID = id
+	if es.sent {
+		es.activeAt = time.Now()
+	}
+	if err := a.AddCleaner(Cleaner{
+		Name:   fmt.Sprintf("eventstate-%d", id),
+		Before: []string{CleanerEvents},
+		Clean:  es.close,
+	}); err != nil {
+		a.Syslog.Warnf("Event %s: %s", cpe.Name, err.Error())
+	}
+	return es, nil
+}
+
+// Active returns the state that was last sent.
+func (es *EventState) Active() bool {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return es.sent
+}
+
+// Set requests the given state. The event is sent immediately if no debounce or minimum active duration applies,
+// otherwise it is sent once they elapsed and the state was not changed back meanwhile.
+// Errors of delayed sends are logged to the syslog.
+func (es *EventState) Set(active bool) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.closed {
+		return ErrEventStateClosed
+	}
+	es.stopPulse()
+	return es.set(active)
+}
+
+// Toggle requests the opposite of the last requested state.
+func (es *EventState) Toggle() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.closed {
+		return ErrEventStateClosed
+	}
+	es.stopPulse()
+	return es.set(!es.desired)
+}
+
+// Pulse requests the active state and the inactive state after d. A pulse during a running pulse extends it.
+func (es *EventState) Pulse(d time.Duration) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.closed {
+		return ErrEventStateClosed
+	}
+	es.stopPulse()
+	if err := es.set(true); err != nil {
+		return err
+	}
+	var pulse *glib.Source
+	pulse = es.app.After(d, func() {
+		es.mu.Lock()
+		defer es.mu.Unlock()
+		if es.closed || es.pulse != pulse {
+			return
+		}
+		es.pulse = nil
+		if err := es.set(false); err != nil {
+			es.app.Syslog.Errorf("Event %s: unable to end pulse: %s", es.cpe.Name, err.Error())
+		}
+	})
+	es.pulse = pulse
+	return nil
+}
+
+func (es *EventState) stopPulse() {
+	if es.pulse != nil {
+		es.pulse.Remove()
+		es.pulse = nil
+	}
+}
+
+func (es *EventState) stopTimer() {
+	if es.timer != nil {
+		es.timer.Remove()
+		es.timer = nil
+	}
+}
+
+// close cancels the pending sends, a send that is running is waited for, so the event handler can be freed afterwards.
+func (es *EventState) close(ctx context.Context) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.closed = true
+	es.stopPulse()
+	es.stopTimer()
+	return nil
+}
+
+// set must be called with mu held.
+func (es *EventState) set(active bool) error {
+	if active != es.desired {
+		es.desired = active
+		es.changedAt = time.Now()
+	}
+	return es.evaluate()
+}
+
+// evaluate sends the desired state if all delays elapsed or schedules a new evaluation, it must be called with mu held.
+func (es *EventState) evaluate() error {
+	es.stopTimer()
+	if es.desired == es.sent {
+		return nil
+	}
+
+	now := time.Now()
+	sendAt := es.changedAt.Add(es.debounce)
+	if !es.desired {
+		if minEnd := es.activeAt.Add(es.minActive); minEnd.After(sendAt) {
+			sendAt = minEnd
+		}
+	}
+	if wait := sendAt.Sub(now); wait > 0 {
+		var timer *glib.Source
+		timer = es.app.After(wait, func() {
+			es.mu.Lock()
+			defer es.mu.Unlock()
+			if es.closed || es.timer != timer {
+				return
+			}
+			es.timer = nil
+			if err := es.evaluate(); err != nil {
+				es.app.Syslog.Errorf("Event %s: unable to send state: %s", es.cpe.Name, err.Error())
+			}
+		})
+		es.timer = timer
+		return nil
+	}
+	return es.send(es.desired)
+}
+
+// send sends the event with the given state, it must be called with mu held.
+func (es *EventState) send(active bool) error {
+	values := KeyValueMap{}
+	for _, entry := range es.cpe.Entries {
+		values[entry.Key] = entry.Value
+	}
+	values[es.entry.Key] = active
+
+	if es.app.EventHandler == nil {
+		return ErrNoEventHandler
+	}
+	event, err := es.cpe.NewEvent(values)
+	if err != nil {
+		return err
+	}
+	defer event.Free()
+	if err := es.app.EventHandler.SendEvent(es.ID, event); err != nil {
+		return err
+	}
+	es.sent = active
+	if active {
+		es.activeAt = time.Now()
+	}
+	return nil
+}
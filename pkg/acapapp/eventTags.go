@@ -0,0 +1,224 @@
+package acapapp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axevent"
+)
+
+// eventField describes a struct field that maps to a key of an event key value set.
+//
+// Fields are described by the struct tag eventKey:"[namespace:]key[,option...]", fields without the tag
+// use the lower case field name as key, eventKey:"-" skips the field. Supported options are:
+//   - source: marks the key as source of the event.
+//   - data: marks the key as data of the event.
+//   - omitempty: the key may be missing in an event, a zero value is left out of sent events.
+//
+// The additional tags keyNiceName, valueNiceName and userDefined set the nice names and the user defined tag of the key.
+// Pointer fields behave like omitempty fields where nil means no value. A time.Time field holds the event timestamp instead of a key.
+type eventField struct {
+	index         int
+	name          string
+	key           string
+	namespace     *string
+	valueType     axevent.AXEventValueType
+	source        bool
+	data          bool
+	omitempty     bool
+	pointer       bool
+	timestamp     bool
+	keyNiceName   *string
+	valueNiceName *string
+	userDefined   *string
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// parseEventFields returns the event fields of the struct type t.
+// Untagged fields of unsupported types are skipped, a tagged one is an error.
+func parseEventFields(t reflect.Type) ([]eventField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("event type must be a struct, got %s", t)
+	}
+
+	var fields []eventField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag, tagged := sf.Tag.Lookup("eventKey")
+		if tag == "-" {
+			continue
+		}
+
+		f := eventField{index: i, name: sf.Name}
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			f.pointer = true
+			ft = ft.Elem()
+		}
+		if ft == timeType {
+			f.timestamp = true
+			fields = append(fields, f)
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		f.key, f.namespace = splitEventKey(parts[0])
+		if f.key == "" {
+			f.key = strings.ToLower(sf.Name)
+		}
+		for _, opt := range parts[1:] {
+			switch strings.TrimSpace(opt) {
+			case "source":
+				f.source = true
+			case "data":
+				f.data = true
+			case "omitempty":
+				f.omitempty = true
+			case "":
+			default:
+				return nil, fmt.Errorf("unknown eventKey option %q on field %s", opt, sf.Name)
+			}
+		}
+		if v, ok := sf.Tag.Lookup("keyNiceName"); ok {
+			f.keyNiceName = &v
+		}
+		if v, ok := sf.Tag.Lookup("valueNiceName"); ok {
+			f.valueNiceName = &v
+		}
+		if v, ok := sf.Tag.Lookup("userDefined"); ok {
+			f.userDefined = &v
+		}
+
+		vt, err := eventValueType(ft)
+		if err != nil {
+			if !tagged {
+				// Untagged fields of other types are not part of the event.
+				continue
+			}
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		f.valueType = vt
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// splitEventKey splits a key of the form namespace:key.
+func splitEventKey(s string) (string, *string) {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, ":"); i >= 0 {
+		namespace := s[:i]
+		return s[i+1:], &namespace
+	}
+	return s, nil
+}
+
+// eventValueType maps a Go type to the matching axevent value type.
+func eventValueType(t reflect.Type) (axevent.AXEventValueType, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return axevent.AXValueTypeBool, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return axevent.AXValueTypeInt, nil
+	case reflect.Float32, reflect.Float64:
+		return axevent.AXValueTypeDouble, nil
+	case reflect.String:
+		return axevent.AXValueTypeString, nil
+	}
+	return 0, fmt.Errorf("unsupported event value type %s", t)
+}
+
+// eventValue converts a field value into the representation expected by axevent,
+// integers become int, floats become float64. Integers that do not fit into an event integer are rejected.
+// A nil pointer results in a nil value.
+func eventValue(v reflect.Value) (any, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, fmt.Errorf("value %d overflows an event integer", i)
+		}
+		return int(i), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		if u > math.MaxInt32 {
+			return nil, fmt.Errorf("value %d overflows an event integer", u)
+		}
+		return int(u), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	}
+	return nil, fmt.Errorf("unsupported event value type %s", v.Type())
+}
+
+// isEmpty reports whether the field has no value to send, which is a nil pointer or a zero value of an omitempty field.
+func (f eventField) isEmpty(v reflect.Value) bool {
+	if f.pointer {
+		return v.IsNil()
+	}
+	return f.omitempty && v.IsZero()
+}
+
+// eventEntries returns the key value entries for the fields of the struct value sv.
+// Empty fields are left out. If wildcards is set, nil pointers are added without a value, which matches any value
+// in a subscription, and all other fields are added with their value, also when it is zero.
+func eventEntries(fields []eventField, sv reflect.Value, wildcards bool) ([]axevent.KeyValueEntrie, error) {
+	entries := make([]axevent.KeyValueEntrie, 0, len(fields))
+	for _, f := range fields {
+		if f.timestamp {
+			continue
+		}
+		fv := sv.Field(f.index)
+		if wildcards && f.pointer && fv.IsNil() {
+			entries = append(entries, axevent.KeyValueEntrie{Key: f.key, Namespace: f.namespace, ValueType: f.valueType})
+			continue
+		}
+		if !wildcards && f.isEmpty(fv) {
+			continue
+		}
+		value, err := eventValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", f.key, err)
+		}
+		entries = append(entries, axevent.KeyValueEntrie{Key: f.key, Namespace: f.namespace, Value: value, ValueType: f.valueType})
+	}
+	return entries, nil
+}
+
+// eventTimestamp returns the value of the first non-zero time.Time field, or nil.
+func eventTimestamp(fields []eventField, sv reflect.Value) *time.Time {
+	for _, f := range fields {
+		if !f.timestamp {
+			continue
+		}
+		fv := sv.Field(f.index)
+		if f.pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+		if ts := fv.Interface().(time.Time); !ts.IsZero() {
+			return &ts
+		}
+	}
+	return nil
+}
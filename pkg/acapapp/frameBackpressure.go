@@ -0,0 +1,135 @@
+package acapapp
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo"
+)
+
+// BackpressurePolicy defines how a FrameProvider delivers frames when the consumer of FrameStreamChannel
+// does not keep up with the video stream.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock waits until the consumer has room in the channel, this stalls the VDO buffer loop.
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDropOldest discards the oldest queued frame to make room for the new one.
+	BackpressureDropOldest
+	// BackpressureDropNewest discards the new frame when the channel is full.
+	BackpressureDropNewest
+	// BackpressureLatestOnly keeps only the most recent frame, the channel depth is always 1.
+	BackpressureLatestOnly
+)
+
+// DefaultFrameChannelDepth is the default buffer size of FrameStreamChannel.
+const DefaultFrameChannelDepth = 30
+
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case BackpressureBlock:
+		return "Block"
+	case BackpressureDropOldest:
+		return "DropOldest"
+	case BackpressureDropNewest:
+		return "DropNewest"
+	case BackpressureLatestOnly:
+		return "LatestOnly"
+	default:
+		return "Unknown"
+	}
+}
+
+// FrameProviderOption configures a FrameProvider when it is created with NewFrameProvider or AddFrameProvider.
+type FrameProviderOption func(*FrameProvider)
+
+// WithBackpressure sets the policy used when FrameStreamChannel is full, default is BackpressureBlock.
+func WithBackpressure(policy BackpressurePolicy) FrameProviderOption {
+	return func(fp *FrameProvider) {
+		fp.backpressure = policy
+	}
+}
+
+// WithChannelDepth sets the buffer size of FrameStreamChannel, default is DefaultFrameChannelDepth.
+// The depth is ignored for BackpressureLatestOnly.
+func WithChannelDepth(depth int) FrameProviderOption {
+	return func(fp *FrameProvider) {
+		if depth > 0 {
+			fp.channelDepth = depth
+		}
+	}
+}
+
+// frameDeliveryStats holds the counters of the frame delivery, they are written from the frame goroutine
+// and read from Stats.
+type frameDeliveryStats struct {
+	delivered     atomic.Uint64
+	droppedOldest atomic.Uint64
+	droppedNewest atomic.Uint64
+	lastAge       atomic.Int64
+	maxAge        atomic.Int64
+	totalAge      atomic.Int64
+	agedFrames    atomic.Uint64
+}
+
+// deliverFrame hands a frame to FrameStreamChannel according to the configured backpressure policy.
+// A blocking delivery is abandoned when stop is closed. Subscriptions receive the frame first, each with its own policy.
+func (fp *FrameProvider) deliverFrame(frame *axvdo.VideoFrame, stop chan struct{}) {
+	fp.subscriptions.publish(frame, stop)
+	switch fp.backpressure {
+	case BackpressureDropNewest:
+		select {
+		case fp.FrameStreamChannel <- frame:
+		default:
+			fp.deliveryStats.droppedNewest.Add(1)
+			return
+		}
+	case BackpressureDropOldest, BackpressureLatestOnly:
+		for sent := false; !sent; {
+			select {
+			case fp.FrameStreamChannel <- frame:
+				sent = true
+			default:
+				select {
+				case <-fp.FrameStreamChannel:
+					fp.deliveryStats.droppedOldest.Add(1)
+				default:
+				}
+			}
+		}
+	default:
+		select {
+		case fp.FrameStreamChannel <- frame:
+		case <-stop:
+			return
+		}
+	}
+	fp.deliveryStats.delivered.Add(1)
+	fp.recordFrameAge(frame)
+}
+
+// recordFrameAge tracks the age of a frame from capture until it was handed to the consumer.
+func (fp *FrameProvider) recordFrameAge(frame *axvdo.VideoFrame) {
+	if frame.Timestamp.IsZero() {
+		return
+	}
+	age := int64(time.Since(frame.Timestamp))
+	fp.deliveryStats.lastAge.Store(age)
+	fp.deliveryStats.totalAge.Add(age)
+	fp.deliveryStats.agedFrames.Add(1)
+	for {
+		cur := fp.deliveryStats.maxAge.Load()
+		if age <= cur || fp.deliveryStats.maxAge.CompareAndSwap(cur, age) {
+			return
+		}
+	}
+}
+
+// averageFrameAge returns the mean age of all delivered frames.
+func (s *frameDeliveryStats) averageFrameAge() time.Duration {
+	n := s.agedFrames.Load()
+	if n == 0 {
+		return 0
+	}
+	return time.Duration(s.totalAge.Load() / int64(n))
+}
@@ -2,6 +2,8 @@ package acapapp
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Cacsjep/goxis/pkg/axlarod"
@@ -9,7 +11,7 @@ import (
 )
 
 // FrameProviderState defines the possible states of a FrameProvider.
-type FrameProviderState int
+type FrameProviderState int32
 
 const (
 	// FrameProviderStateError indicates an error state where the frame provider cannot recover without intervention.
@@ -23,52 +25,92 @@ const (
 	// FrameProviderStateInit indicates the frame provider is initialized but not yet started.
 	FrameProviderStateInit
 	// MaxRestartRetries defines the maximum number of restart attempts for the frame provider before entering an error state.
+	// It is used by the default restart backoff, see WithRestartBackoff to change it.
 	MaxRestartRetries int = 4
 )
 
 // FrameProvider encapsulates the management of video frame streaming, including starting, stopping, and restarting the stream.
 type FrameProvider struct {
+	Name               string                        // Name under which the frame provider is registered in the application.
 	Config             axvdo.VideoSteamConfiguration // Configuration for the video stream.
-	stream             *axvdo.VdoStream              // Internal video stream reference.
-	state              FrameProviderState            // Current state of the frame provider.
-	running            bool                          // Flag indicating whether the frame provider is actively running.
-	FrameStreamChannel chan *axvdo.VideoFrame        // Channel for delivering video frames to consumers.
-	restartRetries     int                           // Counter for the number of restart attempts.
+	stream             *axvdo.VdoStream              // Internal video stream reference, guarded by streamMu.
+	streamMu           sync.Mutex                    // Guards stream.
+	lifecycleMu        sync.Mutex                    // Serializes Start and Stop.
+	stop               chan struct{}                 // Closed by Stop to end the frame loop.
+	state              atomic.Int32                  // Current FrameProviderState of the frame provider.
+	stateListeners     stateListeners                // Receivers of state change notifications.
+	backoff            RestartBackoff                // Delay policy between restart attempts.
+	FrameStreamChannel chan *axvdo.VideoFrame        // Channel for delivering video frames to a consumer, use Subscribe for more than one.
+	restartRetries     atomic.Int32                  // Counter for the number of restart attempts.
 	app                *AcapApplication              // Reference to the application managing this frame provider.
 	PostProcessModel   *axlarod.LarodModel           // Post proccessor for the frame provider, combination of the pp model and the frame provider
 	outReso            *axvdo.VdoResolution
+	rgbMode            axlarod.PreProccessOutputFormat
 	frameProccessor    func([]byte) []byte
+	backpressure       BackpressurePolicy // Policy used when FrameStreamChannel is full.
+	channelDepth       int                // Buffer size of FrameStreamChannel.
+	deliveryStats      frameDeliveryStats // Delivery and drop counters.
+	subscriptions      frameSubscriptions // Independent consumers next to FrameStreamChannel.
 }
 
 // FrameProviderStats provides statistical information about the operation of a FrameProvider.
 type FrameProviderStats struct {
-	InternalChannelBufferLen int               // The current length of the frame stream channel buffer.
-	RestartRetries           int               // The number of restart attempts made since the last successful start.
-	StreamStats              axvdo.StreamStats // Statistics gathered from the video stream.
+	InternalChannelBufferLen int                // The current length of the frame stream channel buffer.
+	ChannelDepth             int                // The capacity of the frame stream channel buffer.
+	Backpressure             BackpressurePolicy // The policy used when the frame stream channel is full.
+	DeliveredFrames          uint64             // The number of frames handed to the frame stream channel.
+	DroppedFrames            uint64             // The total number of frames dropped because the consumer was too slow.
+	DroppedOldestFrames      uint64             // The number of queued frames discarded in favour of newer ones.
+	DroppedNewestFrames      uint64             // The number of new frames discarded because the channel was full.
+	FrameAge                 time.Duration      // Age of the last delivered frame, from capture until it was handed to the consumer.
+	AvgFrameAge              time.Duration      // Average age of all delivered frames.
+	MaxFrameAge              time.Duration      // Maximum age of all delivered frames.
+	RestartRetries           int                // The number of restart attempts made since the last successful start.
+	StreamStats              axvdo.StreamStats  // Statistics gathered from the video stream.
+	Subscribers              []SubscriberStats  // Delivery counters of every FrameSubscription, in the order they subscribed.
 }
 
-// NewFrameProvider initializes a new FrameProvider with the given configuration and application context.
+// NewFrameProvider initializes a new FrameProvider with the given configuration and application context
+// and registers it as the application's default FrameProvider (see DefaultFrameProviderName).
 // It prepares the frame provider for operation but does not start streaming frames until Start is called.
+// Options like WithBackpressure or WithChannelDepth control how frames are delivered to FrameStreamChannel.
 //
 // Note:
 //
-//	Currently this supports only one FrameProvider per application. If you need more then one dont use frameProvider in AcapApplication.
-//	Create a new FrameProvider for each stream, and manage them separately, dont forget to call Stop() when you are done.
-func (a *AcapApplication) NewFrameProvider(config axvdo.VideoSteamConfiguration) error {
+//	For more than one stream use AddFrameProvider, every registered FrameProvider is stopped when the application closes.
+func (a *AcapApplication) NewFrameProvider(config axvdo.VideoSteamConfiguration, opts ...FrameProviderOption) error {
+	fp, err := a.AddFrameProvider(DefaultFrameProviderName, config, opts...)
+	if err != nil {
+		return err
+	}
+	a.FrameProvider = fp
+	return nil
+}
+
+// newFrameProvider creates a FrameProvider and its underlying video stream without registering it.
+func newFrameProvider(a *AcapApplication, name string, config axvdo.VideoSteamConfiguration, opts ...FrameProviderOption) (*FrameProvider, error) {
 	fp := &FrameProvider{
-		Config:             config,
-		state:              FrameProviderStateInit,
-		FrameStreamChannel: make(chan *axvdo.VideoFrame, 30),
-		running:            false,
-		app:                a,
+		Name:         name,
+		Config:       config,
+		app:          a,
+		backoff:      NewFixedBackoff(2*time.Second, MaxRestartRetries),
+		backpressure: BackpressureBlock,
+		channelDepth: DefaultFrameChannelDepth,
+	}
+	fp.state.Store(int32(FrameProviderStateInit))
+	for _, opt := range opts {
+		opt(fp)
 	}
+	if fp.backpressure == BackpressureLatestOnly {
+		fp.channelDepth = 1
+	}
+	fp.FrameStreamChannel = make(chan *axvdo.VideoFrame, fp.channelDepth)
 	stream, err := fp.createStream()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	fp.stream = stream
-	a.FrameProvider = fp
-	return nil
+	return fp, nil
 }
 
 // createStream initializes the video stream based on the FrameProvider's configuration.
@@ -86,26 +128,23 @@ func (fp *FrameProvider) SetLarodPostProccessor(device string, rgbMode axlarod.P
 		return fmt.Errorf("Application is not initialized")
 	}
 
-	if fp.app.FrameProvider == nil {
-		return fmt.Errorf("FrameProvider is not initialized")
-	}
-
 	if fp.app.Larod == nil {
 		return fmt.Errorf("Larod is not initialized")
 	}
 
-	if fp.app.FrameProvider.Config.Width == nil || fp.app.FrameProvider.Config.Height == nil {
+	if fp.Config.Width == nil || fp.Config.Height == nil {
 		return fmt.Errorf("FrameProvider width and height is not initialized")
 	}
 
-	cropMap, err := axlarod.CreateCropMap(outReso.Width, outReso.Height, *fp.app.FrameProvider.Config.Width, *fp.app.FrameProvider.Config.Height)
+	cropMap, err := axlarod.CreateCropMap(outReso.Width, outReso.Height, *fp.Config.Width, *fp.Config.Height)
 	if err != nil {
 		return err
 	}
 	fp.outReso = outReso
-	if fp.app.FrameProvider.PostProcessModel, err = fp.app.Larod.NewPreProccessModel(
+	fp.rgbMode = rgbMode
+	if fp.PostProcessModel, err = fp.app.Larod.NewPreProccessModel(
 		device,
-		axlarod.LarodResolution{Width: *fp.app.FrameProvider.Config.Width, Height: *fp.app.FrameProvider.Config.Height},
+		axlarod.LarodResolution{Width: *fp.Config.Width, Height: *fp.Config.Height},
 		axlarod.LarodResolution{Width: outReso.Width, Height: outReso.Height},
 		rgbMode,
 		cropMap,
@@ -119,10 +158,10 @@ func (fp *FrameProvider) SetLarodPostProccessor(device string, rgbMode axlarod.P
 func (fp *FrameProvider) frameProviderPostProcess(frame *axvdo.VideoFrame) (*axlarod.JobResult, error) {
 	var result *axlarod.JobResult
 	var err error
-	if result, err = fp.app.Larod.ExecuteJob(fp.app.FrameProvider.PostProcessModel, func() error {
-		return fp.app.FrameProvider.PostProcessModel.Inputs[0].CopyDataInto(frame.Data)
+	if result, err = fp.app.Larod.ExecuteJob(fp.PostProcessModel, func() error {
+		return fp.PostProcessModel.Inputs[0].CopyDataInto(frame.Data)
 	}, func() (any, error) {
-		img, err := fp.app.FrameProvider.PostProcessModel.Outputs[0].GetData(fp.outReso.RgbSize())
+		img, err := fp.PostProcessModel.Outputs[0].GetData(fp.outReso.RgbSize())
 		return fp.frameProccessor(img), err
 	}); err != nil {
 		return nil, err
@@ -130,114 +169,246 @@ func (fp *FrameProvider) frameProviderPostProcess(frame *axvdo.VideoFrame) (*axl
 	return result, nil
 }
 
-// Start begins the frame streaming process, marking the FrameProvider as running and initiating the frame fetching loop.
+// Start begins the frame streaming process, marking the FrameProvider as started and initiating the frame fetching loop.
 // If an error occurs while starting the stream, it returns the error without altering the provider's state.
-// Handles automatic restart in case of an expected Vdo error
+// A previously stopped FrameProvider gets a new underlying stream, so it can be started again.
+// Handles automatic restart in case of an expected Vdo error, the delay between attempts is controlled by the RestartBackoff.
 func (fp *FrameProvider) Start() error {
-	if err := fp.stream.Start(); err != nil {
+	fp.lifecycleMu.Lock()
+	defer fp.lifecycleMu.Unlock()
+
+	if fp.IsRunning() {
+		return nil
+	}
+
+	fp.streamMu.Lock()
+	stream := fp.stream
+	fp.streamMu.Unlock()
+
+	if stream == nil {
+		var err error
+		if stream, err = fp.createStream(); err != nil {
+			return err
+		}
+	}
+
+	if err := stream.Start(); err != nil {
+		fp.streamMu.Lock()
+		fp.stream = stream
+		fp.streamMu.Unlock()
 		return err
 	}
 
-	fp.running = true
-	fp.state = FrameProviderStateStarted
+	fp.streamMu.Lock()
+	fp.stream = stream
+	fp.streamMu.Unlock()
+
+	fp.stop = make(chan struct{})
+	fp.restartRetries.Store(0)
+	if err := fp.transition(FrameProviderStateStarted, nil); err != nil {
+		return err
+	}
 	fp.app.Syslog.Infof("VDO Channel(%d): Stream is started", fp.Config.GetChannel())
 
-	go func() {
-		for fp.running {
-			video_frame := axvdo.GetVideoFrame(fp.stream)
-			if video_frame.Error != nil {
-				if video_frame.ErrorExpected {
-					if fp.state == FrameProviderStateStopped {
-						fp.app.Syslog.Infof("VDO Channel(%d): exit frame loop", fp.Config.GetChannel())
-						return
-					}
-					fp.app.Syslog.Warnf("VDO Channel(%d): Restarting stream because vdo is in maintanance mode %s", fp.Config.GetChannel(), video_frame.Error.Error())
-					if err := fp.Restart(); err != nil {
-						fp.app.Syslog.Warnf("VDO Channel(%d): Unable to restart stream, try again...: %s", fp.Config.GetChannel(), err.Error())
-						if fp.restartRetries >= MaxRestartRetries {
-							fp.state = FrameProviderStateError
-							fp.app.Syslog.Errorf("VDO Channel(%d): Max retries for stream restart reached, stream is stopped", fp.Config.GetChannel())
-							break
-						}
-						fp.restartRetries++
-					} else {
-						fp.app.Syslog.Infof("VDO Channel(%d): Successfully restart stream", fp.Config.GetChannel())
-						fp.running = true
-						fp.state = FrameProviderStateStarted
-					}
-					continue
+	go fp.frameLoop(stream, fp.stop)
+	return nil
+}
+
+// frameLoop fetches frames from the given stream until stop is closed.
+// The loop owns the stream, it unrefs it when the loop exits or the stream is replaced by a restart.
+func (fp *FrameProvider) frameLoop(stream *axvdo.VdoStream, stop chan struct{}) {
+	defer func() {
+		if stream != nil {
+			fp.releaseStream(stream)
+		}
+		fp.app.Syslog.Infof("VDO Channel(%d): exit frame loop", fp.Config.GetChannel())
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		video_frame := axvdo.GetVideoFrame(stream)
+		if video_frame.Error != nil {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if video_frame.ErrorExpected {
+				fp.app.Syslog.Warnf("VDO Channel(%d): Restarting stream because vdo is in maintanance mode %s", fp.Config.GetChannel(), video_frame.Error.Error())
+				fp.releaseStream(stream)
+				if stream = fp.restartStream(stop); stream == nil {
+					return
 				}
-				fp.app.Syslog.Errorf("VDO Channel(%d): Vdo returns an error when getting buffer/frame data %s", fp.Config.Channel, video_frame.Error.Error())
 				continue
 			}
-			fp.restartRetries = 0
-			if fp.PostProcessModel != nil {
-				job_r, err := fp.frameProviderPostProcess(video_frame)
-				var job_err error
-				var data []byte
-
-				if err != nil {
-					job_err = err
-				} else {
-					data = job_r.OutputData.([]byte)
-				}
-				fp.FrameStreamChannel <- &axvdo.VideoFrame{
-					Data:        data,
-					Size:        uint(len(data)),
-					SequenceNbr: video_frame.SequenceNbr,
-					Timestamp:   video_frame.Timestamp,
-					Type:        axvdo.VdoFrameTypeRGB,
-					Error:       job_err,
-				}
+			fp.app.Syslog.Errorf("VDO Channel(%d): Vdo returns an error when getting buffer/frame data %s", fp.Config.GetChannel(), video_frame.Error.Error())
+			continue
+		}
+
+		if fp.PostProcessModel != nil {
+			job_r, err := fp.frameProviderPostProcess(video_frame)
+			var job_err error
+			var data []byte
 
+			if err != nil {
+				job_err = err
 			} else {
-				fp.FrameStreamChannel <- video_frame
+				data = job_r.OutputData.([]byte)
 			}
+			fp.deliverFrame(&axvdo.VideoFrame{
+				Data:        data,
+				Size:        uint(len(data)),
+				SequenceNbr: video_frame.SequenceNbr,
+				Timestamp:   video_frame.Timestamp,
+				Type:        axvdo.VdoFrameTypeRGB,
+				Error:       job_err,
+			}, stop)
+
+		} else {
+			fp.deliverFrame(video_frame, stop)
 		}
-	}()
-	return nil
+	}
+}
+
+// restartStream creates and starts a new stream, retrying according to the RestartBackoff.
+// It returns nil when the FrameProvider was stopped meanwhile or the backoff gives up, in the latter case
+// the FrameProvider enters FrameProviderStateError.
+func (fp *FrameProvider) restartStream(stop chan struct{}) *axvdo.VdoStream {
+	if err := fp.transition(FrameProviderStateRestarting, nil); err != nil {
+		return nil
+	}
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		delay, retry := fp.backoff.NextDelay(attempt)
+		if !retry {
+			fp.app.Syslog.Errorf("VDO Channel(%d): Max retries for stream restart reached, stream is stopped", fp.Config.GetChannel())
+			fp.transition(FrameProviderStateError, fmt.Errorf("unable to restart stream after %d attempts: %w", attempt-1, lastErr))
+			return nil
+		}
+		fp.restartRetries.Store(int32(attempt))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-stop:
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		fp.app.Syslog.Infof("VDO Channel(%d): Try to restart stream", fp.Config.GetChannel())
+		stream, err := fp.createStream()
+		if err == nil {
+			if err = stream.Start(); err != nil {
+				stream.Unref()
+			}
+		}
+		if err != nil {
+			lastErr = err
+			fp.app.Syslog.Warnf("VDO Channel(%d): Unable to restart stream, try again...: %s", fp.Config.GetChannel(), err.Error())
+			continue
+		}
+
+		fp.streamMu.Lock()
+		select {
+		case <-stop:
+			// Stopped while the new stream was starting, the FrameProvider may already own a newer stream.
+			fp.streamMu.Unlock()
+			stream.Stop()
+			stream.Unref()
+			return nil
+		default:
+		}
+		fp.stream = stream
+		fp.streamMu.Unlock()
+		if err := fp.transition(FrameProviderStateStarted, nil); err != nil {
+			// Stopped while the new stream was starting.
+			fp.releaseStream(stream)
+			return nil
+		}
+		fp.restartRetries.Store(0)
+		fp.app.Syslog.Infof("VDO Channel(%d): Successfully restart stream", fp.Config.GetChannel())
+		return stream
+	}
+}
+
+// releaseStream stops and unrefs the stream and detaches it from the FrameProvider if it is the current one.
+func (fp *FrameProvider) releaseStream(stream *axvdo.VdoStream) {
+	fp.streamMu.Lock()
+	if fp.stream == stream {
+		fp.stream = nil
+	}
+	fp.streamMu.Unlock()
+	stream.Stop()
+	stream.Unref()
 }
 
 // Stop halts the frame streaming process, changing the state of the FrameProvider to stopped and cleaning up resources.
+// The stream is detached from the FrameProvider and released by the frame loop when it exits, so a following Start
+// always creates a new stream. Calling Stop on an already stopped FrameProvider has no effect.
 func (fp *FrameProvider) Stop() {
-	fp.running = false
-	fp.state = FrameProviderStateStopped
-	fp.stream.Stop()
-	fp.stream.Unref()
+	fp.lifecycleMu.Lock()
+	defer fp.lifecycleMu.Unlock()
+
+	from := fp.State()
+	if err := fp.transition(FrameProviderStateStopped, nil); err != nil {
+		return
+	}
+
+	if from == FrameProviderStateInit {
+		// No frame loop owns the stream yet.
+		fp.streamMu.Lock()
+		stream := fp.stream
+		fp.stream = nil
+		fp.streamMu.Unlock()
+		if stream != nil {
+			stream.Unref()
+		}
+	} else if fp.stop != nil {
+		// stop is closed under streamMu, so a restart of the frame loop can not attach its stream afterwards.
+		fp.streamMu.Lock()
+		close(fp.stop)
+		if fp.stream != nil {
+			fp.stream.Stop()
+			fp.stream = nil
+		}
+		fp.streamMu.Unlock()
+		fp.stop = nil
+	}
 	fp.app.Syslog.Infof("VDO Channel(%d): Stream is stopped", fp.Config.GetChannel())
 }
 
-// Restart attempts to restart the video stream, first stopping the current stream and then re-initializing and starting a new stream.
-// It applies a delay before attempting the restart to give the system time to release resources.
+// Restart stops the FrameProvider and starts it again with a new video stream.
 func (fp *FrameProvider) Restart() error {
-	if fp.state == FrameProviderStateStopped {
-		fp.app.Syslog.Infof("VDO Channel(%d): exit frame loop", fp.Config.GetChannel())
-		return nil
-	}
-	time.Sleep(time.Second * 2)
-	fp.app.Syslog.Infof("VDO Channel(%d): Try to restart stream", fp.Config.GetChannel())
-	var err error
-	fp.state = FrameProviderStateRestarting
 	fp.Stop()
-	if fp.stream, err = fp.createStream(); err != nil {
-		return err
-	}
-	return fp.stream.Start()
+	return fp.Start()
 }
 
 // State returns the current state of the FrameProvider, providing insight into whether it's running, stopped, or in an error state.
 func (fp *FrameProvider) State() FrameProviderState {
-	return fp.state
+	return FrameProviderState(fp.state.Load())
 }
 
-// IsRunning checks if the FrameProvider is currently active and streaming frames.
+// IsRunning checks if the FrameProvider is currently active and streaming frames or recovering from a vdo maintenance.
 func (fp *FrameProvider) IsRunning() bool {
-	return fp.running
+	state := fp.State()
+	return state == FrameProviderStateStarted || state == FrameProviderStateRestarting
 }
 
 // Stats gathers and returns statistical information about the frame provider's operation, including internal buffer lengths and stream statistics.
 func (fp *FrameProvider) Stats() (*FrameProviderStats, error) {
+	fp.streamMu.Lock()
+	if fp.stream == nil {
+		fp.streamMu.Unlock()
+		return nil, fmt.Errorf("VDO Channel(%d): no active stream", fp.Config.GetChannel())
+	}
 	m, err := fp.stream.GetInfo()
+	fp.streamMu.Unlock()
 	if err != nil {
 		return nil, err
 	}
@@ -280,9 +451,21 @@ func (fp *FrameProvider) Stats() (*FrameProviderStats, error) {
 		ZipProfile:                    m.GetInt16("zip.profile", 0),
 	}
 
+	droppedOldest := fp.deliveryStats.droppedOldest.Load()
+	droppedNewest := fp.deliveryStats.droppedNewest.Load()
 	return &FrameProviderStats{
 		StreamStats:              stats,
-		RestartRetries:           fp.restartRetries,
+		RestartRetries:           int(fp.restartRetries.Load()),
 		InternalChannelBufferLen: len(fp.FrameStreamChannel),
+		ChannelDepth:             cap(fp.FrameStreamChannel),
+		Backpressure:             fp.backpressure,
+		DeliveredFrames:          fp.deliveryStats.delivered.Load(),
+		DroppedFrames:            droppedOldest + droppedNewest,
+		DroppedOldestFrames:      droppedOldest,
+		DroppedNewestFrames:      droppedNewest,
+		FrameAge:                 time.Duration(fp.deliveryStats.lastAge.Load()),
+		AvgFrameAge:              fp.deliveryStats.averageFrameAge(),
+		MaxFrameAge:              time.Duration(fp.deliveryStats.maxAge.Load()),
+		Subscribers:              fp.subscriptions.stats(),
 	}, nil
 }
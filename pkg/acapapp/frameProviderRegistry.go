@@ -0,0 +1,133 @@
+package acapapp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo"
+)
+
+// DefaultFrameProviderName is the name under which NewFrameProvider registers the application's default FrameProvider.
+const DefaultFrameProviderName = "default"
+
+// AddFrameProvider creates a new FrameProvider with the given configuration and registers it under the given name.
+// The FrameProvider is not started, use Start or StartFrameProvider to begin streaming.
+// Options like WithBackpressure or WithChannelDepth control how frames are delivered to FrameStreamChannel.
+// Every registered FrameProvider is stopped when the application closes.
+func (a *AcapApplication) AddFrameProvider(name string, config axvdo.VideoSteamConfiguration, opts ...FrameProviderOption) (*FrameProvider, error) {
+	if name == "" {
+		return nil, errors.New("FrameProvider name must not be empty")
+	}
+
+	a.frameProvidersMu.Lock()
+	defer a.frameProvidersMu.Unlock()
+
+	if _, exists := a.frameProviders[name]; exists {
+		return nil, fmt.Errorf("FrameProvider %s already exists", name)
+	}
+
+	fp, err := newFrameProvider(a, name, config, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.frameProviders == nil {
+		a.frameProviders = make(map[string]*FrameProvider)
+	}
+	a.frameProviders[name] = fp
+	a.frameProviderNames = append(a.frameProviderNames, name)
+	return fp, nil
+}
+
+// GetFrameProvider returns the FrameProvider registered under the given name.
+// It returns the found FrameProvider and a boolean indicating whether the search was successful.
+func (a *AcapApplication) GetFrameProvider(name string) (*FrameProvider, bool) {
+	a.frameProvidersMu.Lock()
+	defer a.frameProvidersMu.Unlock()
+	fp, found := a.frameProviders[name]
+	return fp, found
+}
+
+// GetFrameProviderByChannel returns the first registered FrameProvider streaming from the given video channel.
+// It returns the found FrameProvider and a boolean indicating whether the search was successful.
+func (a *AcapApplication) GetFrameProviderByChannel(channel int) (*FrameProvider, bool) {
+	for _, fp := range a.FrameProviders() {
+		if fp.Config.GetChannel() == channel {
+			return fp, true
+		}
+	}
+	return nil, false
+}
+
+// FrameProviders returns all registered FrameProviders in registration order.
+func (a *AcapApplication) FrameProviders() []*FrameProvider {
+	a.frameProvidersMu.Lock()
+	defer a.frameProvidersMu.Unlock()
+	fps := make([]*FrameProvider, 0, len(a.frameProviderNames))
+	for _, name := range a.frameProviderNames {
+		fps = append(fps, a.frameProviders[name])
+	}
+	return fps
+}
+
+// StartFrameProvider starts the FrameProvider registered under the given name.
+func (a *AcapApplication) StartFrameProvider(name string) error {
+	fp, found := a.GetFrameProvider(name)
+	if !found {
+		return fmt.Errorf("FrameProvider %s not found", name)
+	}
+	return fp.Start()
+}
+
+// StopFrameProvider stops the FrameProvider registered under the given name.
+func (a *AcapApplication) StopFrameProvider(name string) error {
+	fp, found := a.GetFrameProvider(name)
+	if !found {
+		return fmt.Errorf("FrameProvider %s not found", name)
+	}
+	fp.Stop()
+	return nil
+}
+
+// RemoveFrameProvider stops the FrameProvider registered under the given name and removes it from the application.
+func (a *AcapApplication) RemoveFrameProvider(name string) error {
+	a.frameProvidersMu.Lock()
+	fp, found := a.frameProviders[name]
+	if found {
+		delete(a.frameProviders, name)
+		for i, n := range a.frameProviderNames {
+			if n == name {
+				a.frameProviderNames = append(a.frameProviderNames[:i], a.frameProviderNames[i+1:]...)
+				break
+			}
+		}
+	}
+	a.frameProvidersMu.Unlock()
+
+	if !found {
+		return fmt.Errorf("FrameProvider %s not found", name)
+	}
+	fp.Stop()
+	if a.FrameProvider == fp {
+		a.FrameProvider = nil
+	}
+	return nil
+}
+
+// StartFrameProviders starts all registered FrameProviders in registration order.
+// It stops at the first FrameProvider that fails to start and returns its error.
+func (a *AcapApplication) StartFrameProviders() error {
+	for _, fp := range a.FrameProviders() {
+		if err := fp.Start(); err != nil {
+			return fmt.Errorf("unable to start FrameProvider %s: %w", fp.Name, err)
+		}
+	}
+	return nil
+}
+
+// StopFrameProviders stops all registered FrameProviders.
+func (a *AcapApplication) StopFrameProviders() {
+	for _, fp := range a.FrameProviders() {
+		fp.Stop()
+	}
+}
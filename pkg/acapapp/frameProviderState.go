@@ -0,0 +1,201 @@
+package acapapp
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// FrameProviderStateChange describes a transition of a FrameProvider from one state to another.
+type FrameProviderStateChange struct {
+	Provider *FrameProvider     // The FrameProvider that changed its state.
+	From     FrameProviderState // The previous state.
+	To       FrameProviderState // The new state.
+	Err      error              // The cause of the transition, set when entering FrameProviderStateError.
+}
+
+// validFrameProviderTransitions lists the states reachable from each state.
+var validFrameProviderTransitions = map[FrameProviderState][]FrameProviderState{
+	FrameProviderStateInit:       {FrameProviderStateStarted, FrameProviderStateStopped},
+	FrameProviderStateStarted:    {FrameProviderStateRestarting, FrameProviderStateStopped, FrameProviderStateError},
+	FrameProviderStateRestarting: {FrameProviderStateStarted, FrameProviderStateStopped, FrameProviderStateError},
+	FrameProviderStateStopped:    {FrameProviderStateStarted},
+	FrameProviderStateError:      {FrameProviderStateStarted, FrameProviderStateStopped},
+}
+
+func (s FrameProviderState) String() string {
+	switch s {
+	case FrameProviderStateError:
+		return "Error"
+	case FrameProviderStateStopped:
+		return "Stopped"
+	case FrameProviderStateStarted:
+		return "Started"
+	case FrameProviderStateRestarting:
+		return "Restarting"
+	case FrameProviderStateInit:
+		return "Init"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int32(s))
+	}
+}
+
+// CanTransitionTo reports whether a FrameProvider in state s may change to the given state.
+func (s FrameProviderState) CanTransitionTo(to FrameProviderState) bool {
+	for _, valid := range validFrameProviderTransitions[s] {
+		if valid == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition atomically moves the FrameProvider into the given state and notifies all state listeners.
+// It returns an error if the transition is not valid from the current state.
+func (fp *FrameProvider) transition(to FrameProviderState, cause error) error {
+	for {
+		from := fp.State()
+		if !from.CanTransitionTo(to) {
+			return fmt.Errorf("invalid FrameProvider state transition from %s to %s", from, to)
+		}
+		if fp.state.CompareAndSwap(int32(from), int32(to)) {
+			fp.stateListeners.notify(FrameProviderStateChange{Provider: fp, From: from, To: to, Err: cause})
+			return nil
+		}
+	}
+}
+
+// OnStateChange registers a callback that is invoked on every state change of the FrameProvider.
+// The callback is called from the goroutine that changed the state, it should return quickly.
+func (fp *FrameProvider) OnStateChange(callback func(FrameProviderStateChange)) {
+	fp.stateListeners.mu.Lock()
+	defer fp.stateListeners.mu.Unlock()
+	fp.stateListeners.callbacks = append(fp.stateListeners.callbacks, callback)
+}
+
+// StateChanges returns a channel that receives every state change of the FrameProvider.
+// The channel is buffered, changes are dropped if the receiver does not keep up.
+func (fp *FrameProvider) StateChanges() <-chan FrameProviderStateChange {
+	fp.stateListeners.mu.Lock()
+	defer fp.stateListeners.mu.Unlock()
+	if fp.stateListeners.channel == nil {
+		fp.stateListeners.channel = make(chan FrameProviderStateChange, 16)
+	}
+	return fp.stateListeners.channel
+}
+
+// WithStateChangeHandler registers a state change callback when the FrameProvider is created, see OnStateChange.
+func WithStateChangeHandler(callback func(FrameProviderStateChange)) FrameProviderOption {
+	return func(fp *FrameProvider) {
+		fp.OnStateChange(callback)
+	}
+}
+
+// stateListeners holds the receivers of FrameProvider state changes.
+type stateListeners struct {
+	mu        sync.Mutex
+	callbacks []func(FrameProviderStateChange)
+	channel   chan FrameProviderStateChange
+}
+
+func (l *stateListeners) notify(change FrameProviderStateChange) {
+	l.mu.Lock()
+	callbacks := l.callbacks
+	channel := l.channel
+	l.mu.Unlock()
+
+	if channel != nil {
+		select {
+		case channel <- change:
+		default:
+		}
+	}
+	for _, callback := range callbacks {
+		callback(change)
+	}
+}
+
+// RestartBackoff decides how long a FrameProvider waits before each attempt to restart its stream
+// after vdo went into maintenance mode.
+type RestartBackoff interface {
+	// NextDelay returns the delay before the given restart attempt, starting at 1.
+	// It returns false when no further attempt should be made, the FrameProvider then enters FrameProviderStateError.
+	NextDelay(attempt int) (time.Duration, bool)
+}
+
+// UnlimitedRestarts can be used as maxRetries for a RestartBackoff to never give up restarting.
+const UnlimitedRestarts = 0
+
+// DefaultMaxRestartDelay bounds the delay of an ExponentialBackoff without Max.
+const DefaultMaxRestartDelay = 5 * time.Minute
+
+// FixedBackoff waits the same delay before every restart attempt.
+type FixedBackoff struct {
+	Delay      time.Duration // Delay before each attempt.
+	MaxRetries int           // Maximum number of attempts, UnlimitedRestarts or less retries forever.
+}
+
+// NewFixedBackoff returns a FixedBackoff with the given delay and maximum number of attempts.
+func NewFixedBackoff(delay time.Duration, maxRetries int) *FixedBackoff {
+	return &FixedBackoff{Delay: delay, MaxRetries: maxRetries}
+}
+
+// NextDelay implements RestartBackoff.
+func (b *FixedBackoff) NextDelay(attempt int) (time.Duration, bool) {
+	if b.MaxRetries > 0 && attempt > b.MaxRetries {
+		return 0, false
+	}
+	return b.Delay, true
+}
+
+// ExponentialBackoff multiplies the delay after every failed restart attempt up to a maximum delay.
+// Jitter randomizes each delay by up to the given fraction, e.g. 0.2 results in delays between 80% and 120%.
+type ExponentialBackoff struct {
+	Initial    time.Duration // Delay before the first attempt.
+	Max        time.Duration // Upper bound of the delay, DefaultMaxRestartDelay if 0.
+	Multiplier float64       // Factor applied after every attempt, values below 1 default to 2.
+	Jitter     float64       // Random fraction in the range [0, 1] applied to each delay.
+	MaxRetries int           // Maximum number of attempts, UnlimitedRestarts or less retries forever.
+}
+
+// NewExponentialBackoff returns an ExponentialBackoff doubling the delay from initial up to max.
+func NewExponentialBackoff(initial time.Duration, max time.Duration, maxRetries int) *ExponentialBackoff {
+	return &ExponentialBackoff{Initial: initial, Max: max, Multiplier: 2, MaxRetries: maxRetries}
+}
+
+// NextDelay implements RestartBackoff.
+func (b *ExponentialBackoff) NextDelay(attempt int) (time.Duration, bool) {
+	if b.MaxRetries > 0 && attempt > b.MaxRetries {
+		return 0, false
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	limit := float64(b.Max)
+	if limit <= 0 {
+		limit = float64(DefaultMaxRestartDelay)
+	}
+	// The power overflows to +Inf after enough attempts, the bound keeps the conversion to time.Duration defined.
+	delay := 0.0
+	if b.Initial > 0 {
+		delay = math.Min(float64(b.Initial)*math.Pow(multiplier, float64(attempt-1)), limit)
+	}
+	if b.Jitter > 0 {
+		jitter := math.Min(b.Jitter, 1)
+		delay += delay * jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(math.Max(math.Min(delay, limit), 0)), true
+}
+
+// WithRestartBackoff sets the delay policy between restart attempts,
+// default is a FixedBackoff of 2 seconds with MaxRestartRetries attempts.
+func WithRestartBackoff(backoff RestartBackoff) FrameProviderOption {
+	return func(fp *FrameProvider) {
+		if backoff != nil {
+			fp.backoff = backoff
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package acapapp
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo"
+)
+
+// SubscriptionPolicy defines how a FrameSubscription is fed when its channel is full.
+// Unlike BackpressurePolicy its zero value drops frames, a blocking subscriber has to ask for it.
+type SubscriptionPolicy int
+
+const (
+	// SubscriptionDropOldest discards the oldest queued frame to make room for the new one, it is the default.
+	SubscriptionDropOldest SubscriptionPolicy = iota
+	// SubscriptionDropNewest discards the new frame when the channel is full.
+	SubscriptionDropNewest
+	// SubscriptionLatestOnly keeps only the most recent frame, the channel depth is always 1.
+	SubscriptionLatestOnly
+	// SubscriptionBlock waits until the subscriber has room in the channel, so a slow subscriber never loses a frame.
+	// It stalls the frame loop and with it all other consumers.
+	SubscriptionBlock
+)
+
+func (p SubscriptionPolicy) String() string {
+	return p.backpressure().String()
+}
+
+// backpressure returns the matching BackpressurePolicy, unknown policies drop the oldest frame.
+func (p SubscriptionPolicy) backpressure() BackpressurePolicy {
+	switch p {
+	case SubscriptionDropNewest:
+		return BackpressureDropNewest
+	case SubscriptionLatestOnly:
+		return BackpressureLatestOnly
+	case SubscriptionBlock:
+		return BackpressureBlock
+	default:
+		return BackpressureDropOldest
+	}
+}
+
+// SubscribeOptions configure a FrameSubscription created by Subscribe.
+type SubscribeOptions struct {
+	// Name identifies the subscriber in FrameProviderStats, default is subscriber-<n>.
+	Name string
+	// Depth is the buffer size of the channel, default is DefaultFrameChannelDepth. It is ignored for SubscriptionLatestOnly.
+	Depth int
+	// Policy is used when the channel is full, default is SubscriptionDropOldest.
+	Policy SubscriptionPolicy
+	// MaxFPS decimates the frames to at most this rate based on their timestamps, 0 delivers every frame.
+	// Decimating H.264 or H.265 frames breaks decoding until the next keyframe, it is meant for raw frames.
+	MaxFPS float64
+}
+
+// FrameSubscription is an independent consumer of the frames of a FrameProvider, next to FrameStreamChannel.
+// All subscribers receive the same frames, they are shared and must not be modified.
+type FrameSubscription struct {
+	Name         string
+	Backpressure BackpressurePolicy
+	MaxFPS       float64
+	fp           *FrameProvider
+	frames       chan *axvdo.VideoFrame
+	done         chan struct{} // Closed by Unsubscribe to abandon a blocking delivery.
+	mu           sync.Mutex    // Serializes the delivery with Unsubscribe, which closes frames.
+	closed       bool
+	interval     time.Duration
+	next         time.Time // Earliest timestamp of the next frame when decimating.
+	stats        frameDeliveryStats
+	decimated    atomic.Uint64
+}
+
+// SubscriberStats are the delivery counters of a FrameSubscription.
+type SubscriberStats struct {
+	Name                string             // The name of the subscriber.
+	ChannelBufferLen    int                // The current length of the channel buffer.
+	ChannelDepth        int                // The capacity of the channel buffer.
+	Backpressure        BackpressurePolicy // The policy used when the channel is full.
+	MaxFPS              float64            // The decimation rate, 0 if every frame is delivered.
+	DeliveredFrames     uint64             // The number of frames handed to the channel.
+	DroppedFrames       uint64             // The total number of frames dropped because the subscriber was too slow.
+	DroppedOldestFrames uint64             // The number of queued frames discarded in favour of newer ones.
+	DroppedNewestFrames uint64             // The number of new frames discarded because the channel was full.
+	DecimatedFrames     uint64             // The number of frames skipped to keep MaxFPS.
+}
+
+// frameSubscriptions holds the subscriptions of a FrameProvider.
+type frameSubscriptions struct {
+	mu     sync.Mutex
+	nextID uint64
+	subs   []*FrameSubscription // In the order they subscribed.
+}
+
+// Subscribe returns a new consumer of the frames of the FrameProvider with its own channel, backpressure policy and frame rate.
+// Unlike FrameStreamChannel, where consumers steal frames from each other, every subscriber receives every frame.
+// The subscription survives restarts of the FrameProvider until Unsubscribe is called.
+//
+// Example:
+//
+//	sub := app.FrameProvider.Subscribe(acapapp.SubscribeOptions{Name: "preview", Policy: acapapp.SubscriptionLatestOnly, MaxFPS: 5})
+//	defer sub.Unsubscribe()
+//	for frame := range sub.Frames() {
+//		...
+//	}
+func (fp *FrameProvider) Subscribe(opts SubscribeOptions) *FrameSubscription {
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = DefaultFrameChannelDepth
+	}
+	policy := opts.Policy.backpressure()
+	if policy == BackpressureLatestOnly {
+		depth = 1
+	}
+	s := &FrameSubscription{
+		Name:         opts.Name,
+		Backpressure: policy,
+		MaxFPS:       opts.MaxFPS,
+		fp:           fp,
+		frames:       make(chan *axvdo.VideoFrame, depth),
+		done:         make(chan struct{}),
+	}
+	if opts.MaxFPS > 0 {
+		s.interval = time.Duration(float64(time.Second) / opts.MaxFPS)
+	}
+
+	fp.subscriptions.mu.Lock()
+	defer fp.subscriptions.mu.Unlock()
+	fp.subscriptions.nextID++
+	if s.Name == "" {
+		s.Name = fmt.Sprintf("subscriber-%d", fp.subscriptions.nextID)
+	}
+	fp.subscriptions.subs = append(fp.subscriptions.subs, s)
+	return s
+}
+
+// Frames returns the channel of the subscription, it is closed by Unsubscribe.
+func (s *FrameSubscription) Frames() <-chan *axvdo.VideoFrame {
+	return s.frames
+}
+
+// Unsubscribe stops the delivery and closes the channel. Calling it more than once has no effect.
+func (s *FrameSubscription) Unsubscribe() {
+	s.fp.subscriptions.mu.Lock()
+	found := false
+	for i, sub := range s.fp.subscriptions.subs {
+		if sub == s {
+			s.fp.subscriptions.subs = append(s.fp.subscriptions.subs[:i:i], s.fp.subscriptions.subs[i+1:]...)
+			found = true
+			break
+		}
+	}
+	s.fp.subscriptions.mu.Unlock()
+	if !found {
+		return
+	}
+	close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	close(s.frames)
+}
+
+// Stats returns the delivery counters of the subscription.
+func (s *FrameSubscription) Stats() SubscriberStats {
+	droppedOldest := s.stats.droppedOldest.Load()
+	droppedNewest := s.stats.droppedNewest.Load()
+	return SubscriberStats{
+		Name:                s.Name,
+		ChannelBufferLen:    len(s.frames),
+		ChannelDepth:        cap(s.frames),
+		Backpressure:        s.Backpressure,
+		MaxFPS:              s.MaxFPS,
+		DeliveredFrames:     s.stats.delivered.Load(),
+		DroppedFrames:       droppedOldest + droppedNewest,
+		DroppedOldestFrames: droppedOldest,
+		DroppedNewestFrames: droppedNewest,
+		DecimatedFrames:     s.decimated.Load(),
+	}
+}
+
+// deliver hands the frame to the subscription according to its policy, unknown policies drop the oldest frame.
+// A blocking delivery is abandoned when stop is closed or the subscription ends.
+func (s *FrameSubscription) deliver(frame *axvdo.VideoFrame, stop chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if s.decimate(frame.Timestamp) {
+		s.decimated.Add(1)
+		return
+	}
+	switch s.Backpressure {
+	case BackpressureBlock:
+		select {
+		case s.frames <- frame:
+		case <-stop:
+			return
+		case <-s.done:
+			return
+		}
+	case BackpressureDropNewest:
+		select {
+		case s.frames <- frame:
+		default:
+			s.stats.droppedNewest.Add(1)
+			return
+		}
+	default:
+		for sent := false; !sent; {
+			select {
+			case s.frames <- frame:
+				sent = true
+			default:
+				select {
+				case <-s.frames:
+					s.stats.droppedOldest.Add(1)
+				default:
+				}
+			}
+		}
+	}
+	s.stats.delivered.Add(1)
+}
+
+// decimate reports whether a frame with the timestamp is skipped to keep MaxFPS.
+// A quarter of the interval is tolerated, so the jitter of the capture timestamps does not halve the rate.
+func (s *FrameSubscription) decimate(timestamp time.Time) bool {
+	if s.interval == 0 {
+		return false
+	}
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	if !s.next.IsZero() && timestamp.Before(s.next.Add(-s.interval/4)) {
+		return true
+	}
+	s.next = s.next.Add(s.interval)
+	if s.next.Before(timestamp) {
+		// First frame or a gap in the stream, restart the schedule.
+		s.next = timestamp.Add(s.interval)
+	}
+	return false
+}
+
+// publish hands the frame to all subscriptions, in the order they subscribed.
+func (ss *frameSubscriptions) publish(frame *axvdo.VideoFrame, stop chan struct{}) {
+	for _, s := range ss.list() {
+		s.deliver(frame, stop)
+	}
+}
+
+// list returns the current subscriptions. Unsubscribe replaces the slice instead of modifying it,
+// so it can be used without holding the lock.
+func (ss *frameSubscriptions) list() []*FrameSubscription {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.subs
+}
+
+// stats returns the counters of all subscriptions.
+func (ss *frameSubscriptions) stats() []SubscriberStats {
+	subs := ss.list()
+	stats := make([]SubscriberStats, len(subs))
+	for i, s := range subs {
+		stats[i] = s.Stats()
+	}
+	return stats
+}
@@ -0,0 +1,50 @@
+package acapapp
+
+import (
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/glib"
+)
+
+// Every calls fn every interval on the main loop, so it does not race with event and parameter callbacks.
+// The returned source can be removed to stop the calls, otherwise it is removed when the application closes.
+func (a *AcapApplication) Every(interval time.Duration, fn func()) *glib.Source {
+	return a.trackSource(glib.TimeoutAdd(interval, func() bool {
+		fn()
+		return true
+	}))
+}
+
+// After calls fn once on the main loop after d.
+// The returned source can be removed to cancel the call, pending calls are cancelled when the application closes.
+func (a *AcapApplication) After(d time.Duration, fn func()) *glib.Source {
+	return a.trackSource(glib.TimeoutAdd(d, func() bool {
+		fn()
+		return false
+	}))
+}
+
+// trackSource remembers the source for removal on shutdown and forgets sources that are no longer active.
+func (a *AcapApplication) trackSource(source *glib.Source) *glib.Source {
+	a.sourcesMu.Lock()
+	defer a.sourcesMu.Unlock()
+	active := a.sources[:0]
+	for _, s := range a.sources {
+		if s.IsActive() {
+			active = append(active, s)
+		}
+	}
+	a.sources = append(active, source)
+	return source
+}
+
+// removeSources removes all sources created with Every and After.
+func (a *AcapApplication) removeSources() {
+	a.sourcesMu.Lock()
+	sources := a.sources
+	a.sources = nil
+	a.sourcesMu.Unlock()
+	for _, s := range sources {
+		s.Remove()
+	}
+}
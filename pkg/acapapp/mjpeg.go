@@ -0,0 +1,141 @@
+package acapapp
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Cacsjep/goxis/pkg/axlarod"
+	"github.com/Cacsjep/goxis/pkg/axvdo"
+	"github.com/Cacsjep/goxis/pkg/axvdo/vdoimage"
+)
+
+const (
+	// DefaultMJPEGQuality is the JPEG quality used to encode RGB and YUV frames for an MJPEG stream.
+	DefaultMJPEGQuality = 80
+	mjpegBoundary       = "mjpegframe"
+)
+
+// MJPEGOptions configure an MJPEG stream served by MJPEGHandler.
+type MJPEGOptions struct {
+	// MaxFPS limits the frame rate sent to each client, 0 sends every frame. A client can request a lower rate
+	// with the query parameter fps, for example ?fps=2.
+	MaxFPS float64
+	// Quality is the JPEG quality [1:100] used for frames that are not JPEG, default is DefaultMJPEGQuality.
+	Quality int
+}
+
+// MJPEGHandler returns a handler that streams the frames of the FrameProvider as multipart/x-mixed-replace,
+// which browsers show in an img tag. JPEG frames are sent as they are, RGB frames of SetLarodPostProccessor
+// and RGB, planar RGB or YUV streams are encoded to JPEG in Go, so the stream shows what a model sees.
+//
+// Every client gets its own FrameSubscription with SubscriptionLatestOnly, a slow client skips frames
+// and never blocks FrameStreamChannel. The stream ends when the client disconnects or the server shuts down.
+func (fp *FrameProvider) MJPEGHandler(opts MJPEGOptions) http.Handler {
+	opts = opts.normalized()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fp.serveMJPEG(w, r, opts)
+	})
+}
+
+// normalized returns the options with defaults for unset values.
+func (o MJPEGOptions) normalized() MJPEGOptions {
+	if o.Quality <= 0 || o.Quality > 100 {
+		o.Quality = DefaultMJPEGQuality
+	}
+	return o
+}
+
+func (fp *FrameProvider) serveMJPEG(w http.ResponseWriter, r *http.Request, opts MJPEGOptions) {
+	fps := opts.MaxFPS
+	if v := r.URL.Query().Get("fps"); v != "" {
+		requested, err := strconv.ParseFloat(v, 64)
+		if err != nil || requested <= 0 {
+			http.Error(w, "Invalid fps", http.StatusBadRequest)
+			return
+		}
+		if fps == 0 || requested < fps {
+			fps = requested
+		}
+	}
+	sub := fp.Subscribe(SubscribeOptions{Name: "mjpeg " + r.RemoteAddr, Policy: SubscriptionLatestOnly, MaxFPS: fps})
+	defer sub.Unsubscribe()
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case frame := <-sub.Frames():
+			if frame.Error != nil {
+				continue
+			}
+			data, err := fp.frameJPEG(frame, opts.Quality)
+			if err != nil {
+				fp.app.Syslog.Errorf("VDO Channel(%d): MJPEG stream ends: %s", fp.Config.GetChannel(), err.Error())
+				return
+			}
+			if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", mjpegBoundary, len(data)); err != nil {
+				return
+			}
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			if _, err := io.WriteString(w, "\r\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// frameJPEG returns the frame as JPEG, encoding RGB and YUV frames with the given quality.
+func (fp *FrameProvider) frameJPEG(frame *axvdo.VideoFrame, quality int) ([]byte, error) {
+	data := frame.Data
+	if int(frame.Size) <= len(data) {
+		data = data[:frame.Size]
+	}
+	if frame.Type == axvdo.VdoFrameTypeJPEG {
+		return data, nil
+	}
+
+	img := vdoimage.Frame{Data: data}
+	if fp.Config.Width != nil && fp.Config.Height != nil {
+		img.Width, img.Height = *fp.Config.Width, *fp.Config.Height
+	}
+	switch frame.Type {
+	case axvdo.VdoFrameTypeRGB:
+		img.Format = vdoimage.FormatRGB
+		if fp.PostProcessModel != nil && fp.outReso != nil {
+			img.Width, img.Height = fp.outReso.Width, fp.outReso.Height
+			if fp.rgbMode == axlarod.PreProccessOutputFormatRgbPlanar {
+				img.Format = vdoimage.FormatPlanarRGB
+			}
+		}
+	case axvdo.VdoFrameTypePlanarRGB:
+		img.Format = vdoimage.FormatPlanarRGB
+	case axvdo.VdoFrameTypeYUV:
+		img.Format = vdoimage.FormatNV12
+	default:
+		return nil, fmt.Errorf("frame type %s can not be encoded to JPEG", frame.Type)
+	}
+	decoded, err := img.Image()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, decoded, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
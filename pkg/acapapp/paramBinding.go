@@ -0,0 +1,342 @@
+package acapapp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParamChange describes an update of a parameter bound by BindParams.
+type ParamChange struct {
+	Name  string // Name of the parameter.
+	Field string // Name of the struct field the parameter is bound to.
+	Value string // The new raw value of the parameter.
+	Err   error  // Set if the value was rejected, the field then keeps its previous value.
+}
+
+// ParamBindOption configures BindParams.
+type ParamBindOption func(*paramBinding)
+
+// WithParamChangeHandler registers a callback that is invoked after a bound parameter changed or a change was rejected.
+func WithParamChangeHandler(handler func(ParamChange)) ParamBindOption {
+	return func(pb *paramBinding) {
+		pb.onChange = handler
+	}
+}
+
+// WithParamLocker sets a lock that is held while a field is updated on a parameter change.
+// Hold the same lock when reading the struct from other goroutines.
+func WithParamLocker(locker sync.Locker) ParamBindOption {
+	return func(pb *paramBinding) {
+		pb.locker = locker
+	}
+}
+
+type paramBinding struct {
+	app      *AcapApplication
+	onChange func(ParamChange)
+	locker   sync.Locker
+}
+
+// boundParam is a struct field bound to an application parameter.
+type boundParam struct {
+	name       string
+	field      reflect.StructField
+	value      reflect.Value
+	defaultVal string
+	min        *float64
+	max        *float64
+	ptype      string
+	lastValid  string
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// BindParams binds the fields of the struct cfg points to with application parameters.
+// Fields are bound with the struct tag param, which holds the parameter name, and the optional tags:
+//   - default: the initial value of a parameter that does not exist yet.
+//   - min, max: the allowed range of numeric values.
+//   - type: the parameter type used when the parameter is added, by default derived from the field type.
+//
+// Missing parameters are added with AXParameter.Add, existing ones are read and validated.
+// Fields are updated whenever the parameter changes, invalid values are rejected and the parameter is reset to the last valid value.
+// Supported field types are string, bool, all int, uint and float types and time.Duration.
+//
+// Example:
+//
+//	type Config struct {
+//		Threshold float64 `param:"Threshold" default:"0.5" min:"0" max:"1"`
+//		Enabled   bool    `param:"Enabled" default:"yes"`
+//	}
+func (a *AcapApplication) BindParams(cfg any, opts ...ParamBindOption) error {
+	rv := reflect.ValueOf(cfg)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("BindParams expects a non-nil pointer to a struct")
+	}
+
+	if a.ParamHandler == nil {
+		return errors.New("Parameter handler is not initialized")
+	}
+
+	pb := &paramBinding{app: a}
+	for _, opt := range opts {
+		opt(pb)
+	}
+
+	params, err := parseBoundParams(rv.Elem())
+	if err != nil {
+		return err
+	}
+
+	for _, p := range params {
+		value, err := a.ParamHandler.Get(p.name)
+		if err != nil {
+			value = p.defaultVal
+			if value == "" {
+				value = formatParamValue(p.value)
+			}
+			if err := a.ParamHandler.Add(p.name, value, p.ptype); err != nil {
+				return fmt.Errorf("Unable to add parameter %s: %w", p.name, err)
+			}
+		}
+		if err := p.set(value); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range params {
+		p := p
+		if err := a.ParamHandler.RegisterCallback(p.name, func(name string, value string, userdata any) {
+			pb.update(p, value)
+		}, nil); err != nil {
+			return fmt.Errorf("Unable to register callback for parameter %s: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+func (pb *paramBinding) update(p *boundParam, value string) {
+	if pb.locker != nil {
+		pb.locker.Lock()
+	}
+	previous := p.lastValid
+	err := p.set(value)
+	if pb.locker != nil {
+		pb.locker.Unlock()
+	}
+
+	if err != nil {
+		pb.app.Syslog.Warnf("Rejected value %q of parameter %s: %s", value, p.name, err.Error())
+		go func() {
+			if err := pb.app.ParamHandler.Set(p.name, previous, true); err != nil {
+				pb.app.Syslog.Errorf("Unable to reset parameter %s: %s", p.name, err.Error())
+			}
+		}()
+	}
+	if pb.onChange != nil {
+		pb.onChange(ParamChange{Name: p.name, Field: p.field.Name, Value: value, Err: err})
+	}
+}
+
+func parseBoundParams(sv reflect.Value) ([]*boundParam, error) {
+	var params []*boundParam
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		name, ok := field.Tag.Lookup("param")
+		if !ok || name == "-" {
+			continue
+		}
+		if !field.IsExported() {
+			return nil, fmt.Errorf("Field %s with parameter %s is not exported", field.Name, name)
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		p := &boundParam{
+			name:       name,
+			field:      field,
+			value:      sv.Field(i),
+			defaultVal: field.Tag.Get("default"),
+		}
+		if !isSupportedParamKind(field.Type) {
+			return nil, fmt.Errorf("Field %s has unsupported type %s for parameter %s", field.Name, field.Type, name)
+		}
+		var err error
+		if p.min, err = parseLimitTag(field, "min"); err != nil {
+			return nil, err
+		}
+		if p.max, err = parseLimitTag(field, "max"); err != nil {
+			return nil, err
+		}
+		p.ptype = field.Tag.Get("type")
+		if p.ptype == "" {
+			p.ptype = defaultParamType(field.Type, p.min, p.max)
+		}
+		if p.defaultVal != "" {
+			if _, err := p.parse(p.defaultVal); err != nil {
+				return nil, fmt.Errorf("Invalid default of parameter %s: %w", name, err)
+			}
+		}
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+func parseLimitTag(field reflect.StructField, tag string) (*float64, error) {
+	s, ok := field.Tag.Lookup(tag)
+	if !ok {
+		return nil, nil
+	}
+	var limit float64
+	var err error
+	if field.Type == durationType {
+		var d time.Duration
+		d, err = time.ParseDuration(s)
+		limit = float64(d)
+	} else {
+		limit, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s tag %q on field %s: %w", tag, s, field.Name, err)
+	}
+	return &limit, nil
+}
+
+func isSupportedParamKind(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// defaultParamType returns the axparameter type for a field, integers with limits get a ranged int type.
+func defaultParamType(t reflect.Type, lower *float64, upper *float64) string {
+	if t == durationType {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool:no,yes"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var limits []string
+		if lower != nil {
+			limits = append(limits, fmt.Sprintf("min=%d", int64(*lower)))
+		}
+		if upper != nil {
+			limits = append(limits, fmt.Sprintf("max=%d", int64(*upper)))
+		}
+		if len(limits) > 0 {
+			return "int:" + strings.Join(limits, ",")
+		}
+		return "int"
+	default:
+		return "string"
+	}
+}
+
+// parse converts a raw parameter value into a value of the field type and checks its limits.
+func (p *boundParam) parse(raw string) (reflect.Value, error) {
+	t := p.field.Type
+	v := reflect.New(t).Elem()
+	raw = strings.TrimSpace(raw)
+
+	var number float64
+	switch {
+	case t == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(int64(d))
+		number = float64(d)
+	case t.Kind() == reflect.String:
+		v.SetString(raw)
+		return v, nil
+	case t.Kind() == reflect.Bool:
+		b, err := parseParamBool(raw)
+		if err != nil {
+			return v, err
+		}
+		v.SetBool(b)
+		return v, nil
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(i)
+		number = float64(i)
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(u)
+		number = float64(u)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetFloat(f)
+		number = f
+	}
+
+	if p.min != nil && number < *p.min {
+		return v, fmt.Errorf("value %s is below the minimum of %s", raw, p.field.Tag.Get("min"))
+	}
+	if p.max != nil && number > *p.max {
+		return v, fmt.Errorf("value %s is above the maximum of %s", raw, p.field.Tag.Get("max"))
+	}
+	return v, nil
+}
+
+// set parses and validates the raw value and assigns it to the field.
+func (p *boundParam) set(raw string) error {
+	v, err := p.parse(raw)
+	if err != nil {
+		return fmt.Errorf("Invalid value %q for parameter %s: %w", raw, p.name, err)
+	}
+	p.value.Set(v)
+	p.lastValid = raw
+	return nil
+}
+
+func parseParamBool(raw string) (bool, error) {
+	switch strings.ToLower(raw) {
+	case "yes", "true", "1", "on":
+		return true, nil
+	case "no", "false", "0", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not a boolean", raw)
+}
+
+// formatParamValue returns the parameter representation of the current field value.
+func formatParamValue(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return "yes"
+		}
+		return "no"
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
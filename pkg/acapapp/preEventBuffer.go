@@ -0,0 +1,289 @@
+package acapapp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo"
+)
+
+const (
+	// DefaultPreEventDuration is the pre-roll kept by a PreEventBuffer without a configured Duration.
+	DefaultPreEventDuration = 10 * time.Second
+	// DefaultTailDepth is the number of frames a Tail queues for a slow sink before it fails with ErrTailOverflow.
+	DefaultTailDepth = 120
+)
+
+var (
+	ErrPreEventBufferClosed = errors.New("pre-event buffer is closed")
+	ErrTailOverflow         = errors.New("tail sink does not keep up with the frame rate")
+)
+
+// PreEventBufferConfig configures the caps of a PreEventBuffer.
+type PreEventBufferConfig struct {
+	// Duration is the pre-roll to keep, default is DefaultPreEventDuration. Because the buffer starts at a keyframe
+	// it holds up to one GOP more than Duration.
+	Duration time.Duration
+	// MaxBytes caps the memory used by the frame data, 0 disables the cap. Oldest GOPs are dropped first,
+	// if the current GOP alone exceeds the cap the buffer is cleared until the next keyframe.
+	MaxBytes int
+}
+
+// TailOptions define what a Tail writes and when it stops. Tail stops at the first condition reached.
+type TailOptions struct {
+	PreRoll   bool                         // Write the keyframe aligned Snapshot before the post-trigger frames.
+	Duration  time.Duration                // Stop after the post-trigger frames cover this duration from the first one, 0 disables it.
+	MaxFrames int                          // Stop after this number of post-trigger frames, 0 disables it.
+	Until     func(*axvdo.VideoFrame) bool // Stop when Until returns true, the frame is not written to the sink.
+	Depth     int                          // Frames queued for a slow sink, default is DefaultTailDepth.
+}
+
+// PreEventBuffer keeps the most recent encoded frames in memory, always starting at a keyframe,
+// so an event recording can include the video before the trigger.
+// Feed it with Add from a frame consumer or with Feed from a frame channel, for example the FrameStreamChannel of a FrameProvider.
+// Frames of H.264 and H.265 streams are aligned to IDR frames, for other formats every frame is a keyframe.
+type PreEventBuffer struct {
+	config PreEventBufferConfig
+	mu     sync.Mutex
+	frames []*axvdo.VideoFrame
+	bytes  int
+	tails  map[*preEventTail]struct{}
+	closed bool
+}
+
+// preEventTail is a running Tail, frames are handed over without blocking the feeder.
+type preEventTail struct {
+	frames   chan *axvdo.VideoFrame
+	overflow chan struct{}
+}
+
+// NewPreEventBuffer creates an empty PreEventBuffer with the given caps.
+func NewPreEventBuffer(config PreEventBufferConfig) *PreEventBuffer {
+	if config.Duration <= 0 {
+		config.Duration = DefaultPreEventDuration
+	}
+	return &PreEventBuffer{config: config, tails: make(map[*preEventTail]struct{})}
+}
+
+// Add appends a frame to the buffer and hands it to the running tails, it never blocks.
+// Frames with an error and frames before the first keyframe are ignored.
+func (b *PreEventBuffer) Add(frame *axvdo.VideoFrame) {
+	if frame == nil || frame.Error != nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	for t := range b.tails {
+		select {
+		case t.frames <- frame:
+		default:
+			close(t.overflow)
+			delete(b.tails, t)
+		}
+	}
+
+	if !isKeyframe(frame) && len(b.frames) == 0 {
+		return
+	}
+	b.frames = append(b.frames, frame)
+	b.bytes += frameBytes(frame)
+	b.trim()
+}
+
+// Feed adds all frames of the channel until it is closed or ctx is done.
+func (b *PreEventBuffer) Feed(ctx context.Context, frames <-chan *axvdo.VideoFrame) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case frame, ok := <-frames:
+			if !ok {
+				return
+			}
+			b.Add(frame)
+		}
+	}
+}
+
+// Snapshot returns the buffered frames, starting at a keyframe. The frames are shared and must not be modified.
+func (b *PreEventBuffer) Snapshot() []*axvdo.VideoFrame {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]*axvdo.VideoFrame{}, b.frames...)
+}
+
+// Duration returns the time covered by the buffered frames.
+func (b *PreEventBuffer) Duration() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.frames) == 0 {
+		return 0
+	}
+	return b.frames[len(b.frames)-1].Timestamp.Sub(b.frames[0].Timestamp)
+}
+
+// Bytes returns the size of the buffered frame data.
+func (b *PreEventBuffer) Bytes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bytes
+}
+
+// Reset drops all buffered frames, buffering starts again at the next keyframe.
+func (b *PreEventBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.frames = nil
+	b.bytes = 0
+}
+
+// Close drops the buffered frames and ends all running tails with ErrPreEventBufferClosed.
+func (b *PreEventBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	b.frames = nil
+	b.bytes = 0
+	for t := range b.tails {
+		close(t.frames)
+		delete(b.tails, t)
+	}
+}
+
+// Tail writes the frames added after the call to sink until a stop condition of opts is reached, ctx is done,
+// the sink returns an error or the buffer is closed. With PreRoll the Snapshot is written first, no frame is
+// lost or written twice between the snapshot and the post-trigger frames.
+// The sink runs in the goroutine of Tail, if it does not keep up Tail fails with ErrTailOverflow instead of blocking the feeder.
+func (b *PreEventBuffer) Tail(ctx context.Context, sink func(*axvdo.VideoFrame) error, opts TailOptions) error {
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = DefaultTailDepth
+	}
+	t := &preEventTail{frames: make(chan *axvdo.VideoFrame, depth), overflow: make(chan struct{})}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrPreEventBufferClosed
+	}
+	var preRoll []*axvdo.VideoFrame
+	if opts.PreRoll {
+		preRoll = append(preRoll, b.frames...)
+	}
+	b.tails[t] = struct{}{}
+	b.mu.Unlock()
+	defer b.removeTail(t)
+
+	for _, frame := range preRoll {
+		if err := sink(frame); err != nil {
+			return err
+		}
+	}
+
+	// The frame timestamps come from the capture clock of VDO, so the duration is measured between frames
+	// and not against the wall clock.
+	var first time.Time
+	written := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.overflow:
+			return ErrTailOverflow
+		case frame, ok := <-t.frames:
+			if !ok {
+				return ErrPreEventBufferClosed
+			}
+			if opts.Until != nil && opts.Until(frame) {
+				return nil
+			}
+			if opts.Duration > 0 && !frame.Timestamp.IsZero() {
+				if first.IsZero() {
+					first = frame.Timestamp
+				}
+				if frame.Timestamp.Sub(first) >= opts.Duration {
+					return nil
+				}
+			}
+			if err := sink(frame); err != nil {
+				return err
+			}
+			written++
+			if opts.MaxFrames > 0 && written >= opts.MaxFrames {
+				return nil
+			}
+		}
+	}
+}
+
+func (b *PreEventBuffer) removeTail(t *preEventTail) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.tails, t)
+}
+
+// trim drops whole GOPs from the front while the rest still covers the configured duration,
+// and while the frame data exceeds MaxBytes.
+func (b *PreEventBuffer) trim() {
+	newest := b.frames[len(b.frames)-1].Timestamp
+	for {
+		next := b.nextKeyframe()
+		if next < 0 {
+			break
+		}
+		coversDuration := newest.Sub(b.frames[next].Timestamp) >= b.config.Duration
+		overCap := b.config.MaxBytes > 0 && b.bytes > b.config.MaxBytes
+		if !coversDuration && !overCap {
+			break
+		}
+		b.dropFront(next)
+	}
+	if b.config.MaxBytes > 0 && b.bytes > b.config.MaxBytes {
+		b.frames = nil
+		b.bytes = 0
+	}
+}
+
+// nextKeyframe returns the index of the second GOP, -1 if the buffer holds only one GOP.
+func (b *PreEventBuffer) nextKeyframe() int {
+	for i := 1; i < len(b.frames); i++ {
+		if isKeyframe(b.frames[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b *PreEventBuffer) dropFront(n int) {
+	for _, frame := range b.frames[:n] {
+		b.bytes -= frameBytes(frame)
+	}
+	clear(b.frames[:n])
+	b.frames = b.frames[n:]
+}
+
+// isKeyframe reports whether decoding can start at the frame.
+func isKeyframe(frame *axvdo.VideoFrame) bool {
+	switch axvdo.VdoFrameIsOfFormat(frame.Type) {
+	case axvdo.VdoFormatH264, axvdo.VdoFormatH265:
+		return frame.Type == axvdo.VdoFrameTypeH264IDR || frame.Type == axvdo.VdoFrameTypeH265IDR
+	default:
+		return true
+	}
+}
+
+func frameBytes(frame *axvdo.VideoFrame) int {
+	if int(frame.Size) <= len(frame.Data) {
+		return int(frame.Size)
+	}
+	return len(frame.Data)
+}
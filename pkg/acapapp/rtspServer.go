@@ -0,0 +1,217 @@
+package acapapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo"
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+	"github.com/Cacsjep/goxis/pkg/axvdo/rtsp"
+)
+
+// DefaultRTSPPort is the port of an RTSPServer without WithRTSPPort or WithRTSPReverseProxy.
+const DefaultRTSPPort = 8554
+
+// RTSPServer republishes H.264 and H.265 streams to RTSP clients like a VMS, see the package rtsp.
+// Streams are served at rtsp://<device>:<port>/<path>, the RTP packets are sent interleaved in the RTSP connection or over UDP.
+// The server has no authentication, every client that reaches the port can play all streams. Keep it on localhost
+// with WithRTSPReverseProxy or restrict the port with the firewall of the network.
+type RTSPServer struct {
+	*rtsp.Server
+	Address    string // Address of the listener, host:port or :port for all interfaces.
+	app        *AcapApplication
+	listener   net.Listener
+	mu         sync.Mutex
+	forwarders map[string]*FrameSubscription // Subscriptions of the frame providers added with AddFrameProvider by path.
+	done       chan struct{}                 // Closed by Stop to end the forwarding.
+}
+
+// RTSPServerOption configures an RTSPServer created by NewRTSPServer.
+type RTSPServerOption func(*RTSPServer) error
+
+// WithRTSPPort sets the TCP port of the server, default is DefaultRTSPPort.
+func WithRTSPPort(port int) RTSPServerOption {
+	return func(s *RTSPServer) error {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("Invalid RTSP port %d", port)
+		}
+		s.Address = ":" + strconv.Itoa(port)
+		return nil
+	}
+}
+
+// WithRTSPReverseProxy listens on the host and port of the target of the reverse proxy configuration with the given apiPath,
+// so a target like http://localhost:2001 is not reachable from the network directly.
+// The target must be a TCP address, the port must not be used by a WebServer as well.
+// The device does not authenticate the RTSP clients, see RTSPServer.
+func WithRTSPReverseProxy(apiPath string) RTSPServerOption {
+	return func(s *RTSPServer) error {
+		for _, rp := range s.app.Manifest.ACAPPackageConf.Configuration.ReverseProxy {
+			if rp.ApiPath != apiPath {
+				continue
+			}
+			network, address, err := ParseReverseProxyTarget(rp.Target)
+			if err != nil {
+				return err
+			}
+			if network != "tcp" {
+				return fmt.Errorf("Reverse proxy target %s is not a TCP address", rp.Target)
+			}
+			if _, _, err := net.SplitHostPort(address); err != nil {
+				return err
+			}
+			s.Address = address
+			return nil
+		}
+		return fmt.Errorf("No reverse proxy configuration with apiPath %s in manifest", apiPath)
+	}
+}
+
+// NewRTSPServer creates an RTSPServer, add streams with AddFrameProvider or AddStream.
+// The server is not listening until Start is called and is stopped when the application shuts down.
+func (a *AcapApplication) NewRTSPServer(opts ...RTSPServerOption) (*RTSPServer, error) {
+	s := &RTSPServer{
+		Server:     rtsp.NewServer(),
+		Address:    ":" + strconv.Itoa(DefaultRTSPPort),
+		app:        a,
+		forwarders: make(map[string]*FrameSubscription),
+		done:       make(chan struct{}),
+	}
+	s.Server.Name = a.Manifest.ACAPPackageConf.Setup.AppName
+	s.Server.Logf = a.Syslog.Warnf
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// AddFrameProvider serves the frames of a FrameProvider with an H.264 or H.265 stream under the path.
+// The frames are taken from a FrameSubscription, so FrameStreamChannel is still fed and a slow client never blocks it.
+// When the subscription drops frames the stream resumes at the next keyframe.
+// The stream keeps being served when the FrameProvider restarts.
+func (s *RTSPServer) AddFrameProvider(path string, fp *FrameProvider) (*rtsp.Stream, error) {
+	if fp.Config.Format == nil {
+		return nil, errors.New("FrameProvider has no video format")
+	}
+	codec, ok := axvdo.BitstreamCodec(*fp.Config.Format)
+	if !ok {
+		return nil, fmt.Errorf("FrameProvider %s is not an H.264 or H.265 stream", fp.Name)
+	}
+	stream, err := s.Server.AddStream(path, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := fp.Subscribe(SubscribeOptions{Name: "rtsp " + stream.Path, Policy: SubscriptionDropOldest})
+	s.mu.Lock()
+	s.forwarders[stream.Path] = sub
+	s.mu.Unlock()
+	go s.forward(stream, sub)
+	return stream, nil
+}
+
+// AddStream registers a stream for access units the application generates itself, write them with Stream.WriteAccessUnit.
+func (s *RTSPServer) AddStream(path string, codec bitstream.Codec) (*rtsp.Stream, error) {
+	return s.Server.AddStream(path, codec)
+}
+
+// RemoveStream unregisters the stream, ends its sessions and stops the forwarding of its FrameProvider.
+func (s *RTSPServer) RemoveStream(path string) {
+	stream, found := s.Server.GetStream(path)
+	if !found {
+		return
+	}
+	s.Server.RemoveStream(path)
+	s.mu.Lock()
+	sub, found := s.forwarders[stream.Path]
+	delete(s.forwarders, stream.Path)
+	s.mu.Unlock()
+	if found {
+		sub.Unsubscribe()
+	}
+}
+
+// forward writes the frames of the subscription to the stream until the server is stopped or the stream is removed.
+// Frames after a drop of the subscription reference the dropped ones, so they are skipped until the next keyframe.
+func (s *RTSPServer) forward(stream *rtsp.Stream, sub *FrameSubscription) {
+	defer sub.Unsubscribe()
+	var dropped uint64
+	waitKeyframe := false
+	for {
+		select {
+		case <-s.done:
+			return
+		case frame, ok := <-sub.Frames():
+			if !ok {
+				return
+			}
+			if frame.Error != nil || frame.Size == 0 {
+				continue
+			}
+			if d := sub.stats.droppedOldest.Load(); d != dropped {
+				dropped = d
+				waitKeyframe = true
+			}
+			if waitKeyframe && !isKeyframe(frame) {
+				continue
+			}
+			waitKeyframe = false
+			data := frame.Data
+			if int(frame.Size) <= len(data) {
+				data = data[:frame.Size]
+			}
+			if err := stream.WriteAccessUnit(data, frame.Timestamp); err != nil {
+				if errors.Is(err, rtsp.ErrStreamRemoved) {
+					return
+				}
+				s.app.Syslog.Warnf("RTSPServer(%s): %s", stream.Path, err.Error())
+			}
+		}
+	}
+}
+
+// Start listens on Address and serves RTSP clients in the background.
+func (s *RTSPServer) Start() error {
+	if s.listener != nil {
+		return errors.New("RTSPServer is already started")
+	}
+	listener, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	if err := s.app.AddCleaner(Cleaner{
+		Name:   "rtspserver" + s.Address,
+		Before: []string{CleanerFrameProviders},
+		Clean:  s.Stop,
+	}); err != nil {
+		s.app.Syslog.Warnf("RTSPServer(%s): %s", s.Address, err.Error())
+	}
+
+	go func() {
+		if err := s.Server.Serve(listener); err != nil && !errors.Is(err, rtsp.ErrServerClosed) {
+			s.app.Syslog.Errorf("RTSPServer(%s): %s", s.Address, err.Error())
+		}
+	}()
+	s.app.Syslog.Infof("RTSPServer: Listening on tcp %s", s.Address)
+	return nil
+}
+
+// Stop closes the listener and all sessions and stops the forwarding of the frame providers.
+func (s *RTSPServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	s.mu.Unlock()
+	return s.Server.Close()
+}
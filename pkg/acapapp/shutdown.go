@@ -0,0 +1,360 @@
+package acapapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultCleanerTimeout is the time a cleaner may take when it does not define its own Timeout.
+	DefaultCleanerTimeout = 10 * time.Second
+	// DefaultShutdownTimeout is the overall time a shutdown triggered by Run may take.
+	DefaultShutdownTimeout = 30 * time.Second
+
+	// ExitCodeOk is the exit code of an application that shut down without errors.
+	ExitCodeOk = 0
+	// ExitCodeShutdownFailed is the exit code of an application where at least one cleaner failed or timed out.
+	ExitCodeShutdownFailed = 1
+)
+
+// ErrShutdownInProgress is returned by Shutdown when it is called while the application is shutting down,
+// for example by a cleaner that calls Close.
+var ErrShutdownInProgress = errors.New("application is shutting down")
+
+// Names of the cleaners the AcapApplication registers itself, they can be used in Cleaner.Before and Cleaner.After.
+// The built-in cleaners run in this order: sources, events, frameproviders, larod, storage.
+// Cleaners without ordering constraints run after the frame providers are stopped and before larod is disconnected.
+const (
+	CleanerSources        = "sources"        // Removes all main loop sources created with Every and After.
+	CleanerEvents         = "events"         // Undeclares all events added by the application.
+	CleanerFrameProviders = "frameproviders" // Stops all frame providers.
+	CleanerLarod          = "larod"          // Disconnects from larod.
+	CleanerStorage        = "storage"        // Unsubscribes and releases all storages.
+)
+
+// Cleaner is a named function that releases a resource when the application shuts down.
+type Cleaner struct {
+	Name    string                          // Unique name of the cleaner, a name like cleaner-1 is generated if empty.
+	Clean   func(ctx context.Context) error // Releases the resource, ctx is cancelled when Timeout is exceeded.
+	Timeout time.Duration                   // Maximum duration of Clean, DefaultCleanerTimeout if 0.
+	Before  []string                        // Names of cleaners that must run after this cleaner.
+	After   []string                        // Names of cleaners that must run before this cleaner.
+}
+
+// AddCleaner registers a cleaner that runs on shutdown.
+// Cleaners run in registration order unless Before or After define a different order.
+func (a *AcapApplication) AddCleaner(c Cleaner) error {
+	if c.Clean == nil {
+		return errors.New("Cleaner has no clean function")
+	}
+
+	a.cleanersMu.Lock()
+	defer a.cleanersMu.Unlock()
+
+	if c.Name == "" {
+		c.Name = uniqueCleanerName("cleaner", a.cleaners)
+	}
+	if isBuiltinCleaner(c.Name) {
+		return fmt.Errorf("Cleaner name %s is reserved", c.Name)
+	}
+	if hasCleaner(a.cleaners, c.Name) {
+		return fmt.Errorf("Cleaner %s already exists", c.Name)
+	}
+	a.cleaners = append(a.cleaners, c)
+	return nil
+}
+
+func hasCleaner(cleaners []Cleaner, name string) bool {
+	for _, c := range cleaners {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueCleanerName returns the first name prefix-n that is not used by the cleaners, so a generated name
+// never collides with a name chosen by the application.
+func uniqueCleanerName(prefix string, cleaners []Cleaner) string {
+	for n := len(cleaners) + 1; ; n++ {
+		if name := fmt.Sprintf("%s-%d", prefix, n); !hasCleaner(cleaners, name) {
+			return name
+		}
+	}
+}
+
+func isBuiltinCleaner(name string) bool {
+	switch name {
+	case CleanerSources, CleanerEvents, CleanerFrameProviders, CleanerLarod, CleanerStorage:
+		return true
+	}
+	return false
+}
+
+// builtinCleaners returns the cleaners for the resources the AcapApplication manages itself.
+func (a *AcapApplication) builtinCleaners() (first []Cleaner, last []Cleaner) {
+	first = []Cleaner{
+		{Name: CleanerSources, Clean: func(ctx context.Context) error {
+			a.removeSources()
+			return nil
+		}},
+		{Name: CleanerEvents, After: []string{CleanerSources}, Clean: func(ctx context.Context) error {
+			if a.EventHandler == nil {
+				return nil
+			}
+			var errs []error
+			for _, declaration_id := range a.eventDeclarationIds {
+				if err := a.EventHandler.Undeclare(declaration_id); err != nil {
+					errs = append(errs, fmt.Errorf("undeclare event %d: %w", declaration_id, err))
+				}
+			}
+			a.eventDeclarationIds = nil
+			return errors.Join(errs...)
+		}},
+		{Name: CleanerFrameProviders, After: []string{CleanerEvents}, Clean: func(ctx context.Context) error {
+			a.StopFrameProviders()
+			return nil
+		}},
+	}
+	last = []Cleaner{
+		{Name: CleanerLarod, After: []string{CleanerFrameProviders}, Clean: func(ctx context.Context) error {
+			if a.Larod == nil {
+				return nil
+			}
+			return a.Larod.Disconnect()
+		}},
+		{Name: CleanerStorage, After: []string{CleanerLarod}, Clean: func(ctx context.Context) error {
+			if a.StorageProvider != nil {
+				a.StorageProvider.Close()
+			}
+			return nil
+		}},
+	}
+	return first, last
+}
+
+// shutdownOrder returns the cleaners sorted by their Before and After constraints.
+// Among cleaners without constraints between each other the given order is kept.
+// If the constraints contain a cycle the given order is returned together with an error.
+func shutdownOrder(cleaners []Cleaner) ([]Cleaner, error) {
+	index := make(map[string]int, len(cleaners))
+	for i, c := range cleaners {
+		index[c.Name] = i
+	}
+
+	// successors[i] holds the cleaners that must run after cleaner i.
+	successors := make([][]int, len(cleaners))
+	pending := make([]int, len(cleaners))
+	addEdge := func(from, to int) {
+		successors[from] = append(successors[from], to)
+		pending[to]++
+	}
+	for i, c := range cleaners {
+		for _, name := range c.Before {
+			if j, ok := index[name]; ok && j != i {
+				addEdge(i, j)
+			}
+		}
+		for _, name := range c.After {
+			if j, ok := index[name]; ok && j != i {
+				addEdge(j, i)
+			}
+		}
+	}
+
+	ordered := make([]Cleaner, 0, len(cleaners))
+	done := make([]bool, len(cleaners))
+	for len(ordered) < len(cleaners) {
+		next := -1
+		for i := range cleaners {
+			if !done[i] && pending[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			return cleaners, errors.New("Cleaner order contains a cycle, using registration order")
+		}
+		done[next] = true
+		ordered = append(ordered, cleaners[next])
+		for _, j := range successors[next] {
+			pending[j]--
+		}
+	}
+	return ordered, nil
+}
+
+// runCleaner runs a single cleaner and waits at most for its timeout or the cancellation of ctx.
+// A cleaner that does not return in time keeps running in the background, finished is false then.
+func runCleaner(ctx context.Context, c Cleaner) (finished bool, err error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCleanerTimeout
+	}
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		result <- c.Clean(cctx)
+	}()
+
+	select {
+	case err := <-result:
+		return true, err
+	case <-cctx.Done():
+		return false, cctx.Err()
+	}
+}
+
+// Shutdown runs all cleaners in dependency order, quits the main loop and releases the parameter handler,
+// event handler and syslog. Cleaners that are not reached before ctx is cancelled are skipped.
+// A cleaner that times out may still use the resources of the application, in that case larod, the storages
+// and the handlers are not released, the process is about to exit anyway.
+// It returns all cleaner errors joined together. Only the first call shuts down the application,
+// calls during the shutdown return ErrShutdownInProgress without waiting, so a cleaner may call Close,
+// calls after it return the same error.
+func (a *AcapApplication) Shutdown(ctx context.Context) error {
+	if a.shuttingDown.Swap(true) {
+		select {
+		case <-a.shutdownDone:
+			return a.shutdownErr
+		default:
+			return ErrShutdownInProgress
+		}
+	}
+	a.shutdownErr = a.shutdown(ctx)
+	close(a.shutdownDone)
+	return a.shutdownErr
+}
+
+func (a *AcapApplication) shutdown(ctx context.Context) error {
+	first, last := a.builtinCleaners()
+
+	a.cleanersMu.Lock()
+	cleaners := append([]Cleaner{}, first...)
+	cleaners = append(cleaners, a.cleaners...)
+	a.cleanersMu.Unlock()
+	for _, f := range a.OnCloseCleaners {
+		f := f
+		cleaners = append(cleaners, Cleaner{Name: uniqueCleanerName("close-func", cleaners), Clean: func(ctx context.Context) error {
+			f()
+			return nil
+		}})
+	}
+	cleaners = append(cleaners, last...)
+
+	var errs []error
+	cleaners, err := shutdownOrder(cleaners)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	leaked := false
+	for _, c := range cleaners {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("Cleaner %s skipped: %w", c.Name, ctx.Err()))
+			continue
+		}
+		if leaked && (c.Name == CleanerLarod || c.Name == CleanerStorage) {
+			errs = append(errs, fmt.Errorf("Cleaner %s skipped: a previous cleaner is still running", c.Name))
+			continue
+		}
+		finished, err := runCleaner(ctx, c)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Cleaner %s failed: %w", c.Name, err))
+		}
+		leaked = leaked || !finished
+	}
+
+	a.Mainloop.Quit() // Terminate the main loop.
+	if leaked {
+		errs = append(errs, errors.New("Parameter and event handler are not released, a cleaner is still running"))
+	} else {
+		if a.ParamHandler != nil {
+			a.ParamHandler.Free() // Release the parameter handler.
+		}
+		if a.EventHandler != nil {
+			a.EventHandler.Free() // Release the event handler.
+		}
+	}
+
+	shutdownErr := errors.Join(errs...)
+	if shutdownErr != nil {
+		for _, err := range errs {
+			a.Syslog.Errorf("%s", err.Error())
+		}
+		a.Syslog.Errorf("%s has shut down with errors.", a.Manifest.ACAPPackageConf.Setup.AppName)
+	} else {
+		a.Syslog.Info(fmt.Sprintf("%s has shut down gracefully.", a.Manifest.ACAPPackageConf.Setup.AppName))
+	}
+	a.Syslog.Close() // Close the syslog.
+	return shutdownErr
+}
+
+// Run runs the main loop until ctx is cancelled, a SIGTERM, SIGINT or SIGABRT is received or the application is closed.
+// A cancellation or signal shuts the application down within ShutdownTimeout, it returns the error of the shutdown.
+//
+// Example:
+//
+//	os.Exit(acapapp.ExitCode(app.Run(context.Background())))
+func (a *AcapApplication) Run(ctx context.Context) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGABRT)
+	defer signal.Stop(sigs)
+
+	loopDone := make(chan struct{})
+	go func() {
+		select {
+		case <-sigs:
+			a.signaled.Store(true)
+		case <-ctx.Done():
+		case <-loopDone:
+			return
+		}
+		a.shutdownWithTimeout()
+	}()
+
+	a.Mainloop.Run()
+	close(loopDone)
+
+	// The main loop was quit by Shutdown or directly, in both cases wait until the application is shut down.
+	a.shutdownWithTimeout()
+	<-a.shutdownDone
+	return a.shutdownErr
+}
+
+func (a *AcapApplication) shutdownWithTimeout() error {
+	timeout := a.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return a.Shutdown(ctx)
+}
+
+// ExitCode returns the process exit code for the error returned by Shutdown or Run.
+func ExitCode(err error) int {
+	if err != nil {
+		return ExitCodeShutdownFailed
+	}
+	return ExitCodeOk
+}
+
+// exitAfterSignal terminates the process if the application was shut down by a signal.
+func (a *AcapApplication) exitAfterSignal(err error) {
+	if a.signaled.Load() {
+		os.Exit(ExitCode(err))
+	}
+}
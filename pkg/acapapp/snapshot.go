@@ -0,0 +1,242 @@
+package acapapp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo"
+	"github.com/Cacsjep/goxis/pkg/axvdo/vdoimage"
+)
+
+// DefaultSnapshotCompression is the JPEG compression used to re-encode cropped snapshots when no compression is set.
+const DefaultSnapshotCompression = 30
+
+// SnapshotOptions describes a snapshot taken with GetSnapshotWithOptions.
+// Zero values keep the defaults of the video channel.
+type SnapshotOptions struct {
+	Channel     int                   // Video channel, 0 is overview, 1, 2, ... are view areas.
+	Format      axvdo.VdoFormat       // VdoFormatJPEG (default), VdoFormatYUV (NV12) or VdoFormatRGB (interleaved).
+	Width       int                   // Output width, raw formats use the highest channel resolution if width or height is 0.
+	Height      int                   // Output height.
+	Compression *int                  // JPEG compression [0:100], higher values mean smaller files with lower quality.
+	Rotation    *axvdo.StreamRotation // Image rotation, normally [0,90,180,270].
+	Crop        *axvdo.CropArea       // Area of the output image to keep, applied after the capture.
+}
+
+// Snapshot is a captured snapshot and its format.
+type Snapshot struct {
+	Data   []byte          // JPEG data or raw pixels, NV12 for YUV and interleaved 8-bit RGB for RGB.
+	Format axvdo.VdoFormat // Format of Data.
+	Width  int             // Width of the image in pixels.
+	Height int             // Height of the image in pixels.
+	Stride int             // Bytes per row of raw pixels, the chroma rows of NV12 have the same stride. 0 for JPEG.
+}
+
+// GetSnapshotWithOptions captures a snapshot from the video channel of opts.
+// The crop area is cut out in Go after the capture, for JPEG this means the snapshot is decoded and encoded again.
+func (a *AcapApplication) GetSnapshotWithOptions(opts SnapshotOptions) (*Snapshot, error) {
+	return a.snapshot(opts, true)
+}
+
+// snapshot captures a snapshot like GetSnapshotWithOptions. The JPEG header is only read for a crop or if dimensions is set,
+// otherwise Width and Height of a JPEG snapshot are the requested ones.
+func (a *AcapApplication) snapshot(opts SnapshotOptions, dimensions bool) (*Snapshot, error) {
+	format := opts.Format
+	if format == axvdo.VdoFormatNone {
+		format = axvdo.VdoFormatJPEG
+	}
+	if format != axvdo.VdoFormatJPEG && format != axvdo.VdoFormatYUV && format != axvdo.VdoFormatRGB {
+		return nil, fmt.Errorf("Unsupported snapshot format: %d", format)
+	}
+	if opts.Compression != nil && (*opts.Compression < 0 || *opts.Compression > 100) {
+		return nil, fmt.Errorf("Snapshot compression %d is not in range [0:100]", *opts.Compression)
+	}
+
+	width, height := opts.Width, opts.Height
+	if format != axvdo.VdoFormatJPEG && (width <= 0 || height <= 0) {
+		res, err := axvdo.GetVdoChannelMaxResolution(opts.Channel)
+		if err != nil {
+			return nil, err
+		}
+		width, height = res.Width, res.Height
+	}
+
+	settings := axvdo.NewVdoMap()
+	defer settings.Unref()
+	settings.SetUint32("channel", uint32(opts.Channel))
+	settings.SetUint32("format", uint32(format))
+	if width > 0 && height > 0 {
+		settings.SetUint32("width", uint32(width))
+		settings.SetUint32("height", uint32(height))
+	}
+	if opts.Compression != nil {
+		settings.SetUint32("compression", uint32(*opts.Compression))
+	}
+	if opts.Rotation != nil {
+		settings.SetUint32("rotation", uint32(*opts.Rotation))
+	}
+
+	buffer, err := axvdo.Snapshot(settings)
+	if err != nil {
+		return nil, err
+	}
+	defer buffer.Unref()
+
+	data, err := buffer.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+	// The buffer capacity may be larger than the frame, prefer the frame size if vdo reports one.
+	frameSize := false
+	if frame, err := buffer.GetFrame(); err == nil {
+		if size := int(frame.GetSize()); size > 0 && size <= len(data) {
+			data = data[:size]
+			frameSize = true
+		}
+	}
+
+	snap := &Snapshot{Data: data, Format: format, Width: width, Height: height}
+	if format == axvdo.VdoFormatJPEG {
+		if !dimensions && opts.Crop == nil {
+			return snap, nil
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read snapshot JPEG header: %w", err)
+		}
+		snap.Width, snap.Height = cfg.Width, cfg.Height
+	} else if snap.Stride, err = rawStride(format, width, height, len(data), frameSize); err != nil {
+		return nil, err
+	}
+
+	if opts.Crop != nil {
+		compression := DefaultSnapshotCompression
+		if opts.Compression != nil {
+			compression = *opts.Compression
+		}
+		if err := snap.crop(*opts.Crop, compression); err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
+
+// GetSnapshotImage captures a snapshot like GetSnapshotWithOptions and decodes it into an image.Image.
+// A JPEG snapshot decodes to an *image.YCbCr, a YUV snapshot to an *image.YCbCr and an RGB snapshot to an *image.RGBA.
+func (a *AcapApplication) GetSnapshotImage(opts SnapshotOptions) (image.Image, error) {
+	snap, err := a.GetSnapshotWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return snap.Image()
+}
+
+// Image decodes the snapshot into an image.Image.
+func (s *Snapshot) Image() (image.Image, error) {
+	switch s.Format {
+	case axvdo.VdoFormatJPEG:
+		return jpeg.Decode(bytes.NewReader(s.Data))
+	case axvdo.VdoFormatYUV:
+		return vdoimage.Frame{Format: vdoimage.FormatNV12, Width: s.Width, Height: s.Height, Stride: s.Stride, Data: s.Data}.Image()
+	case axvdo.VdoFormatRGB:
+		return vdoimage.Frame{Format: vdoimage.FormatRGB, Width: s.Width, Height: s.Height, Stride: s.Stride, Data: s.Data}.Image()
+	}
+	return nil, fmt.Errorf("Unsupported snapshot format: %d", s.Format)
+}
+
+// crop cuts the area out of the snapshot, JPEG snapshots are encoded again with the given compression.
+func (s *Snapshot) crop(area axvdo.CropArea, compression int) error {
+	if area.Width <= 0 || area.Height <= 0 || area.X < 0 || area.Y < 0 ||
+		area.X+area.Width > s.Width || area.Y+area.Height > s.Height {
+		return fmt.Errorf("Crop area %dx%d+%d+%d is outside of the %dx%d snapshot", area.Width, area.Height, area.X, area.Y, s.Width, s.Height)
+	}
+
+	switch s.Format {
+	case axvdo.VdoFormatJPEG:
+		img, err := jpeg.Decode(bytes.NewReader(s.Data))
+		if err != nil {
+			return err
+		}
+		sub, ok := img.(interface {
+			SubImage(r image.Rectangle) image.Image
+		})
+		if !ok {
+			return errors.New("Decoded snapshot does not support cropping")
+		}
+		var buf bytes.Buffer
+		rect := image.Rect(area.X, area.Y, area.X+area.Width, area.Y+area.Height).Add(img.Bounds().Min)
+		if err := jpeg.Encode(&buf, sub.SubImage(rect), &jpeg.Options{Quality: jpegQuality(compression)}); err != nil {
+			return err
+		}
+		s.Data = buf.Bytes()
+	case axvdo.VdoFormatYUV:
+		// Chroma is subsampled by two in both directions, keep the area on even coordinates.
+		area.X, area.Y = area.X&^1, area.Y&^1
+		area.Width, area.Height = area.Width&^1, area.Height&^1
+		if area.Width == 0 || area.Height == 0 {
+			return errors.New("Crop area of a YUV snapshot must be at least 2x2")
+		}
+		out := make([]byte, 0, rawFrameSize(s.Format, area.Width, area.Width, area.Height))
+		for y := area.Y; y < area.Y+area.Height; y++ {
+			off := y*s.Stride + area.X
+			out = append(out, s.Data[off:off+area.Width]...)
+		}
+		uv := s.Stride * s.Height
+		for y := area.Y / 2; y < (area.Y+area.Height)/2; y++ {
+			off := uv + y*s.Stride + area.X
+			out = append(out, s.Data[off:off+area.Width]...)
+		}
+		s.Data = out
+		s.Stride = area.Width
+	case axvdo.VdoFormatRGB:
+		out := make([]byte, 0, rawFrameSize(s.Format, area.Width*3, area.Width, area.Height))
+		for y := area.Y; y < area.Y+area.Height; y++ {
+			off := y*s.Stride + area.X*3
+			out = append(out, s.Data[off:off+area.Width*3]...)
+		}
+		s.Data = out
+		s.Stride = area.Width * 3
+	}
+	s.Width, s.Height = area.Width, area.Height
+	return nil
+}
+
+// rawFrameSize returns the number of bytes of a raw frame with the given stride.
+func rawFrameSize(format axvdo.VdoFormat, stride, width, height int) int {
+	if format == axvdo.VdoFormatYUV {
+		return stride*height + stride*((height+1)/2)
+	}
+	return stride * height
+}
+
+// rawStride returns the stride of a raw snapshot of size bytes. Padded rows are derived from the frame size reported by vdo,
+// a frame size that is no multiple of the rows is rejected. Without a frame size only the capacity is known and the rows are assumed packed.
+func rawStride(format axvdo.VdoFormat, width, height, size int, frameSize bool) (int, error) {
+	packed := width
+	rows := height + (height+1)/2
+	if format == axvdo.VdoFormatRGB {
+		packed = width * 3
+		rows = height
+	}
+	switch {
+	case size < rawFrameSize(format, packed, width, height):
+		return 0, fmt.Errorf("Snapshot has %d bytes, expected %d for %dx%d", size, rawFrameSize(format, packed, width, height), width, height)
+	case size == rawFrameSize(format, packed, width, height) || !frameSize:
+		return packed, nil
+	case size%rows == 0:
+		return size / rows, nil
+	}
+	return 0, fmt.Errorf("Snapshot has %d bytes, which is no row layout of %dx%d", size, width, height)
+}
+
+// jpegQuality converts an Axis compression value into a JPEG quality.
+func jpegQuality(compression int) int {
+	quality := 100 - compression
+	if quality < 1 {
+		return 1
+	}
+	return quality
+}
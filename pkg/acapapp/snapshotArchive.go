@@ -0,0 +1,374 @@
+package acapapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axevent"
+	"github.com/Cacsjep/goxis/pkg/axstorage"
+	"github.com/Cacsjep/goxis/pkg/axvdo"
+)
+
+const (
+	// DefaultSnapshotArchiveTemplate is the file name template of archived snapshots.
+	DefaultSnapshotArchiveTemplate = "{date}/{topic}/{timestamp}_{seq}.jpg"
+	// DefaultSnapshotArchiveRetries is the number of write retries when no disk is available.
+	DefaultSnapshotArchiveRetries = 3
+	// DefaultSnapshotArchiveRetryDelay is the delay between write retries.
+	DefaultSnapshotArchiveRetryDelay = 5 * time.Second
+)
+
+// SnapshotArchiveConfig describes which event triggers snapshots and where they are stored.
+//
+// The file name template supports these placeholders:
+//   - {date}: capture date as 2006-01-02.
+//   - {time}: capture time as 150405.
+//   - {timestamp}: capture time as Unix milliseconds.
+//   - {topic}: topics of the event joined with an underscore, or the archive name if the trigger has no topics.
+//   - {seq}: zero padded sequence number of the snapshot, it starts at 1 when the archive is created.
+//   - {index}: number of the snapshot within one trigger, starting at 1.
+//   - {channel}: video channel of the snapshot.
+//
+// Existing files are replaced. {seq} starts again with every application run, keep {timestamp} in the template
+// so the snapshots of different runs do not overwrite each other.
+type SnapshotArchiveConfig struct {
+	Name        string                   // Name of the archive, used for {topic} and the cleaner name.
+	Trigger     any                      // A struct with eventKey tags like axevent.VideoSourceMotionAlarmEvent or an *axevent.AXEventKeyValueSet like axevent.VideoSourceMotionAlarmEventKvs, which is freed.
+	SidecarKeys []axevent.KeyValueEntrie // Additional event keys written to the sidecar, only Key and Namespace are used.
+	Snapshot    SnapshotOptions          // Snapshot settings, the format must be JPEG.
+	Count       int                      // Number of snapshots per trigger, 1 if 0.
+	Interval    time.Duration            // Time between the snapshots of one trigger.
+	Disks       []axstorage.StorageId    // Disks in order of preference, any set up disk is used if empty.
+	Template    string                   // File name template relative to the storage path, DefaultSnapshotArchiveTemplate if empty.
+	SkipSidecar bool                     // Do not write a JSON sidecar next to each snapshot.
+	Retries     int                      // Write retries when no disk is available, DefaultSnapshotArchiveRetries if 0, -1 disables retries.
+	RetryDelay  time.Duration            // Delay between retries, DefaultSnapshotArchiveRetryDelay if 0.
+}
+
+// SnapshotSidecar is the content of the JSON file written next to each archived snapshot.
+type SnapshotSidecar struct {
+	Topic       string         `json:"topic"`
+	Disk        string         `json:"disk"`
+	Seq         uint64         `json:"seq"`
+	Index       int            `json:"index"`
+	Channel     int            `json:"channel"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	EventTime   time.Time      `json:"eventTime"`
+	CaptureTime time.Time      `json:"captureTime"`
+	Event       map[string]any `json:"event"` // Key values of the trigger event, keys with namespace are written as namespace:key.
+}
+
+// SnapshotArchive takes snapshots when its trigger event fires and stores them with the StorageProvider.
+type SnapshotArchive struct {
+	cfg          SnapshotArchiveConfig
+	app          *AcapApplication
+	keys         []axevent.KeyValueEntrie
+	subscription int
+	seq          atomic.Uint64
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+	stopped      bool
+}
+
+// archiveTrigger holds the values of one trigger event, they are read in the event callback while the event is valid.
+type archiveTrigger struct {
+	topic  string
+	time   time.Time
+	values map[string]any
+}
+
+// NewSnapshotArchive subscribes to the trigger event of cfg and archives snapshots each time it fires.
+// The StorageProvider must be created with NewStorageProvider and opened before.
+// The archive stops when the application shuts down, or with Stop.
+func (a *AcapApplication) NewSnapshotArchive(cfg SnapshotArchiveConfig) (*SnapshotArchive, error) {
+	if a.StorageProvider == nil {
+		return nil, errors.New("No storage provider, create it with NewStorageProvider")
+	}
+	if a.EventHandler == nil {
+		return nil, ErrNoEventHandler
+	}
+	if cfg.Snapshot.Format != axvdo.VdoFormatNone && cfg.Snapshot.Format != axvdo.VdoFormatJPEG {
+		return nil, errors.New("Snapshot archive only supports JPEG snapshots")
+	}
+	if cfg.Count <= 0 {
+		cfg.Count = 1
+	}
+	if cfg.Template == "" {
+		cfg.Template = DefaultSnapshotArchiveTemplate
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = DefaultSnapshotArchiveRetries
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = DefaultSnapshotArchiveRetryDelay
+	}
+
+	sa := &SnapshotArchive{cfg: cfg, app: a}
+	var kvs *axevent.AXEventKeyValueSet
+	switch trigger := cfg.Trigger.(type) {
+	case nil:
+		return nil, errors.New("Snapshot archive has no trigger")
+	case *axevent.AXEventKeyValueSet:
+		kvs = trigger
+		sa.keys = trigger.Keys()
+	default:
+		entries, err := marshalEventEntries(trigger)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trigger: %w", err)
+		}
+		if kvs, err = newKeyValueSet(entries); err != nil {
+			return nil, err
+		}
+		sa.keys = entries
+	}
+	sa.keys = append(sa.keys, cfg.SidecarKeys...)
+
+	sa.ctx, sa.cancel = context.WithCancel(context.Background())
+	subscription, err := a.OnEvent(kvs, sa.onEvent)
+	if err != nil {
+		sa.cancel()
+		return nil, err
+	}
+	sa.subscription = subscription
+
+	cleanerName := ""
+	if cfg.Name != "" {
+		cleanerName = "snapshotarchive-" + cfg.Name
+	}
+	if err := a.AddCleaner(Cleaner{Name: cleanerName, Clean: sa.Stop, Before: []string{CleanerStorage}}); err != nil {
+		sa.Stop(context.Background())
+		return nil, err
+	}
+	return sa, nil
+}
+
+// Stop unsubscribes from the trigger event and cancels pending retries.
+// It waits for running captures until ctx is done.
+func (sa *SnapshotArchive) Stop(ctx context.Context) error {
+	sa.mu.Lock()
+	if sa.stopped {
+		sa.mu.Unlock()
+		return nil
+	}
+	sa.stopped = true
+	sa.mu.Unlock()
+
+	var err error
+	if sa.app.EventHandler != nil {
+		err = sa.app.EventHandler.Unsubscribe(sa.subscription)
+	}
+	sa.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		sa.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return errors.Join(err, ctx.Err())
+	}
+}
+
+// onEvent is the subscription callback, it runs on the main loop and hands the capture to a goroutine.
+func (sa *SnapshotArchive) onEvent(e *axevent.Event) {
+	trigger := archiveTrigger{time: e.Timestamp, values: map[string]any{}}
+	var topics []axevent.KeyValueEntrie
+	for _, key := range sa.keys {
+		value, ok := eventKeyValue(e.Kvs, key.Key, key.Namespace)
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(key.Key, "topic") {
+			topics = append(topics, axevent.KeyValueEntrie{Key: key.Key, Value: value})
+			continue
+		}
+		trigger.values[eventKeyName(key.Key, key.Namespace)] = value
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Key < topics[j].Key })
+	parts := make([]string, 0, len(topics))
+	for _, t := range topics {
+		parts = append(parts, fmt.Sprint(t.Value))
+	}
+	trigger.topic = strings.Join(parts, "_")
+	if trigger.topic == "" {
+		trigger.topic = sa.cfg.Name
+	}
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	if sa.stopped {
+		return
+	}
+	sa.wg.Add(1)
+	go sa.capture(trigger)
+}
+
+// capture takes the snapshots of one trigger, each snapshot is stored in its own goroutine so retries do not delay the next one.
+func (sa *SnapshotArchive) capture(trigger archiveTrigger) {
+	defer sa.wg.Done()
+	for i := 1; i <= sa.cfg.Count; i++ {
+		if i > 1 && sa.cfg.Interval > 0 {
+			select {
+			case <-sa.ctx.Done():
+				return
+			case <-time.After(sa.cfg.Interval):
+			}
+		}
+		if sa.ctx.Err() != nil {
+			return
+		}
+
+		snap, err := sa.app.GetSnapshotWithOptions(sa.cfg.Snapshot)
+		if err != nil {
+			sa.app.Syslog.Errorf("Snapshot archive %s: unable to take snapshot: %s", sa.cfg.Name, err.Error())
+			continue
+		}
+		now := time.Now()
+		seq := sa.seq.Add(1)
+		path, err := sa.filePath(trigger, now, seq, i)
+		if err != nil {
+			sa.app.Syslog.Errorf("Snapshot archive %s: %s", sa.cfg.Name, err.Error())
+			return
+		}
+		sidecar := &SnapshotSidecar{
+			Topic:       trigger.topic,
+			Seq:         seq,
+			Index:       i,
+			Channel:     sa.cfg.Snapshot.Channel,
+			Width:       snap.Width,
+			Height:      snap.Height,
+			EventTime:   trigger.time,
+			CaptureTime: now,
+			Event:       trigger.values,
+		}
+		sa.wg.Add(1)
+		go sa.store(path, snap.Data, sidecar)
+	}
+}
+
+// store writes the snapshot and its sidecar, it retries when no disk is available or the write fails.
+func (sa *SnapshotArchive) store(path string, data []byte, sidecar *SnapshotSidecar) {
+	defer sa.wg.Done()
+	for attempt := 0; ; attempt++ {
+		err := sa.write(path, data, sidecar)
+		if err == nil {
+			return
+		}
+		if sa.cfg.Retries < 0 || attempt >= sa.cfg.Retries {
+			sa.app.Syslog.Errorf("Snapshot archive %s: unable to store %s: %s", sa.cfg.Name, path, err.Error())
+			return
+		}
+		sa.app.Syslog.Warnf("Snapshot archive %s: unable to store %s, retry in %s: %s", sa.cfg.Name, path, sa.cfg.RetryDelay, err.Error())
+		select {
+		case <-sa.ctx.Done():
+			sa.app.Syslog.Warnf("Snapshot archive %s: dropped %s on stop", sa.cfg.Name, path)
+			return
+		case <-time.After(sa.cfg.RetryDelay):
+		}
+	}
+}
+
+func (sa *SnapshotArchive) write(path string, data []byte, sidecar *SnapshotSidecar) error {
+	di, ok := sa.disk()
+	if !ok {
+		return errors.New("No disk available")
+	}
+	if res := sa.app.StorageProvider.WriteFile(di, path, data); res.RwError != RWErrorNone {
+		return res.Error
+	}
+	if sa.cfg.SkipSidecar {
+		return nil
+	}
+	sidecar.Disk = string(di.StorageId)
+	content, err := json.MarshalIndent(sidecar, "", "  ")
+	if err != nil {
+		return err
+	}
+	sidecarPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+	if res := sa.app.StorageProvider.WriteFile(di, sidecarPath, content); res.RwError != RWErrorNone {
+		return res.Error
+	}
+	return nil
+}
+
+// disk returns the first preferred disk that is usable, or the BestDiskItem of the StorageProvider if no disks are configured.
+// The preferred disks are checked on the copies published by the storage callbacks, a returned preferred disk is such a copy.
+func (sa *SnapshotArchive) disk() (*axstorage.DiskItem, bool) {
+	sp := sa.app.StorageProvider
+	if len(sa.cfg.Disks) == 0 {
+		return sp.BestDiskItem()
+	}
+	for _, id := range sa.cfg.Disks {
+		if di := sp.publishedDiskItem(id).Load(); di != nil && diskUsable(di) {
+			return di, true
+		}
+	}
+	return nil, false
+}
+
+// filePath expands the file name template, the result must stay inside the storage path.
+func (sa *SnapshotArchive) filePath(trigger archiveTrigger, now time.Time, seq uint64, index int) (string, error) {
+	topic := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(trigger.topic)
+	if topic == "" {
+		topic = "snapshots"
+	}
+	path := strings.NewReplacer(
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+		"{timestamp}", strconv.FormatInt(now.UnixMilli(), 10),
+		"{topic}", topic,
+		"{seq}", fmt.Sprintf("%06d", seq),
+		"{index}", strconv.Itoa(index),
+		"{channel}", strconv.Itoa(sa.cfg.Snapshot.Channel),
+	).Replace(sa.cfg.Template)
+	path = filepath.Clean(path)
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("File name %s is outside of the storage path", path)
+	}
+	return path, nil
+}
+
+// eventKeyValue reads the value of a key from the key value set, it reports false if the key is missing or has no value.
+func eventKeyValue(kvs *axevent.AXEventKeyValueSet, key string, namespace *string) (any, bool) {
+	valueType, err := kvs.GetValueType(key, namespace)
+	if err != nil {
+		return nil, false
+	}
+	var value any
+	switch valueType {
+	case axevent.AXValueTypeInt:
+		value, err = kvs.GetInteger(key, namespace)
+	case axevent.AXValueTypeBool:
+		value, err = kvs.GetBoolean(key, namespace)
+	case axevent.AXValueTypeDouble:
+		value, err = kvs.GetDouble(key, namespace)
+	case axevent.AXValueTypeString:
+		value, err = kvs.GetString(key, namespace)
+	default:
+		return nil, false
+	}
+	return value, err == nil
+}
+
+// eventKeyName returns the key prefixed with its namespace, like in eventKey tags.
+func eventKeyName(key string, namespace *string) string {
+	if namespace != nil && *namespace != "" {
+		return *namespace + ":" + key
+	}
+	return key
+}
@@ -0,0 +1,218 @@
+package acapapp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axstorage"
+)
+
+// StorageFileInfo describes a file or directory below the storage path of a disk.
+type StorageFileInfo struct {
+	Path    string    `json:"path"` // Path relative to the storage path, with forward slashes.
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	IsDir   bool      `json:"isDir"`
+}
+
+// StorageUsage describes the space of a disk, all values are in bytes.
+type StorageUsage struct {
+	StorageId axstorage.StorageId `json:"storageId"`
+	Total     int64               `json:"total"` // Size of the file system.
+	Used      int64               `json:"used"`  // Used space of the whole file system.
+	Free      int64               `json:"free"`  // Space available to the application.
+	App       int64               `json:"app"`   // Size of all files in the storage path of the application.
+}
+
+// checkReadPossibility is checkRwPossibility for reads, a full or read only disk can still be read.
+func (sp *StorageProvider) checkReadPossibility(di *axstorage.DiskItem) *RwResult {
+	state, rwPossible := sp.diskState(di)
+	if rwPossible != nil {
+		return rwPossible
+	}
+	rwPossible = rwPossibility(&state)
+	if rwPossible.RwError == RWErrorFull || rwPossible.RwError == RWErrorNotWriteable {
+		if !state.Setup {
+			return &RwResult{RwError: RWErrorNotSetuped, Error: errors.New("Storage/Disk is not setuped")}
+		}
+		return &RwResult{RwError: RWErrorNone}
+	}
+	return rwPossible
+}
+
+// storagePath joins the relative path with the storage path, paths that leave the storage path are rejected.
+func storagePath(di *axstorage.DiskItem, relPath string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(relPath))
+	if clean != "." && !filepath.IsLocal(clean) {
+		return "", fmt.Errorf("Path %s is outside of the storage path", relPath)
+	}
+	return filepath.Join(di.StoragePath, clean), nil
+}
+
+// fileInfo converts an os.FileInfo of the absolute path into a StorageFileInfo.
+func fileInfo(di *axstorage.DiskItem, absPath string, info os.FileInfo) StorageFileInfo {
+	rel, err := filepath.Rel(di.StoragePath, absPath)
+	if err != nil {
+		rel = info.Name()
+	}
+	return StorageFileInfo{
+		Path:    filepath.ToSlash(rel),
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+}
+
+// List returns the entries of the directory dir relative to the storage path, sorted by name.
+// Temporary files of running writes are left out.
+func (sp *StorageProvider) List(di *axstorage.DiskItem, dir string) ([]StorageFileInfo, error) {
+	if rwPossible := sp.checkReadPossibility(di); rwPossible.RwError != RWErrorNone {
+		return nil, rwPossible.Error
+	}
+	absDir, err := storagePath(di, dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]StorageFileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if isTempFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// The file was removed after reading the directory.
+			continue
+		}
+		infos = append(infos, fileInfo(di, filepath.Join(absDir, entry.Name()), info))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// Walk calls fn for each file and directory below dir relative to the storage path, in lexical order.
+// fn may return filepath.SkipDir or filepath.SkipAll like in filepath.WalkDir. Temporary files of running writes are left out.
+func (sp *StorageProvider) Walk(di *axstorage.DiskItem, dir string, fn func(info StorageFileInfo) error) error {
+	if rwPossible := sp.checkReadPossibility(di); rwPossible.RwError != RWErrorNone {
+		return rwPossible.Error
+	}
+	root, err := storagePath(di, dir)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root || isTempFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		return fn(fileInfo(di, path, info))
+	})
+}
+
+// Glob returns the paths relative to the storage path that match the pattern, see filepath.Match for the syntax.
+func (sp *StorageProvider) Glob(di *axstorage.DiskItem, pattern string) ([]string, error) {
+	if rwPossible := sp.checkReadPossibility(di); rwPossible.RwError != RWErrorNone {
+		return nil, rwPossible.Error
+	}
+	absPattern, err := storagePath(di, pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(absPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if isTempFile(filepath.Base(match)) {
+			continue
+		}
+		if rel, err := filepath.Rel(di.StoragePath, match); err == nil {
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+	}
+	return paths, nil
+}
+
+// Stat returns the StorageFileInfo of the path relative to the storage path.
+func (sp *StorageProvider) Stat(di *axstorage.DiskItem, filePath string) (StorageFileInfo, error) {
+	if rwPossible := sp.checkReadPossibility(di); rwPossible.RwError != RWErrorNone {
+		return StorageFileInfo{}, rwPossible.Error
+	}
+	absPath, err := storagePath(di, filePath)
+	if err != nil {
+		return StorageFileInfo{}, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return StorageFileInfo{}, err
+	}
+	return fileInfo(di, absPath, info), nil
+}
+
+// Usage returns the space of the disk and the size of the files of the application.
+func (sp *StorageProvider) Usage(di *axstorage.DiskItem) (StorageUsage, error) {
+	if rwPossible := sp.checkReadPossibility(di); rwPossible.RwError != RWErrorNone {
+		return StorageUsage{}, rwPossible.Error
+	}
+	usage, err := statfsUsage(di.StoragePath)
+	if err != nil {
+		return StorageUsage{}, err
+	}
+	usage.StorageId = di.StorageId
+
+	err = filepath.WalkDir(di.StoragePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		usage.App += info.Size()
+		return nil
+	})
+	return usage, err
+}
+
+// statfsUsage returns the total, used and free space of the file system of path.
+func statfsUsage(path string) (StorageUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return StorageUsage{}, err
+	}
+	bsize := int64(st.Bsize)
+	return StorageUsage{
+		Total: int64(st.Blocks) * bsize,
+		Used:  int64(st.Blocks-st.Bfree) * bsize,
+		Free:  int64(st.Bavail) * bsize,
+	}, nil
+}
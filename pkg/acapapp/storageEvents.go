@@ -0,0 +1,145 @@
+package acapapp
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Cacsjep/goxis/pkg/axstorage"
+)
+
+// StorageEventKind describes what changed on a disk.
+type StorageEventKind int
+
+const (
+	StorageEventAdded         StorageEventKind = iota // The disk was found by Open.
+	StorageEventAvailable                             // The Available flag changed.
+	StorageEventSetupComplete                         // The asynchronous setup succeeded, StoragePath is set.
+	StorageEventFull                                  // The Full flag changed.
+	StorageEventWritable                              // The Writable flag changed.
+	StorageEventExiting                               // The Exiting flag changed.
+	StorageEventReleased                              // The disk was released, Err is set if the release failed.
+	StorageEventSetupFailed                           // The asynchronous setup failed, Err holds the reason.
+)
+
+func (k StorageEventKind) String() string {
+	switch k {
+	case StorageEventAdded:
+		return "Added"
+	case StorageEventAvailable:
+		return "Available"
+	case StorageEventSetupComplete:
+		return "SetupComplete"
+	case StorageEventFull:
+		return "Full"
+	case StorageEventWritable:
+		return "Writable"
+	case StorageEventExiting:
+		return "Exiting"
+	case StorageEventReleased:
+		return "Released"
+	case StorageEventSetupFailed:
+		return "SetupFailed"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(k))
+	}
+}
+
+// StorageEvent is a change of a disk. Snapshot is a copy of the DiskItem taken when the event occurred,
+// so it does not change when the DiskItem is updated later. For flag kinds it holds the new value of the flag.
+type StorageEvent struct {
+	Kind     StorageEventKind
+	Snapshot axstorage.DiskItem
+	Err      error
+}
+
+// storageEvents holds the listeners of the storage events of a StorageProvider.
+type storageEvents struct {
+	mu        sync.Mutex
+	channel   chan StorageEvent
+	callbacks []func(StorageEvent)
+	emitted   map[axstorage.StorageId]axstorage.DiskItem // Flags of the last emitted events per disk.
+}
+
+// Events returns a channel that receives every storage event.
+// The channel is buffered, events are dropped if the receiver does not keep up.
+func (sp *StorageProvider) Events() <-chan StorageEvent {
+	sp.events.mu.Lock()
+	defer sp.events.mu.Unlock()
+	if sp.events.channel == nil {
+		sp.events.channel = make(chan StorageEvent, 32)
+	}
+	return sp.events.channel
+}
+
+// OnStorageEvent registers a callback that is called for every storage event.
+// Callbacks run in the storage callbacks on the main loop and must not block.
+func (sp *StorageProvider) OnStorageEvent(callback func(StorageEvent)) {
+	sp.events.mu.Lock()
+	defer sp.events.mu.Unlock()
+	sp.events.callbacks = append(sp.events.callbacks, callback)
+}
+
+// emit sends an event with a snapshot of the disk item to all listeners without blocking.
+func (sp *StorageProvider) emit(kind StorageEventKind, di *axstorage.DiskItem, err error) {
+	event := StorageEvent{Kind: kind, Snapshot: *di, Err: err}
+
+	sp.events.mu.Lock()
+	channel := sp.events.channel
+	callbacks := append([]func(StorageEvent){}, sp.events.callbacks...)
+	sp.events.mu.Unlock()
+
+	if channel != nil {
+		select {
+		case channel <- event:
+		default:
+			sp.app.Syslog.Warnf("Dropped storage event %s of %s, receiver does not keep up", kind, di.StorageId)
+		}
+	}
+	for _, callback := range callbacks {
+		callback(event)
+	}
+}
+
+// emitFlagChanges emits an event for each flag that differs between the flags of the last emitted events and the disk item.
+func (sp *StorageProvider) emitFlagChanges(di *axstorage.DiskItem) {
+	old := sp.rememberFlags(di)
+	if old.Available != di.Available {
+		sp.emit(StorageEventAvailable, di, nil)
+	}
+	if old.Writable != di.Writable {
+		sp.emit(StorageEventWritable, di, nil)
+	}
+	if old.Full != di.Full {
+		sp.emit(StorageEventFull, di, nil)
+	}
+	if old.Exiting != di.Exiting {
+		sp.emit(StorageEventExiting, di, nil)
+	}
+}
+
+// rememberFlags stores the flags of the disk item as emitted and returns the flags emitted before.
+func (sp *StorageProvider) rememberFlags(di *axstorage.DiskItem) axstorage.DiskItem {
+	sp.events.mu.Lock()
+	defer sp.events.mu.Unlock()
+	if sp.events.emitted == nil {
+		sp.events.emitted = map[axstorage.StorageId]axstorage.DiskItem{}
+	}
+	old := sp.events.emitted[di.StorageId]
+	sp.events.emitted[di.StorageId] = *di
+	return old
+}
+
+// sendDiskItemEvent sends the disk item to DiskItemsEvents, without blocking if DropDiskItemsEvents is set.
+func (sp *StorageProvider) sendDiskItemEvent(di *axstorage.DiskItem) {
+	if !sp.UseChannelEvents {
+		return
+	}
+	if !sp.DropDiskItemsEvents {
+		sp.DiskItemsEvents <- di
+		return
+	}
+	select {
+	case sp.DiskItemsEvents <- di:
+	default:
+	}
+}
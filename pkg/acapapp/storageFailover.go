@@ -0,0 +1,355 @@
+package acapapp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/Cacsjep/goxis/pkg/axstorage"
+)
+
+// DefaultSpoolMaxAttempts is the number of failed flushes after which a spooled file is dropped.
+const DefaultSpoolMaxAttempts = 3
+
+// SpoolConfig configures the spool that keeps writes of WriteBest while no disk is usable.
+type SpoolConfig struct {
+	MaxBytes    int64  // Maximum total size of all spooled files, required.
+	MaxFiles    int    // Maximum number of spooled files, unlimited if 0.
+	Dir         string // Directory of the spool, for example under /tmp. The spool is kept in RAM if empty.
+	MaxAttempts int    // Failed flushes of a file before it is dropped, DefaultSpoolMaxAttempts if 0.
+}
+
+// storageFailover holds the disk priority and the spool of a StorageProvider.
+type storageFailover struct {
+	mu       sync.Mutex
+	priority []axstorage.StorageId
+	spool    *SpoolConfig
+	entries  []*spoolEntry
+	bytes    int64
+	flushMu  sync.Mutex
+}
+
+// spoolEntry is a spooled write, its content is either held in data or in the file spoolPath.
+type spoolEntry struct {
+	path      string
+	size      int64
+	data      []byte
+	spoolPath string
+	failures  int // Failed flushes, counted with flushMu held.
+}
+
+// SetDiskPriority sets the order in which WriteBest and BestDiskItem pick disks, for example the SD card before a network share.
+// Disks that are not listed follow in the order of DiskItems.
+func (sp *StorageProvider) SetDiskPriority(storageIds ...axstorage.StorageId) {
+	sp.failover.mu.Lock()
+	defer sp.failover.mu.Unlock()
+	sp.failover.priority = append([]axstorage.StorageId{}, storageIds...)
+}
+
+// BestDiskItem returns the disk with the highest priority that is set up, writable, not full and not exiting.
+// The result is the copy of the disk item published by the storage callbacks, use GetDiskItem for the shared DiskItem.
+func (sp *StorageProvider) BestDiskItem() (*axstorage.DiskItem, bool) {
+	if usable := sp.usableDiskItems(); len(usable) > 0 {
+		return usable[0], true
+	}
+	return nil, false
+}
+
+// usableDiskItems returns the published copies of all usable disks, in the order of the disk priority followed by the remaining DiskItems.
+func (sp *StorageProvider) usableDiskItems() []*axstorage.DiskItem {
+	sp.failover.mu.Lock()
+	priority := sp.failover.priority
+	sp.failover.mu.Unlock()
+
+	var usable []*axstorage.DiskItem
+	added := map[axstorage.StorageId]bool{}
+	add := func(id axstorage.StorageId) {
+		if added[id] {
+			return
+		}
+		if di := sp.publishedDiskItem(id).Load(); di != nil && diskUsable(di) {
+			usable = append(usable, di)
+			added[id] = true
+		}
+	}
+	for _, id := range priority {
+		if _, ok := sp.GetDiskItem(id); ok {
+			add(id)
+		}
+	}
+	for _, di := range sp.diskItems() {
+		add(di.StorageId)
+	}
+	return usable
+}
+
+// diskUsable reports whether the state of the disk allows writes, di must not be the shared DiskItem outside of the main loop.
+func diskUsable(di *axstorage.DiskItem) bool {
+	return di.Setup && di.Writable && !di.Full && !di.Exiting
+}
+
+// EnableSpool enables spooling of WriteBest. If Dir is set, files spooled by an earlier run are restored.
+func (sp *StorageProvider) EnableSpool(cfg SpoolConfig) error {
+	if cfg.MaxBytes <= 0 {
+		return errors.New("Spool needs a maximum size")
+	}
+
+	var restored []*spoolEntry
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+			return fmt.Errorf("Unable to create spool directory: %w", err)
+		}
+		var err error
+		if restored, err = restoreSpool(cfg.Dir); err != nil {
+			return err
+		}
+	}
+
+	f := &sp.failover
+	f.mu.Lock()
+	f.spool = &cfg
+	f.entries = restored
+	f.bytes = 0
+	for _, e := range restored {
+		f.bytes += e.size
+	}
+	bytes := f.bytes
+	f.mu.Unlock()
+
+	if len(restored) > 0 {
+		sp.app.Syslog.Infof("Restored %d spooled files (%d bytes)", len(restored), bytes)
+		sp.flushSpoolAsync()
+	}
+	return nil
+}
+
+// restoreSpool reads the spooled files of dir, oldest first.
+func restoreSpool(dir string) ([]*spoolEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read spool directory: %w", err)
+	}
+	type restoredEntry struct {
+		entry *spoolEntry
+		info  os.FileInfo
+	}
+	var restored []restoredEntry
+	for _, de := range dirEntries {
+		if !de.Type().IsRegular() {
+			continue
+		}
+		path, err := url.PathUnescape(de.Name())
+		if err != nil {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		restored = append(restored, restoredEntry{
+			entry: &spoolEntry{path: path, size: info.Size(), spoolPath: filepath.Join(dir, de.Name())},
+			info:  info,
+		})
+	}
+	sort.SliceStable(restored, func(i, j int) bool { return restored[i].info.ModTime().Before(restored[j].info.ModTime()) })
+	entries := make([]*spoolEntry, len(restored))
+	for i, r := range restored {
+		entries[i] = r.entry
+	}
+	return entries, nil
+}
+
+// SpoolSize returns the number and total size of the spooled files.
+func (sp *StorageProvider) SpoolSize() (files int, bytes int64) {
+	sp.failover.mu.Lock()
+	defer sp.failover.mu.Unlock()
+	return len(sp.failover.entries), sp.failover.bytes
+}
+
+// WriteBest writes the content to the best available disk, see BestDiskItem and SetDiskPriority.
+// If the write fails, the next usable disk is tried. If no disk is usable and a spool is enabled, the content is
+// spooled and RwResult.Spooled is set, spooled files are written once a disk is set up or writable again.
+// The returned DiskItem is the published copy like in BestDiskItem, it is nil if the content was spooled. Errors of the file system, RWErrorOs, are returned and never spooled.
+func (sp *StorageProvider) WriteBest(filePath string, content []byte) (*axstorage.DiskItem, *RwResult) {
+	di, res := sp.writeUsable(filePath, content)
+	if res.RwError == RWErrorNone || res.RwError == RWErrorOs {
+		return di, res
+	}
+	if spooled := sp.spoolWrite(filePath, content); spooled != nil {
+		return nil, spooled
+	}
+	return di, res
+}
+
+// writeUsable writes the content to the first usable disk that accepts it. If all disks fail, the result of the last disk
+// is returned, an RWErrorOs result wins over disk state errors.
+func (sp *StorageProvider) writeUsable(filePath string, content []byte) (*axstorage.DiskItem, *RwResult) {
+	var failedDisk *axstorage.DiskItem
+	var failed *RwResult
+	for _, di := range sp.usableDiskItems() {
+		res := sp.WriteFile(di, filePath, content)
+		if res.RwError == RWErrorNone {
+			return di, res
+		}
+		sp.app.Syslog.Warnf("Unable to write %s to %s, trying the next disk: %s", filePath, di.StorageId, res.Error)
+		if failed == nil || failed.RwError != RWErrorOs {
+			failedDisk, failed = di, res
+		}
+	}
+	if failed == nil {
+		return nil, &RwResult{RwError: RWErrorNotAvalible, Error: errors.New("No usable storage/disk")}
+	}
+	return failedDisk, failed
+}
+
+// spoolWrite adds the content to the spool, it returns nil if no spool is enabled.
+// A spooled file replaces an earlier spooled file with the same path.
+func (sp *StorageProvider) spoolWrite(filePath string, content []byte) *RwResult {
+	f := &sp.failover
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.spool == nil {
+		return nil
+	}
+
+	size := int64(len(content))
+	var replaced *spoolEntry
+	for _, e := range f.entries {
+		if e.path == filePath {
+			replaced = e
+			break
+		}
+	}
+	bytes, files := f.bytes+size, len(f.entries)+1
+	if replaced != nil {
+		bytes -= replaced.size
+		files--
+	}
+	if bytes > f.spool.MaxBytes || (f.spool.MaxFiles > 0 && files > f.spool.MaxFiles) {
+		return &RwResult{RwError: RWErrorSpoolFull, Error: errors.New("Storage spool is full")}
+	}
+
+	entry := &spoolEntry{path: filePath, size: size}
+	if f.spool.Dir == "" {
+		entry.data = append([]byte{}, content...)
+	} else {
+		entry.spoolPath = filepath.Join(f.spool.Dir, url.PathEscape(filePath))
+		if err := os.WriteFile(entry.spoolPath, content, 0644); err != nil {
+			return &RwResult{RwError: RWErrorOs, Error: fmt.Errorf("Unable to spool %s: %w", filePath, err)}
+		}
+	}
+	if replaced != nil {
+		f.removeEntry(replaced, entry.spoolPath == replaced.spoolPath)
+	}
+	f.entries = append(f.entries, entry)
+	f.bytes += size
+	return &RwResult{RwError: RWErrorNone, Spooled: true}
+}
+
+// removeEntry removes the entry from the spool, it must be called with mu held.
+func (f *storageFailover) removeEntry(entry *spoolEntry, keepFile bool) {
+	found := false
+	for i, e := range f.entries {
+		if e == entry {
+			f.entries = append(f.entries[:i], f.entries[i+1:]...)
+			f.bytes -= e.size
+			found = true
+			break
+		}
+	}
+	if found && entry.spoolPath != "" && !keepFile {
+		os.Remove(entry.spoolPath)
+	}
+}
+
+// FlushSpool writes the spooled files to the best available disk, oldest first, and returns the number of written files.
+// It stops when no disk is usable anymore. A file that fails with an error of the file system does not hold back
+// the files behind it, it is retried with the next flush and dropped after SpoolConfig.MaxAttempts failed flushes.
+// Flushing runs automatically when a disk is set up or its state changes.
+func (sp *StorageProvider) FlushSpool() (int, error) {
+	f := &sp.failover
+	if !f.flushMu.TryLock() {
+		return 0, nil
+	}
+	defer f.flushMu.Unlock()
+
+	flushed := 0
+	var errs []error
+	tried := map[*spoolEntry]bool{}
+	for {
+		f.mu.Lock()
+		var entry *spoolEntry
+		for _, e := range f.entries {
+			if !tried[e] {
+				entry = e
+				break
+			}
+		}
+		maxAttempts := DefaultSpoolMaxAttempts
+		if f.spool != nil && f.spool.MaxAttempts > 0 {
+			maxAttempts = f.spool.MaxAttempts
+		}
+		f.mu.Unlock()
+		if entry == nil {
+			break
+		}
+		tried[entry] = true
+
+		if _, ok := sp.BestDiskItem(); !ok {
+			break
+		}
+		content := entry.data
+		if entry.spoolPath != "" {
+			var err error
+			if content, err = os.ReadFile(entry.spoolPath); err != nil {
+				sp.app.Syslog.Warnf("Dropped unreadable spooled file %s: %s", entry.path, err.Error())
+				f.mu.Lock()
+				f.removeEntry(entry, false)
+				f.mu.Unlock()
+				continue
+			}
+		}
+		if di, res := sp.writeUsable(entry.path, content); res.RwError != RWErrorNone {
+			if res.RwError != RWErrorOs {
+				// No disk accepts writes right now, keep the order for the next flush.
+				errs = append(errs, fmt.Errorf("Unable to flush %s: %w", entry.path, res.Error))
+				break
+			}
+			if entry.failures++; entry.failures < maxAttempts {
+				errs = append(errs, fmt.Errorf("Unable to flush %s to %s: %w", entry.path, di.StorageId, res.Error))
+				continue
+			}
+			sp.app.Syslog.Warnf("Dropped spooled file %s after %d failed flushes: %s", entry.path, entry.failures, res.Error)
+			f.mu.Lock()
+			f.removeEntry(entry, false)
+			f.mu.Unlock()
+			continue
+		}
+
+		f.mu.Lock()
+		// If a newer write of the same path replaced the entry while it was flushed, the newer one stays spooled.
+		f.removeEntry(entry, false)
+		f.mu.Unlock()
+		flushed++
+	}
+	if flushed > 0 {
+		sp.app.Syslog.Infof("Flushed %d spooled files", flushed)
+	}
+	return flushed, errors.Join(errs...)
+}
+
+// flushSpoolAsync flushes the spool in the background if it holds files.
+func (sp *StorageProvider) flushSpoolAsync() {
+	if files, _ := sp.SpoolSize(); files == 0 {
+		return
+	}
+	go func() {
+		if _, err := sp.FlushSpool(); err != nil {
+			sp.app.Syslog.Warn(err.Error())
+		}
+	}()
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Cacsjep/goxis/pkg/axstorage"
 )
@@ -14,15 +17,27 @@ import (
 type StorageProvider struct {
 	app              *AcapApplication      // Reference to the main application.
 	DiskItems        []*axstorage.DiskItem // List of disk items representing storage devices.
+	diskItemsMu      sync.Mutex            // Guards DiskItems and published against the goroutines of retention and failover.
 	subscribtions    []int                 // Subscription list for unsubscribe
 	DiskItemsEvents  chan *axstorage.DiskItem
 	UseChannelEvents bool
+	// DropDiskItemsEvents drops DiskItemsEvents when the channel is full instead of blocking the main loop until they are received.
+	DropDiskItemsEvents bool
+	// WriterCheckInterval is the interval in which writers re-check their disk, DefaultWriterCheckInterval if 0.
+	WriterCheckInterval time.Duration
+	retention           storageRetention
+	failover            storageFailover
+	events              storageEvents
+	writers             storageWriters
+	// Copies of the disk items published by the storage callbacks for writers outside of the main loop.
+	published map[axstorage.StorageId]*atomic.Pointer[axstorage.DiskItem]
 }
 
 // NewStorageProvider initializes and returns a new StorageProvider associated with a given AcapApplication.
 // Whem useChannelEvents is true the DiskItemsEvents channel got events from subscriptions callbacks
 // in form of *axstorage.DiskItem.
-// Its an unbufferd channel with cap 10
+// Its a buffered channel with cap 10, the storage callbacks block until it has room unless DropDiskItemsEvents is set.
+// The DiskItem is updated after it was sent, prefer Events or OnStorageEvent, which send copies of the DiskItem together with what changed.
 func (a *AcapApplication) NewStorageProvider(useChannelEvents bool) {
 	a.StorageProvider = &StorageProvider{
 		app:              a,
@@ -42,6 +57,8 @@ const (
 	RWErrorNotWriteable
 	RWErrorNotUpdateable
 	RWErrorOs
+	RWErrorExiting
+	RWErrorSpoolFull
 )
 
 // RwResult encapsulates the result of a read/write operation, including any errors that occurred.
@@ -49,17 +66,37 @@ type RwResult struct {
 	RwError RwError // Specific write error encountered, if any.
 	Error   error   // General error encountered during the operation.
 	Data    []byte  // Data read from storage, applicable for read operations.
+	Spooled bool    // The data was spooled by WriteBest because no disk was usable.
 }
 
 // checkRwPossibility evaluates if read/write operations can be performed on the provided DiskItem.
 // It returns an RwResult indicating any potential issues that would prevent operations.
-func checkRwPossibility(di *axstorage.DiskItem) *RwResult {
+func (sp *StorageProvider) checkRwPossibility(di *axstorage.DiskItem) *RwResult {
+	state, rwPossible := sp.diskState(di)
+	if rwPossible != nil {
+		return rwPossible
+	}
+	return rwPossibility(&state)
+}
 
+// diskState returns a copy of the disk item with the current flags of the storage. The shared DiskItem is only
+// updated by the storage subscription on the main loop, so its events see every change of the flags.
+func (sp *StorageProvider) diskState(di *axstorage.DiskItem) (axstorage.DiskItem, *RwResult) {
+	var state axstorage.DiskItem
+	if published := sp.publishedDiskItem(di.StorageId).Load(); published != nil {
+		state = *published
+	} else {
+		state = axstorage.DiskItem{StorageId: di.StorageId}
+	}
 	// Force update to get sure we are have correct states
-	if err := axstorage.UpdateDiskItemEvents(di); err != nil {
-		return &RwResult{RwError: RWErrorNotUpdateable, Error: err}
+	if err := axstorage.UpdateDiskItemEvents(&state); err != nil {
+		return state, &RwResult{RwError: RWErrorNotUpdateable, Error: err}
 	}
+	return state, nil
+}
 
+// rwPossibility is checkRwPossibility for the current state of the disk item, without asking the storage for an update.
+func rwPossibility(di *axstorage.DiskItem) *RwResult {
 	if !di.Available {
 		return &RwResult{RwError: RWErrorNotAvalible, Error: errors.New("Storage/Disk is not avalible")}
 	}
@@ -79,14 +116,53 @@ func checkRwPossibility(di *axstorage.DiskItem) *RwResult {
 }
 
 // WriteFile writes the given content to a file at the specified path on the disk item.
+// The content is written to a temporary file first and renamed into place, missing parent directories are created.
+// If the disk is full and has a retention policy, the policy is enforced before the write is given up.
 // It returns an RwResult indicating the outcome of the write operation.
 func (sp *StorageProvider) WriteFile(di *axstorage.DiskItem, filePath string, content []byte) *RwResult {
 	var rwPossible *RwResult
-	if rwPossible = checkRwPossibility(di); rwPossible.RwError == RWErrorNone {
-		if err := os.WriteFile(filepath.Join(di.StoragePath, filePath), content, 0644); err != nil {
-			return &RwResult{RwError: RWErrorOs, Error: err}
+	if rwPossible = sp.checkWritePossibility(di); rwPossible.RwError != RWErrorNone {
+		return rwPossible
+	}
+	w, err := sp.openWriter(di, filePath)
+	if err != nil {
+		return &RwResult{RwError: RWErrorOs, Error: err}
+	}
+	if _, err := w.Write(content); err != nil {
+		return writerResult(err)
+	}
+	if err := w.Close(); err != nil {
+		return writerResult(err)
+	}
+	return &RwResult{RwError: RWErrorNone}
+}
+
+// writerResult converts an error of a StorageWriter into an RwResult.
+func writerResult(err error) *RwResult {
+	if errors.Is(err, ErrStorageExiting) {
+		return &RwResult{RwError: RWErrorExiting, Error: err}
+	}
+	return &RwResult{RwError: RWErrorOs, Error: err}
+}
+
+// checkWritePossibility is checkRwPossibility for writes, it also rejects exiting disks
+// and runs the retention policy when the disk is full.
+func (sp *StorageProvider) checkWritePossibility(di *axstorage.DiskItem) *RwResult {
+	state, rwPossible := sp.diskState(di)
+	if rwPossible != nil {
+		return rwPossible
+	}
+	rwPossible = rwPossibility(&state)
+	if rwPossible.RwError == RWErrorFull {
+		if report := sp.EnforceRetention(di); report != nil && report.Removed > 0 {
+			if state, rwPossible = sp.diskState(di); rwPossible != nil {
+				return rwPossible
+			}
+			rwPossible = rwPossibility(&state)
 		}
-		return &RwResult{RwError: RWErrorNone}
+	}
+	if rwPossible.RwError == RWErrorNone && state.Exiting {
+		return &RwResult{RwError: RWErrorExiting, Error: ErrStorageExiting}
 	}
 	return rwPossible
 }
@@ -95,7 +171,7 @@ func (sp *StorageProvider) WriteFile(di *axstorage.DiskItem, filePath string, co
 // It returns an RwResult indicating the outcome of the remove operation.
 func (sp *StorageProvider) RemoveFile(di *axstorage.DiskItem, filePath string) *RwResult {
 	var rwPossible *RwResult
-	if rwPossible = checkRwPossibility(di); rwPossible.RwError == RWErrorNone {
+	if rwPossible = sp.checkRwPossibility(di); rwPossible.RwError == RWErrorNone {
 		if err := os.Remove(filepath.Join(di.StoragePath, filePath)); err != nil {
 			return &RwResult{RwError: RWErrorOs, Error: err}
 		}
@@ -105,12 +181,13 @@ func (sp *StorageProvider) RemoveFile(di *axstorage.DiskItem, filePath string) *
 }
 
 // ReadFile reads the content of a specified file from the disk item.
+// A full or read only disk can still be read.
 // It returns an RwResult containing the read data and any errors that occurred.
 func (sp *StorageProvider) ReadFile(di *axstorage.DiskItem, filePath string) *RwResult {
 	var rwPossible *RwResult
 	var err error
 	var dat []byte
-	if rwPossible = checkRwPossibility(di); rwPossible.RwError == RWErrorNone {
+	if rwPossible = sp.checkReadPossibility(di); rwPossible.RwError == RWErrorNone {
 		if dat, err = os.ReadFile(filepath.Join(di.StoragePath, filePath)); err != nil {
 			return &RwResult{RwError: RWErrorOs, Error: err}
 		}
@@ -141,8 +218,14 @@ func (sp *StorageProvider) Open() error {
 			sp.app.Syslog.Warnf("Unable to create storage subscription callback: %s for storage: %s", err.Error(), storageId)
 		} else {
 			sp.app.Syslog.Infof("Successfully create storage subscription for storage: %s, subsciption-id: %d", storageId, subscriptionId)
-			sp.DiskItems = append(sp.DiskItems, axstorage.NewDiskItem(storageId, subscriptionId))
+			diskItem := axstorage.NewDiskItem(storageId, subscriptionId)
+			sp.diskItemsMu.Lock()
+			sp.DiskItems = append(sp.DiskItems, diskItem)
+			sp.diskItemsMu.Unlock()
+			sp.publishDiskItem(diskItem)
 			sp.subscribtions = append(sp.subscribtions, subscriptionId)
+			sp.rememberFlags(diskItem)
+			sp.emit(StorageEventAdded, diskItem, nil)
 		}
 	}
 	return nil
@@ -150,7 +233,7 @@ func (sp *StorageProvider) Open() error {
 
 // Get DiskItem by its storageId
 func (sp *StorageProvider) GetDiskItemById(storageId string) (*axstorage.DiskItem, bool) {
-	for _, d := range sp.DiskItems {
+	for _, d := range sp.diskItems() {
 		if string(d.StorageId) == storageId {
 			return d, true
 		}
@@ -189,8 +272,9 @@ func (sp *StorageProvider) ReleaseAll() {
 	}
 }
 
-// Close unsubscribes and release all storages/disks
+// Close stops the retention policies, unsubscribes and release all storages/disks
 func (sp *StorageProvider) Close() {
+	sp.stopRetention()
 	sp.UnsubscribeAll()
 	sp.ReleaseAll()
 }
@@ -198,7 +282,7 @@ func (sp *StorageProvider) Close() {
 // GetDiskItem searches for a DiskItem by its storageId among the managed storage devices.
 // It returns the found DiskItem and a boolean indicating whether the search was successful.
 func (sp *StorageProvider) GetDiskItem(storageId axstorage.StorageId) (*axstorage.DiskItem, bool) {
-	for _, d := range sp.DiskItems {
+	for _, d := range sp.diskItems() {
 		if d.StorageId == storageId {
 			return d, true
 		}
@@ -206,6 +290,36 @@ func (sp *StorageProvider) GetDiskItem(storageId axstorage.StorageId) (*axstorag
 	return nil, false
 }
 
+// publishDiskItem stores a copy of the disk item for goroutines other than the main loop,
+// it is called by the storage callbacks on the main loop after they changed the disk item.
+func (sp *StorageProvider) publishDiskItem(di *axstorage.DiskItem) {
+	copied := *di
+	sp.publishedDiskItem(di.StorageId).Store(&copied)
+}
+
+// publishedDiskItem returns the copy of the disk item that the storage callbacks published last,
+// it holds nil until the disk item was published.
+func (sp *StorageProvider) publishedDiskItem(storageId axstorage.StorageId) *atomic.Pointer[axstorage.DiskItem] {
+	sp.diskItemsMu.Lock()
+	defer sp.diskItemsMu.Unlock()
+	if sp.published == nil {
+		sp.published = map[axstorage.StorageId]*atomic.Pointer[axstorage.DiskItem]{}
+	}
+	p, ok := sp.published[storageId]
+	if !ok {
+		p = &atomic.Pointer[axstorage.DiskItem]{}
+		sp.published[storageId] = p
+	}
+	return p
+}
+
+// diskItems returns a snapshot of DiskItems for goroutines other than the main loop.
+func (sp *StorageProvider) diskItems() []*axstorage.DiskItem {
+	sp.diskItemsMu.Lock()
+	defer sp.diskItemsMu.Unlock()
+	return append([]*axstorage.DiskItem(nil), sp.DiskItems...)
+}
+
 // Setup prepares a given DiskItem for use by performing necessary initializations such as
 // setting up its directory structure and ensuring it's ready for read/write operations.
 // This method performs asynchronous setup and is intended to be called when the disk is
@@ -235,36 +349,41 @@ func (sp *StorageProvider) Setup(diskItem *axstorage.DiskItem) error {
 func setupCallback(storage *axstorage.AXStorage, userdata any, setupErr error) {
 	var err error
 	sup := userdata.(*storageUserData)
+	failed := func(err error) {
+		sup.storageProvider.app.Syslog.Warnf("Failed to setup disk: %s. Error: %s", sup.diskItem.StorageId, err.Error())
+		sup.storageProvider.emit(StorageEventSetupFailed, sup.diskItem, err)
+	}
 
 	if setupErr != nil {
-		sup.storageProvider.app.Syslog.Warnf("Failed to setup disk: %s. Error: %s", sup.diskItem.StorageId, setupErr.Error())
+		failed(setupErr)
 		return
 	}
 
 	if storage.Ptr == nil {
-		sup.storageProvider.app.Syslog.Warnf("Failed to setup disk: %s. Error: Storage ptr is NULL", sup.diskItem.StorageId)
+		failed(errors.New("Storage ptr is NULL"))
 		return
 	}
 
 	if sup.diskItem.StorageId, err = storage.GetStorageId(); err != nil {
-		sup.storageProvider.app.Syslog.Warnf("Failed to get storage_id %s. Error: %", sup.diskItem.StorageId, err.Error())
+		failed(fmt.Errorf("Failed to get storage_id: %w", err))
 		return
 	}
 
 	if sup.diskItem.StoragePath, err = storage.GetPath(); err != nil {
-		sup.storageProvider.app.Syslog.Warnf("Failed to get storage %s path. Error: %s", sup.diskItem.StorageId, err.Error())
+		failed(fmt.Errorf("Failed to get storage path: %w", err))
 		return
 	}
 
 	if sup.diskItem.StorageType, err = storage.GetType(); err != nil {
-		sup.storageProvider.app.Syslog.Warnf("Failed to get storage %s type. Error: %s", sup.diskItem.StorageId, err.Error())
+		failed(fmt.Errorf("Failed to get storage type: %w", err))
 		return
 	}
 	sup.diskItem.Storage = storage
 	sup.diskItem.Setup = true
-	if sup.storageProvider.UseChannelEvents {
-		sup.storageProvider.DiskItemsEvents <- sup.diskItem
-	}
+	sup.storageProvider.publishDiskItem(sup.diskItem)
+	sup.storageProvider.emit(StorageEventSetupComplete, sup.diskItem, nil)
+	sup.storageProvider.flushSpoolAsync()
+	sup.storageProvider.sendDiskItemEvent(sup.diskItem)
 }
 
 // storageUserData is a helper struct used to pass additional data to callbacks.
@@ -294,8 +413,10 @@ func releaseCallback(userdata any, err error) {
 		sup.storageProvider.app.Syslog.Warnf("Failed to release %s. Error %s.", sup.diskItem.StorageId, err.Error())
 	} else {
 		sup.diskItem.Setup = false
+		sup.storageProvider.publishDiskItem(sup.diskItem)
 		sup.storageProvider.app.Syslog.Infof("Release of %s was successful", sup.diskItem.StorageId)
 	}
+	sup.storageProvider.emit(StorageEventReleased, sup.diskItem, err)
 }
 
 // storageSubscribeCallback is a callback function for handling storage event subscriptions.
@@ -320,6 +441,8 @@ func storageSubscribeCallback(storageID axstorage.StorageId, userdata any, subsc
 		if err = axstorage.UpdateDiskItemEvents(diskItem); err != nil {
 			sp.app.Syslog.Warnf("Unable to update disk-item: %s", storageID)
 		}
+		sp.publishDiskItem(diskItem)
+		sp.emitFlagChanges(diskItem)
 	} else {
 		sp.app.Syslog.Warnf("Disk not found in storage provider: %s", storageID)
 		return
@@ -329,8 +452,9 @@ func storageSubscribeCallback(storageID axstorage.StorageId, userdata any, subsc
 	if err := sp.Setup(diskItem); err != nil {
 		sp.app.Syslog.Warnf("Unable to setup storage %s because: %s", diskItem.StorageId, err.Error())
 	}
-
-	if sp.UseChannelEvents {
-		sp.DiskItemsEvents <- diskItem
+	if diskUsable(diskItem) {
+		sp.flushSpoolAsync()
 	}
+
+	sp.sendDiskItemEvent(diskItem)
 }
@@ -0,0 +1,308 @@
+package acapapp
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axstorage"
+)
+
+// DefaultRetentionInterval is the interval in which retention policies are enforced when the policy does not define one.
+const DefaultRetentionInterval = time.Minute
+
+// RetentionPolicy limits the files the application keeps on a disk.
+// When a limit is exceeded the oldest files in the storage path of the application are deleted, zero values disable a limit.
+type RetentionPolicy struct {
+	MaxBytes       int64         // Maximum total size of all files of the application.
+	MaxAge         time.Duration // Files older than MaxAge are deleted.
+	MinFreePercent float64       // Minimum free space of the whole disk in percent.
+	Interval       time.Duration // Interval of the periodic enforcement, DefaultRetentionInterval if 0.
+}
+
+// RetentionReport describes a run of a retention policy, it is only emitted when files were deleted or the policy could not be met.
+type RetentionReport struct {
+	StorageId   axstorage.StorageId // Disk the policy was enforced on.
+	Removed     int                 // Number of deleted files.
+	FreedBytes  int64               // Size of the deleted files.
+	UsedBytes   int64               // Size of all remaining files of the application.
+	FreePercent float64             // Free space of the disk after the run, -1 if unknown.
+	Err         error               // Set if the policy could not be met or files could not be deleted.
+}
+
+// storageRetention holds the retention state of a StorageProvider.
+type storageRetention struct {
+	mu        sync.Mutex
+	policies  map[axstorage.StorageId]RetentionPolicy
+	lastRun   map[axstorage.StorageId]time.Time
+	stop      chan struct{}
+	channel   chan RetentionReport
+	callbacks []func(RetentionReport)
+}
+
+// retentionFile is a file found in the storage path of the application.
+type retentionFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// SetRetentionPolicy sets the retention policy of a disk and starts its periodic enforcement.
+// The policy is also enforced when a write finds the disk full.
+func (sp *StorageProvider) SetRetentionPolicy(storageId axstorage.StorageId, policy RetentionPolicy) {
+	r := &sp.retention
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.policies == nil {
+		r.policies = map[axstorage.StorageId]RetentionPolicy{}
+		r.lastRun = map[axstorage.StorageId]time.Time{}
+	}
+	r.policies[storageId] = policy
+	if r.stop == nil {
+		r.stop = make(chan struct{})
+		go sp.retentionLoop(r.stop)
+	}
+}
+
+// RemoveRetentionPolicy removes the retention policy of a disk.
+func (sp *StorageProvider) RemoveRetentionPolicy(storageId axstorage.StorageId) {
+	r := &sp.retention
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.policies, storageId)
+}
+
+// GetRetentionPolicy returns the retention policy of a disk.
+func (sp *StorageProvider) GetRetentionPolicy(storageId axstorage.StorageId) (RetentionPolicy, bool) {
+	r := &sp.retention
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	policy, ok := r.policies[storageId]
+	return policy, ok
+}
+
+// OnRetention registers a callback that is called for each retention report.
+func (sp *StorageProvider) OnRetention(callback func(RetentionReport)) {
+	r := &sp.retention
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.callbacks = append(r.callbacks, callback)
+}
+
+// RetentionReports returns a channel that receives each retention report.
+// The channel is buffered, reports are dropped if the receiver does not keep up.
+func (sp *StorageProvider) RetentionReports() <-chan RetentionReport {
+	r := &sp.retention
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.channel == nil {
+		r.channel = make(chan RetentionReport, 10)
+	}
+	return r.channel
+}
+
+// EnforceRetention applies the retention policy of the disk now.
+// It returns nil if the disk has no policy or is not set up.
+// The setup state and the storage path are taken from the copy of the disk item published by the storage callbacks.
+func (sp *StorageProvider) EnforceRetention(di *axstorage.DiskItem) *RetentionReport {
+	policy, ok := sp.GetRetentionPolicy(di.StorageId)
+	if !ok {
+		return nil
+	}
+	state := sp.publishedDiskItem(di.StorageId).Load()
+	if state == nil || !state.Setup || state.StoragePath == "" {
+		return nil
+	}
+
+	report := enforceRetention(state, policy, time.Now(), &sp.writers)
+	sp.retention.mu.Lock()
+	sp.retention.lastRun[di.StorageId] = time.Now()
+	sp.retention.mu.Unlock()
+
+	if report.Removed > 0 || report.Err != nil {
+		if report.Err != nil {
+			sp.app.Syslog.Warnf("Retention of %s: %s", di.StorageId, report.Err.Error())
+		} else {
+			sp.app.Syslog.Infof("Retention of %s removed %d files (%d bytes)", di.StorageId, report.Removed, report.FreedBytes)
+		}
+		sp.notifyRetention(*report)
+	}
+	return report
+}
+
+func (sp *StorageProvider) notifyRetention(report RetentionReport) {
+	r := &sp.retention
+	r.mu.Lock()
+	channel := r.channel
+	callbacks := append([]func(RetentionReport){}, r.callbacks...)
+	r.mu.Unlock()
+
+	if channel != nil {
+		select {
+		case channel <- report:
+		default:
+		}
+	}
+	for _, callback := range callbacks {
+		callback(report)
+	}
+}
+
+// retentionLoop enforces the policies in their intervals until stop is closed.
+func (sp *StorageProvider) retentionLoop(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, di := range sp.diskItems() {
+				if sp.retentionDue(di.StorageId, now) {
+					sp.EnforceRetention(di)
+				}
+			}
+		}
+	}
+}
+
+func (sp *StorageProvider) retentionDue(storageId axstorage.StorageId, now time.Time) bool {
+	r := &sp.retention
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	policy, ok := r.policies[storageId]
+	if !ok {
+		return false
+	}
+	interval := policy.Interval
+	if interval <= 0 {
+		interval = DefaultRetentionInterval
+	}
+	return now.Sub(r.lastRun[storageId]) >= interval
+}
+
+// stopRetention stops the periodic enforcement.
+func (sp *StorageProvider) stopRetention() {
+	r := &sp.retention
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+// enforceRetention deletes the oldest files in the storage path of the disk until the policy is met,
+// di must be a copy that is not changed meanwhile.
+// Directories that became empty are removed unless a writer is starting a file in them.
+func enforceRetention(di *axstorage.DiskItem, policy RetentionPolicy, now time.Time, writers *storageWriters) *RetentionReport {
+	report := &RetentionReport{StorageId: di.StorageId, FreePercent: -1}
+
+	files, err := retentionFiles(di.StoragePath)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+	for _, f := range files {
+		report.UsedBytes += f.size
+	}
+
+	var removeErr error
+	remove := func(f retentionFile) {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			removeErr = err
+			return
+		}
+		report.Removed++
+		report.FreedBytes += f.size
+		report.UsedBytes -= f.size
+		removeEmptyDirs(filepath.Dir(f.path), di.StoragePath, writers)
+	}
+
+	i := 0
+	if policy.MaxAge > 0 {
+		for ; i < len(files) && now.Sub(files[i].modTime) > policy.MaxAge; i++ {
+			remove(files[i])
+		}
+	}
+	if policy.MaxBytes > 0 {
+		for ; i < len(files) && report.UsedBytes > policy.MaxBytes; i++ {
+			remove(files[i])
+		}
+		if report.UsedBytes > policy.MaxBytes {
+			report.Err = fmt.Errorf("Unable to reduce used space to %d bytes, %d bytes used", policy.MaxBytes, report.UsedBytes)
+		}
+	}
+
+	free, total, err := diskSpace(di.StoragePath)
+	if err == nil && total > 0 {
+		// Deleted files are not always released by the file system immediately, so the freed bytes are counted manually.
+		freed := int64(0)
+		percent := func() float64 { return float64(free+freed) * 100 / float64(total) }
+		for ; policy.MinFreePercent > 0 && i < len(files) && percent() < policy.MinFreePercent; i++ {
+			before := report.FreedBytes
+			remove(files[i])
+			freed += report.FreedBytes - before
+		}
+		if policy.MinFreePercent > 0 && percent() < policy.MinFreePercent && report.Err == nil {
+			report.Err = fmt.Errorf("Unable to free %.1f%% of the disk, %.1f%% free", policy.MinFreePercent, percent())
+		}
+		if free2, total2, err := diskSpace(di.StoragePath); err == nil && total2 > 0 {
+			report.FreePercent = float64(free2) * 100 / float64(total2)
+		}
+	} else if policy.MinFreePercent > 0 && report.Err == nil {
+		report.Err = fmt.Errorf("Unable to get free space: %v", err)
+	}
+
+	if removeErr != nil && report.Err == nil {
+		report.Err = fmt.Errorf("Unable to remove file: %w", removeErr)
+	}
+	return report
+}
+
+// retentionFiles returns all regular files below root except files of running writes, oldest first.
+func retentionFiles(root string) ([]retentionFile, error) {
+	var files []retentionFile
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || isTempFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		files = append(files, retentionFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	return files, nil
+}
+
+// removeEmptyDirs removes dir and its parents up to root as long as they are empty and no writer uses them.
+func removeEmptyDirs(dir, root string, writers *storageWriters) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if !writers.removeIdleDir(dir) {
+			return
+		}
+	}
+}
+
+// diskSpace returns the bytes available to the application and the total bytes of the file system of path.
+func diskSpace(path string) (free int64, total int64, err error) {
+	usage, err := statfsUsage(path)
+	return usage.Free, usage.Total, err
+}
@@ -0,0 +1,229 @@
+package acapapp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axstorage"
+)
+
+// DefaultWriterCheckInterval is the interval in which a StorageWriter re-checks its disk when StorageProvider.WriterCheckInterval is 0.
+const DefaultWriterCheckInterval = 5 * time.Second
+
+var (
+	// ErrStorageExiting is returned by a StorageWriter when its disk is going to disappear, the partial file is removed.
+	ErrStorageExiting = errors.New("Storage/Disk is exiting")
+	// ErrWriterClosed is returned when a StorageWriter is used after Close or Abort.
+	ErrWriterClosed = errors.New("Storage writer is closed")
+)
+
+// tempFileSuffix marks files of running writes, they are ignored by retention policies.
+const tempFileSuffix = ".tmp"
+
+// StorageWriter streams data into a temporary file on a disk, which is moved to its final path on Close.
+// A reader of the final path never sees a partial file. It implements io.WriteCloser.
+type StorageWriter struct {
+	sp        *StorageProvider
+	di        *axstorage.DiskItem
+	path      string
+	dir       string                              // Directory of path, registered in storageWriters until the write ends.
+	state     *atomic.Pointer[axstorage.DiskItem] // State of the disk published by the storage callbacks on the main loop.
+	tmp       *os.File
+	interval  time.Duration
+	lastCheck time.Time
+	written   int64
+	mu        sync.Mutex
+	err       error
+}
+
+// OpenWriter creates a StorageWriter for filePath on the disk item, missing parent directories are created.
+// The disk is re-checked while writing, if it becomes unusable or starts exiting the write is aborted and the partial file is removed.
+func (sp *StorageProvider) OpenWriter(di *axstorage.DiskItem, filePath string) (*StorageWriter, error) {
+	if rwPossible := sp.checkWritePossibility(di); rwPossible.RwError != RWErrorNone {
+		return nil, rwPossible.Error
+	}
+	return sp.openWriter(di, filePath)
+}
+
+// openWriter creates the StorageWriter without checking the disk first.
+// The storage path is taken from the copy of the disk item published by the storage callbacks.
+func (sp *StorageProvider) openWriter(di *axstorage.DiskItem, filePath string) (*StorageWriter, error) {
+	state := sp.publishedDiskItem(di.StorageId)
+	published := state.Load()
+	if published == nil || published.StoragePath == "" {
+		return nil, fmt.Errorf("Storage path of %s is not known", di.StorageId)
+	}
+	path := filepath.Join(published.StoragePath, filePath)
+	dir := filepath.Dir(path)
+	// Register the directory before creating it, so retention does not remove it before the temporary file exists.
+	sp.writers.add(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		sp.writers.done(dir)
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*"+tempFileSuffix)
+	if err != nil {
+		sp.writers.done(dir)
+		return nil, err
+	}
+
+	interval := sp.WriterCheckInterval
+	if interval <= 0 {
+		interval = DefaultWriterCheckInterval
+	}
+	return &StorageWriter{
+		sp:        sp,
+		di:        di,
+		path:      path,
+		dir:       dir,
+		state:     state,
+		tmp:       tmp,
+		interval:  interval,
+		lastCheck: time.Now(),
+	}, nil
+}
+
+// Write writes p to the temporary file.
+func (w *StorageWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	if err := w.check(); err != nil {
+		return 0, err
+	}
+	n, err := w.tmp.Write(p)
+	w.written += int64(n)
+	if err != nil {
+		w.abort(err)
+	}
+	return n, err
+}
+
+// Written returns the number of bytes written so far.
+func (w *StorageWriter) Written() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+// Close syncs the temporary file to the disk and renames it to the final path, an existing file is replaced.
+func (w *StorageWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		if w.err == ErrWriterClosed {
+			return nil
+		}
+		return w.err
+	}
+	if err := w.check(); err != nil {
+		return err
+	}
+	if err := w.tmp.Sync(); err != nil {
+		return w.abort(err)
+	}
+	if err := w.tmp.Close(); err != nil {
+		return w.abort(err)
+	}
+	defer w.sp.writers.done(w.dir)
+	if err := os.Rename(w.tmp.Name(), w.path); err != nil {
+		os.Remove(w.tmp.Name())
+		w.err = err
+		return err
+	}
+	w.err = ErrWriterClosed
+	// Persist the rename, the data itself is already synced.
+	if dir, err := os.Open(filepath.Dir(w.path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// Abort stops the write and removes the temporary file, the final path is not touched.
+func (w *StorageWriter) Abort() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.abort(ErrWriterClosed)
+	}
+}
+
+// check re-checks the disk when the check interval elapsed, it must be called with mu held.
+// The writer runs outside of the main loop, so it only reads the state the storage callbacks published and never the DiskItem itself.
+func (w *StorageWriter) check() error {
+	state := w.state.Load()
+	if state == nil {
+		return nil
+	}
+	// Exiting is published by the storage subscription, it is checked on every call.
+	if state.Exiting {
+		return w.abort(ErrStorageExiting)
+	}
+	if time.Since(w.lastCheck) < w.interval {
+		return nil
+	}
+	w.lastCheck = time.Now()
+	if rwPossible := rwPossibility(state); rwPossible.RwError != RWErrorNone {
+		return w.abort(rwPossible.Error)
+	}
+	return nil
+}
+
+// abort closes and removes the temporary file and keeps err for all further calls, it must be called with mu held.
+func (w *StorageWriter) abort(err error) error {
+	w.tmp.Close()
+	os.Remove(w.tmp.Name())
+	w.sp.writers.done(w.dir)
+	w.err = err
+	if err != ErrWriterClosed {
+		w.sp.app.Syslog.Warnf("Aborted write of %s on %s: %s", w.path, w.di.StorageId, err.Error())
+	}
+	return err
+}
+
+// storageWriters counts the running writes per directory, so retention does not remove a directory a write is starting in.
+type storageWriters struct {
+	mu   sync.Mutex
+	dirs map[string]int
+}
+
+func (ws *storageWriters) add(dir string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.dirs == nil {
+		ws.dirs = map[string]int{}
+	}
+	ws.dirs[dir]++
+}
+
+func (ws *storageWriters) done(dir string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.dirs[dir]--; ws.dirs[dir] <= 0 {
+		delete(ws.dirs, dir)
+	}
+}
+
+// removeIdleDir removes the empty directory if no write runs in it, it reports whether the directory was removed.
+func (ws *storageWriters) removeIdleDir(dir string) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.dirs[dir] > 0 {
+		return false
+	}
+	return os.Remove(dir) == nil
+}
+
+// isTempFile reports whether the file name belongs to a running StorageWriter.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempFileSuffix)
+}
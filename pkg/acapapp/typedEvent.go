@@ -0,0 +1,136 @@
+package acapapp
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/Cacsjep/goxis/pkg/axevent"
+)
+
+// TypedEvent is a declared Camera Application Platform event whose keys are described by the struct type T.
+type TypedEvent[T any] struct {
+	ID     int    // The declaration id of the event.
+	Name   string // The name of the event, the last topic of the declaration.
+	app    *AcapApplication
+	fields []eventField
+}
+
+// EventOption configures an event declared with DeclareEvent.
+type EventOption func(*eventOptions)
+
+type eventOptions struct {
+	niceName  *string
+	stateless bool
+}
+
+// WithEventNiceName sets the human readable name of the event.
+func WithEventNiceName(niceName string) EventOption {
+	return func(o *eventOptions) {
+		o.niceName = &niceName
+	}
+}
+
+// WithStatelessEvent declares the event as stateless, see CameraPlatformEvent.Stateless.
+func WithStatelessEvent() EventOption {
+	return func(o *eventOptions) {
+		o.stateless = true
+	}
+}
+
+// DeclareEvent declares a Camera Application Platform event with the keys described by the fields of T.
+// Keys, namespaces, source and data marks and nice names are taken from the struct tags of T:
+//
+//	type MotionEvent struct {
+//		Zone   int32 `eventKey:"zone,source" keyNiceName:"Zone"`
+//		Active bool  `eventKey:"active,data" keyNiceName:"Active"`
+//	}
+//
+// Booleans, strings and all integer and float types are supported, integers are sent as event integers and floats as doubles.
+// Pointer fields and omitempty fields are left out of sent events when empty, a time.Time field sets the event timestamp.
+// A stateful event is declared with the zero value of T as initial state, use DeclareEventWithState to declare another one.
+// The event is undeclared when the application closes.
+func DeclareEvent[T any](a *AcapApplication, name string, opts ...EventOption) (*TypedEvent[T], error) {
+	var initial T
+	return DeclareEventWithState(a, name, initial, opts...)
+}
+
+// DeclareEventWithState declares a Camera Application Platform event like DeclareEvent with the given initial state.
+// The initial state is ignored for stateless events, their keys are declared without values.
+func DeclareEventWithState[T any](a *AcapApplication, name string, initial T, opts ...EventOption) (*TypedEvent[T], error) {
+	o := &eventOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	fields, err := parseEventFields(reflect.TypeOf(initial))
+	if err != nil {
+		return nil, err
+	}
+
+	iv := reflect.ValueOf(initial)
+	entries := make([]*EventEntry, 0, len(fields))
+	for _, f := range fields {
+		if f.timestamp {
+			continue
+		}
+		entry := &EventEntry{
+			Key:           f.key,
+			Namespace:     f.namespace,
+			ValueType:     f.valueType,
+			UserDefined:   f.userDefined,
+			KeyNiceName:   f.keyNiceName,
+			ValueNiceName: f.valueNiceName,
+		}
+		if f.source {
+			entry.IsSource = &f.source
+		}
+		if f.data {
+			entry.IsData = &f.data
+		}
+		if !o.stateless {
+			if entry.Value, err = eventValue(iv.Field(f.index)); err != nil {
+				return nil, fmt.Errorf("key %s: %w", f.key, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	id, err := a.AddCameraPlatformEvent(&CameraPlatformEvent{
+		Name:      name,
+		NiceName:  o.niceName,
+		Entries:   entries,
+		Stateless: o.stateless,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TypedEvent[T]{ID: id, Name: name, app: a, fields: fields}, nil
+}
+
+// NewEvent builds an AXEvent from v, the caller is responsible to free it.
+// Empty fields are left out and a time.Time field of T sets the timestamp of the event.
+func (e *TypedEvent[T]) NewEvent(v T) (*axevent.AXEvent, error) {
+	sv := reflect.ValueOf(v)
+	entries, err := eventEntries(e.fields, sv, false)
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := newKeyValueSet(entries)
+	if err != nil {
+		return nil, err
+	}
+	return axevent.NewAxEvent(kvs, eventTimestamp(e.fields, sv)), nil
+}
+
+// Send builds an AXEvent from v and sends it.
+func (e *TypedEvent[T]) Send(v T) error {
+	if e.app.EventHandler == nil {
+		return ErrNoEventHandler
+	}
+	event, err := e.NewEvent(v)
+	if err != nil {
+		return err
+	}
+	defer event.Free()
+	return e.app.EventHandler.SendEvent(e.ID, event)
+}
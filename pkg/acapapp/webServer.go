@@ -0,0 +1,508 @@
+package acapapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Cacsjep/goxis/pkg/axmanifest"
+)
+
+// Access levels of a reverse proxy configuration in the manifest.
+const (
+	AccessAnonymous = "anonymous"
+	AccessViewer    = "viewer"
+	AccessOperator  = "operator"
+	AccessAdmin     = "admin"
+)
+
+// RoleResolver returns the access level of the user of a request, false rejects the request.
+type RoleResolver func(r *http.Request) (role string, ok bool)
+
+// WebServer is an HTTP server bound to the target of a reverse proxy configuration of the manifest.
+// All handlers are mounted below BasePath, which is /local/<appname>/<apipath>.
+//
+// The target must be a loopback address or a unix socket, so requests can only reach the server through
+// the reverse proxy of the device, which authenticates users for the declared Access level.
+// Every request needs at least Access, handlers registered with HandleAccess can require a higher level
+// if the RoleResolver is able to grant it.
+type WebServer struct {
+	BasePath     string       // Path under which the reverse proxy forwards requests, /local/<appname>/<apipath>.
+	Network      string       // Network of the listener, tcp or unix.
+	Address      string       // Address of the listener, host:port or the path of the unix socket.
+	Access       string       // Access level declared for the reverse proxy.
+	RoleResolver RoleResolver // Returns the access level of a request, defaults to ReverseProxyRole, set it with WithRoleResolver.
+	proxy        axmanifest.ReverseProxyItem
+	mux          *http.ServeMux
+	server       *http.Server
+	listener     net.Listener
+	app          *AcapApplication
+	endpoints    map[string]endpoint // Opt-in endpoints, mounted once BasePath is known.
+	customRoles  bool                // Set by WithRoleResolver, the resolver may grant more than Access.
+}
+
+// endpoint is an opt-in handler with the access level it requires, empty for the Access of the WebServer.
+type endpoint struct {
+	access      string
+	writeAccess string // Access level the handler checks itself for writing requests, empty if it has none.
+	handler     http.HandlerFunc
+}
+
+// WebServerOption configures a WebServer created by NewWebServer.
+type WebServerOption func(*WebServer) error
+
+// WithReverseProxy selects the reverse proxy configuration by its apiPath, default is the first one of the manifest.
+func WithReverseProxy(apiPath string) WebServerOption {
+	return func(ws *WebServer) error {
+		for _, rp := range ws.app.Manifest.ACAPPackageConf.Configuration.ReverseProxy {
+			if rp.ApiPath == apiPath {
+				ws.proxy = rp
+				return nil
+			}
+		}
+		return fmt.Errorf("No reverse proxy configuration with apiPath %s in manifest", apiPath)
+	}
+}
+
+// WithRoleResolver replaces the default ReverseProxyRole, for example to look up the role of the forwarded user.
+func WithRoleResolver(resolver RoleResolver) WebServerOption {
+	return func(ws *WebServer) error {
+		ws.RoleResolver = resolver
+		ws.customRoles = true
+		return nil
+	}
+}
+
+// WithSnapshotEndpoint serves JPEG snapshots at <BasePath>/snapshot, the video channel is selected by the query parameter channel, default is 1.
+func WithSnapshotEndpoint() WebServerOption {
+	return func(ws *WebServer) error {
+		ws.endpoints["/snapshot"] = endpoint{handler: ws.handleSnapshot}
+		return nil
+	}
+}
+
+// WithStreamStatsEndpoint serves the FrameProviderStats of all running frame providers as JSON at <BasePath>/stats.
+func WithStreamStatsEndpoint() WebServerOption {
+	return func(ws *WebServer) error {
+		ws.endpoints["/stats"] = endpoint{handler: ws.handleStreamStats}
+		return nil
+	}
+}
+
+// ParametersEndpoint configures the parameters exposed by WithParametersEndpoint.
+type ParametersEndpoint struct {
+	Readable    []string // Parameters returned by a GET, all parameters if empty.
+	Writable    []string // Parameters a POST may set, a POST is rejected if empty.
+	WriteAccess string   // Access level a POST requires, default is AccessOperator.
+}
+
+// WithParametersEndpoint serves the application parameters as JSON at <BasePath>/parameters.
+// A GET returns the readable parameters, a POST with form values sets writable parameters.
+// All values of a POST are validated before any is set, a failing set restores the parameters set before.
+// NewWebServer fails if the RoleResolver can not grant WriteAccess, for example AccessOperator under a viewer reverse proxy.
+func WithParametersEndpoint(config ParametersEndpoint) WebServerOption {
+	return func(ws *WebServer) error {
+		if config.WriteAccess == "" {
+			config.WriteAccess = AccessOperator
+		}
+		e := endpoint{handler: func(w http.ResponseWriter, r *http.Request) {
+			ws.handleParameters(w, r, config)
+		}}
+		if len(config.Writable) > 0 {
+			e.writeAccess = config.WriteAccess
+		}
+		ws.endpoints["/parameters"] = e
+		return nil
+	}
+}
+
+// WithMJPEGEndpoint serves a multipart/x-mixed-replace stream at <BasePath>/mjpeg, see FrameProvider.MJPEGHandler.
+// The FrameProvider is selected by its name with the query parameter provider, default is DefaultFrameProviderName.
+func WithMJPEGEndpoint(opts MJPEGOptions) WebServerOption {
+	return func(ws *WebServer) error {
+		ws.endpoints["/mjpeg"] = endpoint{handler: func(w http.ResponseWriter, r *http.Request) {
+			ws.handleMJPEG(w, r, opts)
+		}}
+		return nil
+	}
+}
+
+// NewWebServer creates a WebServer for the reverse proxy configuration of the manifest.
+// The target of the configuration determines where the server listens, for example http://localhost:2001
+// or unix:/tmp/app.sock, other hosts than loopback addresses are rejected.
+// The server is not listening until Start is called and is stopped when the application shuts down.
+func (a *AcapApplication) NewWebServer(opts ...WebServerOption) (*WebServer, error) {
+	proxies := a.Manifest.ACAPPackageConf.Configuration.ReverseProxy
+	if len(proxies) == 0 {
+		return nil, errors.New("No reverse proxy configuration set in manifest")
+	}
+
+	ws := &WebServer{
+		proxy:     proxies[0],
+		mux:       http.NewServeMux(),
+		app:       a,
+		endpoints: make(map[string]endpoint),
+	}
+	ws.RoleResolver = ws.ReverseProxyRole
+	for _, opt := range opts {
+		if err := opt(ws); err != nil {
+			return nil, err
+		}
+	}
+
+	network, address, err := ParseReverseProxyTarget(ws.proxy.Target)
+	if err != nil {
+		return nil, err
+	}
+	if network == "tcp" && !isLoopbackAddress(address) {
+		return nil, fmt.Errorf("Reverse proxy target %s is not a loopback address", ws.proxy.Target)
+	}
+	ws.Network = network
+	ws.Address = address
+	ws.Access = ws.proxy.Access
+	ws.BasePath = fmt.Sprintf("/local/%s/%s", a.Manifest.ACAPPackageConf.Setup.AppName, strings.Trim(ws.proxy.ApiPath, "/"))
+	// Long running requests like streams see their context cancelled when the server shuts down.
+	baseCtx, cancel := context.WithCancel(context.Background())
+	ws.server = &http.Server{
+		Handler:     http.HandlerFunc(ws.serveHTTP),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	ws.server.RegisterOnShutdown(cancel)
+	for pattern, e := range ws.endpoints {
+		if err := ws.checkAccess(e.writeAccess); err != nil {
+			return nil, fmt.Errorf("Endpoint %s: %w", pattern, err)
+		}
+		if err := ws.HandleAccess(pattern, e.access, e.handler); err != nil {
+			return nil, fmt.Errorf("Endpoint %s: %w", pattern, err)
+		}
+	}
+	return ws, nil
+}
+
+// ParseReverseProxyTarget returns the network and address to listen on for a reverse proxy target.
+// Targets with the scheme unix are unix domain sockets, http and ws targets are TCP addresses.
+func ParseReverseProxyTarget(target string) (network string, address string, err error) {
+	if strings.HasPrefix(target, "unix:") {
+		socket := "/" + strings.TrimLeft(strings.TrimPrefix(target, "unix:"), "/")
+		if socket == "/" {
+			return "", "", fmt.Errorf("Invalid unix socket target %s", target)
+		}
+		return "unix", socket, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid reverse proxy target %s: %w", target, err)
+	}
+	switch u.Scheme {
+	case "http", "ws":
+	default:
+		return "", "", fmt.Errorf("Unsupported scheme %s in reverse proxy target %s", u.Scheme, target)
+	}
+	if u.Port() == "" {
+		return "", "", fmt.Errorf("Reverse proxy target %s has no port", target)
+	}
+	return "tcp", u.Host, nil
+}
+
+// accessLevel maps an access level to a rank, higher ranks include the lower ones.
+func accessLevel(access string) int {
+	switch access {
+	case AccessViewer:
+		return 1
+	case AccessOperator:
+		return 2
+	case AccessAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// isLoopbackAddress reports whether the host of a host:port address is localhost or a loopback IP.
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ReverseProxyRole is the default RoleResolver. The listener only accepts local connections, which the
+// reverse proxy of the device forwards after it authenticated the user for the declared Access level.
+// The role of the user is not forwarded, so a request has exactly the Access level of the WebServer,
+// HandleAccess refuses handlers that require more. Requests from other addresses are anonymous.
+func (ws *WebServer) ReverseProxyRole(r *http.Request) (string, bool) {
+	if ws.Network == "unix" {
+		return ws.Access, true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if ip := net.ParseIP(host); err == nil && ip != nil && ip.IsLoopback() {
+		return ws.Access, true
+	}
+	return AccessAnonymous, true
+}
+
+// allowed reports whether the role of the request includes the access level.
+func (ws *WebServer) allowed(r *http.Request, access string) bool {
+	if ws.RoleResolver == nil {
+		return accessLevel(access) == 0
+	}
+	role, ok := ws.RoleResolver(r)
+	return ok && accessLevel(role) >= accessLevel(access)
+}
+
+func (ws *WebServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !ws.allowed(r, ws.Access) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	ws.mux.ServeHTTP(w, r)
+}
+
+// Handle registers the handler for the pattern relative to BasePath, for example /events.
+// Like http.ServeMux a pattern ending with a slash matches the whole subtree.
+func (ws *WebServer) Handle(pattern string, handler http.Handler) {
+	ws.mux.Handle(ws.mountPath(pattern), handler)
+}
+
+// HandleFunc registers the handler function for the pattern relative to BasePath.
+func (ws *WebServer) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	ws.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// HandleAccess registers the handler for the pattern relative to BasePath, requests need the given access level
+// in addition to the Access of the WebServer. An empty access level only requires the Access of the WebServer.
+// It fails for unknown access levels and, with the default ReverseProxyRole, for levels above the Access of the
+// WebServer, because such a handler could never be reached.
+func (ws *WebServer) HandleAccess(pattern string, access string, handler http.HandlerFunc) error {
+	if err := ws.checkAccess(access); err != nil {
+		return err
+	}
+	if access == "" {
+		ws.Handle(pattern, handler)
+		return nil
+	}
+	ws.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if !ws.allowed(r, access) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	})
+	return nil
+}
+
+// checkAccess returns an error if the access level is unknown or can not be granted by ReverseProxyRole.
+func (ws *WebServer) checkAccess(access string) error {
+	switch access {
+	case "", AccessAnonymous, AccessViewer, AccessOperator, AccessAdmin:
+	default:
+		return fmt.Errorf("Unknown access level %s", access)
+	}
+	if !ws.customRoles && accessLevel(access) > accessLevel(ws.Access) {
+		return fmt.Errorf("Access level %s exceeds the %s access of reverse proxy %s, use WithRoleResolver to grant it", access, ws.Access, ws.proxy.ApiPath)
+	}
+	return nil
+}
+
+func (ws *WebServer) mountPath(pattern string) string {
+	mounted := path.Join(ws.BasePath, pattern)
+	if strings.HasSuffix(pattern, "/") && !strings.HasSuffix(mounted, "/") {
+		mounted += "/"
+	}
+	return mounted
+}
+
+// Start listens on the reverse proxy target and serves requests in the background.
+// A stale unix socket of a previous run is removed before listening.
+func (ws *WebServer) Start() error {
+	if ws.listener != nil {
+		return errors.New("WebServer is already started")
+	}
+	if ws.Network == "unix" {
+		if err := os.Remove(ws.Address); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	listener, err := net.Listen(ws.Network, ws.Address)
+	if err != nil {
+		return err
+	}
+	ws.listener = listener
+
+	if err := ws.app.AddCleaner(Cleaner{
+		Name:   "webserver-" + ws.proxy.ApiPath,
+		Before: []string{CleanerFrameProviders},
+		Clean:  ws.Stop,
+	}); err != nil {
+		ws.app.Syslog.Warnf("WebServer(%s): %s", ws.BasePath, err.Error())
+	}
+
+	go func() {
+		if err := ws.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ws.app.Syslog.Errorf("WebServer(%s): %s", ws.BasePath, err.Error())
+		}
+	}()
+	ws.app.Syslog.Infof("WebServer(%s): Listening on %s %s", ws.BasePath, ws.Network, ws.Address)
+	return nil
+}
+
+// Stop gracefully shuts down the server, waiting for active requests until ctx is done.
+func (ws *WebServer) Stop(ctx context.Context) error {
+	if ws.listener == nil {
+		return nil
+	}
+	err := ws.server.Shutdown(ctx)
+	if ws.Network == "unix" {
+		os.Remove(ws.Address)
+	}
+	return err
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (ws *WebServer) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	channel := 1
+	if c := r.URL.Query().Get("channel"); c != "" {
+		var err error
+		if channel, err = strconv.Atoi(c); err != nil {
+			http.Error(w, "Invalid channel", http.StatusBadRequest)
+			return
+		}
+	}
+	jpeg, err := ws.app.GetSnapshot(channel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(jpeg)
+}
+
+func (ws *WebServer) handleStreamStats(w http.ResponseWriter, r *http.Request) {
+	stats := make(map[string]*FrameProviderStats)
+	for _, fp := range ws.app.FrameProviders() {
+		if !fp.IsRunning() {
+			continue
+		}
+		if s, err := fp.Stats(); err == nil {
+			stats[fp.Name] = s
+		}
+	}
+	writeJSON(w, stats)
+}
+
+func (ws *WebServer) handleMJPEG(w http.ResponseWriter, r *http.Request, opts MJPEGOptions) {
+	name := r.URL.Query().Get("provider")
+	if name == "" {
+		name = DefaultFrameProviderName
+	}
+	fp, found := ws.app.GetFrameProvider(name)
+	if !found || !fp.IsRunning() {
+		http.Error(w, fmt.Sprintf("FrameProvider %s is not running", name), http.StatusNotFound)
+		return
+	}
+	fp.serveMJPEG(w, r, opts.normalized())
+}
+
+func (ws *WebServer) handleParameters(w http.ResponseWriter, r *http.Request, config ParametersEndpoint) {
+	writable := func(name string) bool {
+		for _, n := range config.Writable {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ws.app.ParamHandler == nil {
+		http.Error(w, "Parameter handler is not initialized", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		params := config.Readable
+		if len(params) == 0 {
+			var err error
+			if params, err = ws.app.ParamHandler.List(); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		values := make(map[string]string, len(params))
+		for _, name := range params {
+			value, err := ws.app.ParamHandler.Get(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			values[name] = value
+		}
+		writeJSON(w, values)
+	case http.MethodPost:
+		if !ws.allowed(r, config.WriteAccess) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Validate all values before the first one is set, so a bad request changes nothing.
+		previous := make(map[string]string, len(r.PostForm))
+		for name, values := range r.PostForm {
+			if !writable(name) {
+				http.Error(w, fmt.Sprintf("Parameter %s is not writable", name), http.StatusForbidden)
+				return
+			}
+			if len(values) != 1 {
+				http.Error(w, fmt.Sprintf("Parameter %s has %d values", name, len(values)), http.StatusBadRequest)
+				return
+			}
+			value, err := ws.app.ParamHandler.Get(name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			previous[name] = value
+		}
+		var applied []string
+		for name, values := range r.PostForm {
+			if err := ws.app.ParamHandler.Set(name, values[0], true); err != nil {
+				for _, n := range applied {
+					if rerr := ws.app.ParamHandler.Set(n, previous[n], true); rerr != nil {
+						ws.app.Syslog.Errorf("WebServer(%s): restore parameter %s: %s", ws.BasePath, n, rerr.Error())
+					}
+				}
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			applied = append(applied, name)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -16,14 +16,11 @@ package axevent
 // 	</tnsaxis:IO>
 // </tns1:Device>
 func DeviceIoSupervisedPortEventKvs(port *int, tampered *bool, state *string) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "SupervisedPort"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceIoSupervisedPortEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("port", port),
 		NewBoolKeyValueEntrie("tampered", tampered),
 		NewStringKeyValueEntrie("state", state),
-	})
+	))
 }
 
 type DeviceIoSupervisedPortEvent struct {
@@ -32,6 +29,15 @@ type DeviceIoSupervisedPortEvent struct {
 	State    string `eventKey:"state"`
 }
 
+// EventTopics returns the topics of the DeviceIoSupervisedPortEvent declaration.
+func (DeviceIoSupervisedPortEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "SupervisedPort"),
+	}
+}
+
 // <tns1:Device>
 // 	<tnsaxis:IO>
 //		<VirtualPort wstop:topic="true">
@@ -47,13 +53,10 @@ type DeviceIoSupervisedPortEvent struct {
 // 	</tnsaxis:IO>
 // </tns1:Device>
 func DeviceIoVirtualPortEventKvs(port *int, state *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "VirtualPort"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceIoVirtualPortEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("port", port),
 		NewBoolKeyValueEntrie("state", state),
-	})
+	))
 }
 
 type DeviceIoVirtualPortEvent struct {
@@ -61,6 +64,15 @@ type DeviceIoVirtualPortEvent struct {
 	State bool `eventKey:"state"`
 }
 
+// EventTopics returns the topics of the DeviceIoVirtualPortEvent declaration.
+func (DeviceIoVirtualPortEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "VirtualPort"),
+	}
+}
+
 // <tns1:Device>
 // 	<tnsaxis:IO>
 //		<OutputPort wstop:topic="true">
@@ -76,13 +88,10 @@ type DeviceIoVirtualPortEvent struct {
 // 	</tnsaxis:IO>
 // </tns1:Device>
 func DeviceIoOutputPortEventKvs(port *int, state *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "OutputPort"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceIoOutputPortEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("port", port),
 		NewBoolKeyValueEntrie("state", state),
-	})
+	))
 }
 
 type DeviceIoOutputPortEvent struct {
@@ -90,6 +99,15 @@ type DeviceIoOutputPortEvent struct {
 	State bool `eventKey:"state"`
 }
 
+// EventTopics returns the topics of the DeviceIoOutputPortEvent declaration.
+func (DeviceIoOutputPortEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "OutputPort"),
+	}
+}
+
 // <tns1:Device>
 // 	<tnsaxis:IO>
 //		<VirtualInput wstop:topic="true">
@@ -105,13 +123,10 @@ type DeviceIoOutputPortEvent struct {
 // 	</tnsaxis:IO>
 // </tns1:Device>
 func DeviceIoVirtualInputEventKvs(port *int, active *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "VirtualInput"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceIoVirtualInputEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("port", port),
 		NewBoolKeyValueEntrie("active", active),
-	})
+	))
 }
 
 type DeviceIoVirtualInputEvent struct {
@@ -119,6 +134,15 @@ type DeviceIoVirtualInputEvent struct {
 	Active bool `eventKey:"active"`
 }
 
+// EventTopics returns the topics of the DeviceIoVirtualInputEvent declaration.
+func (DeviceIoVirtualInputEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "VirtualInput"),
+	}
+}
+
 // <tns1:Device>
 // 	<tnsaxis:IO>
 //		<Port wstop:topic="true">
@@ -134,13 +158,10 @@ type DeviceIoVirtualInputEvent struct {
 // 	</tnsaxis:IO>
 // </tns1:Device>
 func DeviceIoPortEventKvs(port *int, state *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Port"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceIoPortEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("port", port),
 		NewBoolKeyValueEntrie("state", state),
-	})
+	))
 }
 
 type DeviceIoPortEvent struct {
@@ -148,6 +169,15 @@ type DeviceIoPortEvent struct {
 	State bool `eventKey:"state"`
 }
 
+// EventTopics returns the topics of the DeviceIoPortEvent declaration.
+func (DeviceIoPortEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "IO"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Port"),
+	}
+}
+
 // <tns1:Device>
 //	<tnsaxis:Sensor>
 //		<PIR wstop:topic="true">
@@ -163,13 +193,10 @@ type DeviceIoPortEvent struct {
 //	</tnsaxis:Sensor>
 // </tns1:Device>
 func DeviceSensorPIREventKvs(sensor *int, state *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Sensor"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "PIR"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceSensorPIREvent{}.EventTopics(),
 		NewIntKeyValueEntrie("sensor", sensor),
 		NewBoolKeyValueEntrie("state", state),
-	})
+	))
 }
 
 type DeviceSensorPIREvent struct {
@@ -177,6 +204,15 @@ type DeviceSensorPIREvent struct {
 	State  bool `eventKey:"state"`
 }
 
+// EventTopics returns the topics of the DeviceSensorPIREvent declaration.
+func (DeviceSensorPIREvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Sensor"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "PIR"),
+	}
+}
+
 // <tns1:Device>
 //	<tnsaxis:Light>
 //		<Status wstop:topic="true">
@@ -192,13 +228,10 @@ type DeviceSensorPIREvent struct {
 //	</tnsaxis:Light>
 // </tns1:Device>
 func DeviceLightStatusEventKvs(id *int, state *string) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Light"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Status"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceLightStatusEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("id", id),
 		NewStringKeyValueEntrie("state", state),
-	})
+	))
 }
 
 type DeviceLightStatusEvent struct {
@@ -206,6 +239,15 @@ type DeviceLightStatusEvent struct {
 	State string `eventKey:"state"`
 }
 
+// EventTopics returns the topics of the DeviceLightStatusEvent declaration.
+func (DeviceLightStatusEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Light"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Status"),
+	}
+}
+
 // <tns1:Device>
 //	<tnsaxis:Status>
 //		<SystemReady wstop:topic="true">
@@ -218,18 +260,24 @@ type DeviceLightStatusEvent struct {
 //	</tnsaxis:Status>
 // </tns1:Device>
 func DeviceStatusSystemReadyEventKvs(ready *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "SystemReady"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceStatusSystemReadyEvent{}.EventTopics(),
 		NewBoolKeyValueEntrie("ready", ready),
-	})
+	))
 }
 
 type DeviceStatusSystemReadyEvent struct {
 	Ready bool `eventKey:"ready"`
 }
 
+// EventTopics returns the topics of the DeviceStatusSystemReadyEvent declaration.
+func (DeviceStatusSystemReadyEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "SystemReady"),
+	}
+}
+
 // <tns1:Device>
 //	<tnsaxis:Status>
 //		<Temperature>
@@ -244,19 +292,25 @@ type DeviceStatusSystemReadyEvent struct {
 //	</tnsaxis:Status>
 // </tns1:Device>
 func DeviceStatusTemperatureInsideEventKvs(sensor_level *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Temperature"),
-		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "Inside"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceStatusTemperatureInsideEvent{}.EventTopics(),
 		NewBoolKeyValueEntrie("sensor_level", sensor_level),
-	})
+	))
 }
 
 type DeviceStatusTemperatureInsideEvent struct {
 	SensorLevel bool `eventKey:"sensor_level"`
 }
 
+// EventTopics returns the topics of the DeviceStatusTemperatureInsideEvent declaration.
+func (DeviceStatusTemperatureInsideEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Temperature"),
+		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "Inside"),
+	}
+}
+
 // <tns1:Device>
 //	<tnsaxis:Status>
 //		<Temperature>
@@ -271,19 +325,25 @@ type DeviceStatusTemperatureInsideEvent struct {
 //	</tnsaxis:Status>
 // </tns1:Device>
 func DeviceStatusTemperatureAboveEventKvs(sensor_level *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Temperature"),
-		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "Above"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceStatusTemperatureAboveEvent{}.EventTopics(),
 		NewBoolKeyValueEntrie("sensor_level", sensor_level),
-	})
+	))
 }
 
 type DeviceStatusTemperatureAboveEvent struct {
 	SensorLevel bool `eventKey:"sensor_level"`
 }
 
+// EventTopics returns the topics of the DeviceStatusTemperatureAboveEvent declaration.
+func (DeviceStatusTemperatureAboveEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Temperature"),
+		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "Above"),
+	}
+}
+
 // <tns1:Device>
 //	<tnsaxis:Status>
 //		<Temperature>
@@ -298,19 +358,25 @@ type DeviceStatusTemperatureAboveEvent struct {
 //	</tnsaxis:Status>
 // </tns1:Device>
 func DeviceStatusTemperatureAboveOrBelowEventKvs(sensor_level *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Temperature"),
-		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "Above_or_below"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceStatusTemperatureAboveOrBelowEvent{}.EventTopics(),
 		NewBoolKeyValueEntrie("sensor_level", sensor_level),
-	})
+	))
 }
 
 type DeviceStatusTemperatureAboveOrBelowEvent struct {
 	SensorLevel bool `eventKey:"sensor_level"`
 }
 
+// EventTopics returns the topics of the DeviceStatusTemperatureAboveOrBelowEvent declaration.
+func (DeviceStatusTemperatureAboveOrBelowEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Temperature"),
+		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "Above_or_below"),
+	}
+}
+
 // <tns1:Device>
 //	<tnsaxis:Status>
 //		<Temperature>
@@ -325,19 +391,25 @@ type DeviceStatusTemperatureAboveOrBelowEvent struct {
 //	</tnsaxis:Status>
 // </tns1:Device>
 func DeviceStatusTemperatureBelowEventKvs(sensor_level *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Temperature"),
-		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "Below"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceStatusTemperatureBelowEvent{}.EventTopics(),
 		NewBoolKeyValueEntrie("sensor_level", sensor_level),
-	})
+	))
 }
 
 type DeviceStatusTemperatureBelowEvent struct {
 	SensorLevel bool `eventKey:"sensor_level"`
 }
 
+// EventTopics returns the topics of the DeviceStatusTemperatureBelowEvent declaration.
+func (DeviceStatusTemperatureBelowEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Status"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Temperature"),
+		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "Below"),
+	}
+}
+
 // <tns1:Device>
 //	<HardwareFailure>
 //		<PowerSupplyFailure>
@@ -355,14 +427,10 @@ type DeviceStatusTemperatureBelowEvent struct {
 //	</HardwareFailure>
 // </tns1:Device>
 func DeviceHardwareFailurePowerSupplyFailurePTZPowerFailureEventKvs(token *int, failed *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "HardwareFailure"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTns1, "PowerSupplyFailure"),
-		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "PTZPowerFailure"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceHardwareFailurePowerSupplyFailurePTZPowerFailureEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("Token", token),
 		NewBoolKeyValueEntrie("Failed", failed),
-	})
+	))
 }
 
 type DeviceHardwareFailurePowerSupplyFailurePTZPowerFailureEvent struct {
@@ -370,6 +438,16 @@ type DeviceHardwareFailurePowerSupplyFailurePTZPowerFailureEvent struct {
 	Failed bool `eventKey:"Failed"`
 }
 
+// EventTopics returns the topics of the DeviceHardwareFailurePowerSupplyFailurePTZPowerFailureEvent declaration.
+func (DeviceHardwareFailurePowerSupplyFailurePTZPowerFailureEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "HardwareFailure"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTns1, "PowerSupplyFailure"),
+		NewTopicKeyValueEntrie("topic3", &OnfivNameSpaceTnsAxis, "PTZPowerFailure"),
+	}
+}
+
 // <tns1:Device>
 //	<Trigger>
 //		<DigitalInput wstop:topic="true">
@@ -385,13 +463,10 @@ type DeviceHardwareFailurePowerSupplyFailurePTZPowerFailureEvent struct {
 //	</Trigger>
 // </tns1:Device>
 func DeviceTriggerDigitalInputEventKvs(inputToken *int, logicalState *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "Trigger"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTns1, "DigitalInput"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceTriggerDigitalInputEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("InputToken", inputToken),
 		NewBoolKeyValueEntrie("LogicalState", logicalState),
-	})
+	))
 }
 
 type DeviceTriggerDigitalInputEvent struct {
@@ -399,6 +474,15 @@ type DeviceTriggerDigitalInputEvent struct {
 	LogicalState bool `eventKey:"LogicalState"`
 }
 
+// EventTopics returns the topics of the DeviceTriggerDigitalInputEvent declaration.
+func (DeviceTriggerDigitalInputEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "Trigger"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTns1, "DigitalInput"),
+	}
+}
+
 // <tns1:Device>
 //	<Trigger>
 //		<Relay wstop:topic="true">
@@ -414,13 +498,10 @@ type DeviceTriggerDigitalInputEvent struct {
 //	</Trigger>
 // </tns1:Device>
 func DeviceTriggerRelayEventKvs(relayToken *int, logicalState *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "Trigger"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTns1, "Relay"),
+	return NewAXEventKeyValueSetFromEntries(append(DeviceTriggerRelayEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("RelayToken", relayToken),
 		NewBoolKeyValueEntrie("LogicalState", logicalState),
-	})
+	))
 }
 
 type DeviceTriggerRelayEvent struct {
@@ -428,6 +509,15 @@ type DeviceTriggerRelayEvent struct {
 	LogicalState bool `eventKey:"LogicalState"`
 }
 
+// EventTopics returns the topics of the DeviceTriggerRelayEvent declaration.
+func (DeviceTriggerRelayEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "Trigger"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTns1, "Relay"),
+	}
+}
+
 // <tns1:Device>
 //	<tnsaxis:RingPowerLimitExceeded wstop:topic="true">
 //		<tt:MessageDescription IsProperty="true">
@@ -441,12 +531,10 @@ type DeviceTriggerRelayEvent struct {
 //	</tnsaxis:RingPowerLimitExceeded>
 // </tns1:Device>
 func DeviceRingPowerLimitExceededEventKvs(input *int, limitExceeded *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "RingPowerLimitExceeded"),
+	return NewAXEventKeyValueSetFromEntries(append(RingPowerLimitExceededEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("input", input),
 		NewBoolKeyValueEntrie("limit_exceeded", limitExceeded),
-	})
+	))
 }
 
 type RingPowerLimitExceededEvent struct {
@@ -454,6 +542,14 @@ type RingPowerLimitExceededEvent struct {
 	LimitExceeded bool `eventKey:"limit_exceeded"`
 }
 
+// EventTopics returns the topics of the RingPowerLimitExceededEvent declaration.
+func (RingPowerLimitExceededEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Device"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "RingPowerLimitExceeded"),
+	}
+}
+
 // <tns1:LightControl>
 // 	<tnsaxis:LightStatusChanged>
 // 		<Status wstop:topic="true">
@@ -466,17 +562,23 @@ type RingPowerLimitExceededEvent struct {
 // 	</tnsaxis:LightStatusChanged>
 // </tns1:LightControl>
 func LightControlLightStatusChangedEventKvs(state *string) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "LightControl"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "LightStatusChanged"),
+	return NewAXEventKeyValueSetFromEntries(append(LightControlLightStatusChangedEvent{}.EventTopics(),
 		NewStringKeyValueEntrie("state", state),
-	})
+	))
 }
 
 type LightControlLightStatusChangedEvent struct {
 	State string `eventKey:"state"`
 }
 
+// EventTopics returns the topics of the LightControlLightStatusChangedEvent declaration.
+func (LightControlLightStatusChangedEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "LightControl"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "LightStatusChanged"),
+	}
+}
+
 // <tns1:VideoSource>
 // 	<tnsaxis:LiveStreamAccessed wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="true">
@@ -487,17 +589,23 @@ type LightControlLightStatusChangedEvent struct {
 // 	</tnsaxis:LiveStreamAccessed>
 // </tns1:VideoSource>
 func VideoSourceLiveStreamAccessedEventKvs(accessed *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "LiveStreamAccessed"),
+	return NewAXEventKeyValueSetFromEntries(append(VideoSourceLiveStreamAccessedEvent{}.EventTopics(),
 		NewBoolKeyValueEntrie("accessed", accessed),
-	})
+	))
 }
 
 type VideoSourceLiveStreamAccessedEvent struct {
 	Accessed bool `eventKey:"accessed"`
 }
 
+// EventTopics returns the topics of the VideoSourceLiveStreamAccessedEvent declaration.
+func (VideoSourceLiveStreamAccessedEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "LiveStreamAccessed"),
+	}
+}
+
 // <tns1:VideoSource>
 // 	<tnsaxis:DayNightVision wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="true">
@@ -511,12 +619,10 @@ type VideoSourceLiveStreamAccessedEvent struct {
 // 	</tnsaxis:DayNightVision>
 // </tns1:VideoSource>
 func VideoSourceDayNightVisionEventKvs(videoSourceConfigurationToken *int, day *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "DayNightVision"),
+	return NewAXEventKeyValueSetFromEntries(append(VideoSourceDayNightVisionEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("VideoSourceConfigurationToken", videoSourceConfigurationToken),
 		NewBoolKeyValueEntrie("day", day),
-	})
+	))
 }
 
 type VideoSourceDayNightVisionEvent struct {
@@ -524,6 +630,14 @@ type VideoSourceDayNightVisionEvent struct {
 	Day                           bool `eventKey:"day"`
 }
 
+// EventTopics returns the topics of the VideoSourceDayNightVisionEvent declaration.
+func (VideoSourceDayNightVisionEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "DayNightVision"),
+	}
+}
+
 // <tns1:VideoSource>
 // 	<tnsaxis:Tampering wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="false">
@@ -537,12 +651,10 @@ type VideoSourceDayNightVisionEvent struct {
 // 	</tnsaxis:Tampering>
 // </tns1:VideoSource>
 func VideoSourceTamperingEventKvs(channel *int, tampering *int) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Tampering"),
+	return NewAXEventKeyValueSetFromEntries(append(VideoSourceTamperingEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("channel", channel),
 		NewIntKeyValueEntrie("tampering", tampering),
-	})
+	))
 }
 
 type VideoSourceTamperingEvent struct {
@@ -550,6 +662,14 @@ type VideoSourceTamperingEvent struct {
 	Tampering int `eventKey:"tampering"`
 }
 
+// EventTopics returns the topics of the VideoSourceTamperingEvent declaration.
+func (VideoSourceTamperingEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Tampering"),
+	}
+}
+
 // <tns1:VideoSource>
 // 	<tnsaxis:ABR wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="true">
@@ -563,12 +683,10 @@ type VideoSourceTamperingEvent struct {
 // 	</tnsaxis:ABR>
 // </tns1:VideoSource>
 func VideoSourceABREventKvs(videoSourceConfigurationToken *int, abrError *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "ABR"),
+	return NewAXEventKeyValueSetFromEntries(append(VideoSourceABREvent{}.EventTopics(),
 		NewIntKeyValueEntrie("VideoSourceConfigurationToken", videoSourceConfigurationToken),
 		NewBoolKeyValueEntrie("abr_error", abrError),
-	})
+	))
 }
 
 type VideoSourceABREvent struct {
@@ -576,6 +694,14 @@ type VideoSourceABREvent struct {
 	AbrError                      bool `eventKey:"abr_error"`
 }
 
+// EventTopics returns the topics of the VideoSourceABREvent declaration.
+func (VideoSourceABREvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "ABR"),
+	}
+}
+
 // <tns1:VideoSource>
 // 	<GlobalSceneChange>
 // 		<ImagingService wstop:topic="true">
@@ -591,13 +717,10 @@ type VideoSourceABREvent struct {
 // 	</GlobalSceneChange>
 // </tns1:VideoSource>
 func VideoSourceGlobalSceneChangeEventKvs(source *int, state *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "GlobalSceneChange"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTns1, "ImagingService"),
+	return NewAXEventKeyValueSetFromEntries(append(VideoSourceGlobalSceneChangeEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("Source", source),
 		NewBoolKeyValueEntrie("State", state),
-	})
+	))
 }
 
 type VideoSourceGlobalSceneChangeEvent struct {
@@ -605,6 +728,15 @@ type VideoSourceGlobalSceneChangeEvent struct {
 	State  bool `eventKey:"State"`
 }
 
+// EventTopics returns the topics of the VideoSourceGlobalSceneChangeEvent declaration.
+func (VideoSourceGlobalSceneChangeEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "GlobalSceneChange"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTns1, "ImagingService"),
+	}
+}
+
 // <tns1:VideoSource>
 // 	<MotionAlarm wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="true">
@@ -618,12 +750,10 @@ type VideoSourceGlobalSceneChangeEvent struct {
 // 	</MotionAlarm>
 // </tns1:VideoSource>
 func VideoSourceMotionAlarmEventKvs(source *int, state *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "MotionAlarm"),
+	return NewAXEventKeyValueSetFromEntries(append(VideoSourceMotionAlarmEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("Source", source),
 		NewBoolKeyValueEntrie("State", state),
-	})
+	))
 }
 
 type VideoSourceMotionAlarmEvent struct {
@@ -631,6 +761,14 @@ type VideoSourceMotionAlarmEvent struct {
 	State  bool `eventKey:"State"`
 }
 
+// EventTopics returns the topics of the VideoSourceMotionAlarmEvent declaration.
+func (VideoSourceMotionAlarmEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "VideoSource"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "MotionAlarm"),
+	}
+}
+
 // <tns1:PTZController>
 // 	<tnsaxis:PTZError wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="false">
@@ -644,12 +782,10 @@ type VideoSourceMotionAlarmEvent struct {
 // 	</tnsaxis:PTZError>
 // </tns1:PTZController>
 func PTZControllerPTZErrorEventKvs(channel *int, ptzError *string) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "PTZController"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "PTZError"),
+	return NewAXEventKeyValueSetFromEntries(append(PTZControllerPTZErrorEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("channel", channel),
 		NewStringKeyValueEntrie("ptz_error", ptzError),
-	})
+	))
 }
 
 type PTZControllerPTZErrorEvent struct {
@@ -657,6 +793,14 @@ type PTZControllerPTZErrorEvent struct {
 	PTZError string `eventKey:"ptz_error"`
 }
 
+// EventTopics returns the topics of the PTZControllerPTZErrorEvent declaration.
+func (PTZControllerPTZErrorEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "PTZController"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "PTZError"),
+	}
+}
+
 // <tns1:PTZController>
 // 	<tnsaxis:PTZReady wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="true">
@@ -670,12 +814,10 @@ type PTZControllerPTZErrorEvent struct {
 // 	</tnsaxis:PTZReady>
 // </tns1:PTZController>
 func PTZControllerPTZReadyEventKvs(channel *int, ready *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "PTZController"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "PTZReady"),
+	return NewAXEventKeyValueSetFromEntries(append(PTZControllerPTZReadyEvent{}.EventTopics(),
 		NewIntKeyValueEntrie("channel", channel),
 		NewBoolKeyValueEntrie("ready", ready),
-	})
+	))
 }
 
 type PTZControllerPTZReadyEvent struct {
@@ -683,6 +825,14 @@ type PTZControllerPTZReadyEvent struct {
 	Ready   bool `eventKey:"ready"`
 }
 
+// EventTopics returns the topics of the PTZControllerPTZReadyEvent declaration.
+func (PTZControllerPTZReadyEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "PTZController"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "PTZReady"),
+	}
+}
+
 // <tns1:Media>
 // 	<ConfigurationChanged wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="false">
@@ -694,12 +844,10 @@ type PTZControllerPTZReadyEvent struct {
 // 	</ConfigurationChanged>
 // </tns1:Media>
 func MediaConfigurationChangedEventKvs(eventType *string, token *string) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Media"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "ConfigurationChanged"),
+	return NewAXEventKeyValueSetFromEntries(append(MediaConfigurationChangedEvent{}.EventTopics(),
 		NewStringKeyValueEntrie("Type", eventType),
 		NewStringKeyValueEntrie("Token", token),
-	})
+	))
 }
 
 type MediaConfigurationChangedEvent struct {
@@ -707,6 +855,14 @@ type MediaConfigurationChangedEvent struct {
 	Token string `eventKey:"Token"`
 }
 
+// EventTopics returns the topics of the MediaConfigurationChangedEvent declaration.
+func (MediaConfigurationChangedEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Media"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "ConfigurationChanged"),
+	}
+}
+
 // <tns1:Media>
 // 	<ProfileChanged wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="false">
@@ -717,17 +873,23 @@ type MediaConfigurationChangedEvent struct {
 // 	</ProfileChanged>
 // </tns1:Media>
 func MediaProfileChangedEventKvs(token *string) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Media"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "ProfileChanged"),
+	return NewAXEventKeyValueSetFromEntries(append(MediaProfileChangedEvent{}.EventTopics(),
 		NewStringKeyValueEntrie("Token", token),
-	})
+	))
 }
 
 type MediaProfileChangedEvent struct {
 	Token string `eventKey:"Token"`
 }
 
+// EventTopics returns the topics of the MediaProfileChangedEvent declaration.
+func (MediaProfileChangedEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTns1, "Media"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTns1, "ProfileChanged"),
+	}
+}
+
 // <tnsaxis:CameraApplicationPlatform>
 // 	<ObjectAnalytics>
 // 		<Device1Scenario1 wstop:topic="true">
@@ -740,18 +902,24 @@ type MediaProfileChangedEvent struct {
 // 	</ObjectAnalytics>
 // </tnsaxis:CameraApplicationPlatform>
 func CameraApplicationPlatformDevice1Scenario1EventKvs(active *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "CameraApplicationPlatform"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "ObjectAnalytics"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Device1Scenario1"),
+	return NewAXEventKeyValueSetFromEntries(append(CameraApplicationPlatformDevice1Scenario1Event{}.EventTopics(),
 		NewBoolKeyValueEntrie("active", active),
-	})
+	))
 }
 
 type CameraApplicationPlatformDevice1Scenario1Event struct {
 	Active bool `eventKey:"active"`
 }
 
+// EventTopics returns the topics of the CameraApplicationPlatformDevice1Scenario1Event declaration.
+func (CameraApplicationPlatformDevice1Scenario1Event) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "CameraApplicationPlatform"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "ObjectAnalytics"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Device1Scenario1"),
+	}
+}
+
 // <tnsaxis:CameraApplicationPlatform>
 // 	<ObjectAnalytics>
 // 		<Device1ScenarioANY wstop:topic="true">
@@ -764,18 +932,24 @@ type CameraApplicationPlatformDevice1Scenario1Event struct {
 // 	</ObjectAnalytics>
 // </tnsaxis:CameraApplicationPlatform>
 func CameraApplicationPlatformDevice1ScenarioANYEventKvs(active *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "CameraApplicationPlatform"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "ObjectAnalytics"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Device1ScenarioANY"),
+	return NewAXEventKeyValueSetFromEntries(append(CameraApplicationPlatformDevice1ScenarioANYEvent{}.EventTopics(),
 		NewBoolKeyValueEntrie("active", active),
-	})
+	))
 }
 
 type CameraApplicationPlatformDevice1ScenarioANYEvent struct {
 	Active bool `eventKey:"active"`
 }
 
+// EventTopics returns the topics of the CameraApplicationPlatformDevice1ScenarioANYEvent declaration.
+func (CameraApplicationPlatformDevice1ScenarioANYEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "CameraApplicationPlatform"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "ObjectAnalytics"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "Device1ScenarioANY"),
+	}
+}
+
 // <tnsaxis:CameraApplicationPlatform>
 // 	<ObjectAnalytics>
 // 		<xinternal_data wstop:topic="true">
@@ -788,18 +962,24 @@ type CameraApplicationPlatformDevice1ScenarioANYEvent struct {
 // 	</ObjectAnalytics>
 // </tnsaxis:CameraApplicationPlatform>
 func CameraApplicationPlatformXInternalDataEventKvs(svgFrame *string) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "CameraApplicationPlatform"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "ObjectAnalytics"),
-		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "xinternal_data"),
+	return NewAXEventKeyValueSetFromEntries(append(CameraApplicationPlatformXInternalDataEvent{}.EventTopics(),
 		NewStringKeyValueEntrie("svgframe", svgFrame),
-	})
+	))
 }
 
 type CameraApplicationPlatformXInternalDataEvent struct {
 	SvgFrame string `eventKey:"svgframe"`
 }
 
+// EventTopics returns the topics of the CameraApplicationPlatformXInternalDataEvent declaration.
+func (CameraApplicationPlatformXInternalDataEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "CameraApplicationPlatform"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "ObjectAnalytics"),
+		NewTopicKeyValueEntrie("topic2", &OnfivNameSpaceTnsAxis, "xinternal_data"),
+	}
+}
+
 // <tnsaxis:Storage>
 // 	<Alert wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="true">
@@ -816,15 +996,13 @@ type CameraApplicationPlatformXInternalDataEvent struct {
 // 	</Alert>
 // </tnsaxis:Storage>
 func StorageAlertEventKvs(diskID *string, alert *bool, overallHealth *int, temperature *int, wear *int) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "Storage"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Alert"),
+	return NewAXEventKeyValueSetFromEntries(append(StorageAlertEvent{}.EventTopics(),
 		NewStringKeyValueEntrie("disk_id", diskID),
 		NewBoolKeyValueEntrie("alert", alert),
 		NewIntKeyValueEntrie("overall_health", overallHealth),
 		NewIntKeyValueEntrie("temperature", temperature),
 		NewIntKeyValueEntrie("wear", wear),
-	})
+	))
 }
 
 type StorageAlertEvent struct {
@@ -835,6 +1013,14 @@ type StorageAlertEvent struct {
 	Wear          int    `eventKey:"wear"`
 }
 
+// EventTopics returns the topics of the StorageAlertEvent declaration.
+func (StorageAlertEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "Storage"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Alert"),
+	}
+}
+
 // <tnsaxis:Storage>
 // 	<Disruption wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="true">
@@ -848,12 +1034,10 @@ type StorageAlertEvent struct {
 // 	</Disruption>
 // </tnsaxis:Storage>
 func StorageDisruptionEventKvs(diskID *string, disruption *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "Storage"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Disruption"),
+	return NewAXEventKeyValueSetFromEntries(append(StorageDisruptionEvent{}.EventTopics(),
 		NewStringKeyValueEntrie("disk_id", diskID),
 		NewBoolKeyValueEntrie("disruption", disruption),
-	})
+	))
 }
 
 type StorageDisruptionEvent struct {
@@ -861,6 +1045,14 @@ type StorageDisruptionEvent struct {
 	Disruption bool   `eventKey:"disruption"`
 }
 
+// EventTopics returns the topics of the StorageDisruptionEvent declaration.
+func (StorageDisruptionEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "Storage"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Disruption"),
+	}
+}
+
 // <tnsaxis:Storage>
 // 	<Recording wstop:topic="true">
 // 		<tt:MessageDescription IsProperty="true">
@@ -871,13 +1063,19 @@ type StorageDisruptionEvent struct {
 // 	</Recording>
 // </tnsaxis:Storage>
 func StorageRecordingEventKvs(recording *bool) *AXEventKeyValueSet {
-	return NewAXEventKeyValueSetFromEntries([]KeyValueEntrie{
-		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "Storage"),
-		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Recording"),
+	return NewAXEventKeyValueSetFromEntries(append(StorageRecordingEvent{}.EventTopics(),
 		NewBoolKeyValueEntrie("recording", recording),
-	})
+	))
 }
 
 type StorageRecordingEvent struct {
 	Recording bool `eventKey:"recording"`
 }
+
+// EventTopics returns the topics of the StorageRecordingEvent declaration.
+func (StorageRecordingEvent) EventTopics() []KeyValueEntrie {
+	return []KeyValueEntrie{
+		NewTopicKeyValueEntrie("topic0", &OnfivNameSpaceTnsAxis, "Storage"),
+		NewTopicKeyValueEntrie("topic1", &OnfivNameSpaceTnsAxis, "Recording"),
+	}
+}
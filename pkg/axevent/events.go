@@ -8,6 +8,12 @@ type KeyValueEntrie struct {
 	ValueType AXEventValueType
 }
 
+// EventTopicProvider is implemented by event structs that know the topics of their event declaration,
+// like the structs in event_declarations.go.
+type EventTopicProvider interface {
+	EventTopics() []KeyValueEntrie
+}
+
 // Mark a key in the AXEventKeyValueSet as a source. A source key is an identifier used to distinguish between multiple instances of the same event declaration.
 // E.g. if a device has multiple I/O ports then event declarations that represent the state of each port will have the same keys but different values.
 // The key that represents which port the event represents should be marked as source and the key which represents the state should be marked as data.
@@ -14,7 +14,8 @@ import (
 
 // https://axiscommunications.github.io/acap-documentation/3.5/api/axevent/html/ax__event__key__value__set_8h.html
 type AXEventKeyValueSet struct {
-	Ptr *C.AXEventKeyValueSet
+	Ptr  *C.AXEventKeyValueSet
+	keys []KeyValueEntrie // Keys added with AddKeyValue, the C API can not enumerate them.
 }
 
 // Creates a new AXEventKeyValueSet
@@ -69,9 +70,33 @@ func (axEventKeyValueSet *AXEventKeyValueSet) AddKeyValue(key string, namespace
 	if int(success) == 0 {
 		return newEventError(gerr)
 	}
+	axEventKeyValueSet.removeKey(key, namespace)
+	axEventKeyValueSet.keys = append(axEventKeyValueSet.keys, KeyValueEntrie{Key: key, Namespace: namespace, ValueType: value_type})
 	return nil
 }
 
+// Keys returns the keys added with AddKeyValue in the order they were added, without their values.
+// The keys of a set that was not built in Go, like the set of a received event, are not known.
+func (axEventKeyValueSet *AXEventKeyValueSet) Keys() []KeyValueEntrie {
+	return append([]KeyValueEntrie(nil), axEventKeyValueSet.keys...)
+}
+
+func (axEventKeyValueSet *AXEventKeyValueSet) removeKey(key string, namespace *string) {
+	for i, k := range axEventKeyValueSet.keys {
+		if k.Key == key && sameNamespace(k.Namespace, namespace) {
+			axEventKeyValueSet.keys = append(axEventKeyValueSet.keys[:i:i], axEventKeyValueSet.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+func sameNamespace(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 // Retrieve the value type of the value associated with a key.
 func (axEventKeyValueSet *AXEventKeyValueSet) GetValueType(key string, namespace *string) (AXEventValueType, error) {
 	cKey := C.CString(key)
@@ -362,7 +387,7 @@ func (axEventKeyValueSet *AXEventKeyValueSet) RemoveKey(key string, namespace *s
 	if int(success) == 0 {
 		return newEventError(gerr)
 	}
-
+	axEventKeyValueSet.removeKey(key, namespace)
 	return nil
 }
 
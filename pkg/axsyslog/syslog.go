@@ -17,9 +17,23 @@ import (
 )
 
 const (
-	LOG_PID  = 0x01
-	LOG_CONS = 0x02
-	LOG_USER = (1 << 3)
+	LOG_PID    = 0x01
+	LOG_CONS   = 0x02
+	LOG_ODELAY = 0x04
+	LOG_NDELAY = 0x08
+	LOG_NOWAIT = 0x10
+	LOG_PERROR = 0x20
+
+	LOG_USER   = (1 << 3)
+	LOG_DAEMON = (3 << 3)
+	LOG_LOCAL0 = (16 << 3)
+	LOG_LOCAL1 = (17 << 3)
+	LOG_LOCAL2 = (18 << 3)
+	LOG_LOCAL3 = (19 << 3)
+	LOG_LOCAL4 = (20 << 3)
+	LOG_LOCAL5 = (21 << 3)
+	LOG_LOCAL6 = (22 << 3)
+	LOG_LOCAL7 = (23 << 3)
 
 	LOG_INFO = 6
 	LOG_CRIT = 2
@@ -0,0 +1,60 @@
+package bitstream
+
+import "errors"
+
+// ErrTruncated is returned when a NAL unit ends before all fields are read.
+var ErrTruncated = errors.New("NAL unit is truncated")
+
+// bitReader reads the bits of an RBSP, most significant bit first.
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// u reads n bits as unsigned integer, n must not exceed 32.
+func (r *bitReader) u(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if r.pos >= len(r.data)*8 {
+			r.err = ErrTruncated
+			return 0
+		}
+		v = v<<1 | uint32(r.data[r.pos/8]>>(7-r.pos%8))&1
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) flag() bool {
+	return r.u(1) == 1
+}
+
+func (r *bitReader) skip(n int) {
+	r.pos += n
+	if r.pos > len(r.data)*8 {
+		r.err = ErrTruncated
+	}
+}
+
+// ue reads an unsigned Exp-Golomb code.
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for r.u(1) == 0 {
+		if r.err != nil || zeros > 31 {
+			r.err = ErrTruncated
+			return 0
+		}
+		zeros++
+	}
+	return (1<<zeros - 1) + r.u(zeros)
+}
+
+// se reads a signed Exp-Golomb code.
+func (r *bitReader) se() int32 {
+	v := r.ue()
+	if v&1 == 1 {
+		return int32((v + 1) / 2)
+	}
+	return -int32(v / 2)
+}
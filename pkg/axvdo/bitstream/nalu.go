@@ -0,0 +1,286 @@
+// Package bitstream parses H.264 and H.265 Annex-B byte streams, like the data of the encoded VDO streams.
+//
+// It splits access units into NAL units, decodes the SPS and VPS into resolution, profile, level and frame rate,
+// detects keyframes from the NAL unit types and reads or injects SEI user data. It is pure Go without cgo.
+package bitstream
+
+import "fmt"
+
+// Codec is the video coding standard of a bitstream.
+type Codec int
+
+const (
+	H264 Codec = iota
+	H265
+)
+
+func (c Codec) String() string {
+	switch c {
+	case H264:
+		return "H264"
+	case H265:
+		return "H265"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(c))
+	}
+}
+
+// H.264 NAL unit types.
+const (
+	H264NalSlice = 1
+	H264NalIDR   = 5
+	H264NalSEI   = 6
+	H264NalSPS   = 7
+	H264NalPPS   = 8
+	H264NalAUD   = 9
+)
+
+// H.265 NAL unit types.
+const (
+	H265NalRASLR     = 9 // The last non IRAP slice type.
+	H265NalBLAWLP    = 16
+	H265NalIDRWRADL  = 19
+	H265NalIDRNLP    = 20
+	H265NalCRA       = 21
+	H265NalVPS       = 32
+	H265NalSPS       = 33
+	H265NalPPS       = 34
+	H265NalAUD       = 35
+	H265NalSEIPrefix = 39
+	H265NalSEISuffix = 40
+)
+
+// NalKind is the codec independent kind of a NAL unit.
+type NalKind int
+
+const (
+	NalKindOther NalKind = iota
+	NalKindSlice         // A slice of a picture that is not a keyframe.
+	NalKindIDR           // A slice of a keyframe, for H.265 all IRAP pictures (BLA, IDR and CRA).
+	NalKindSPS
+	NalKindPPS
+	NalKindVPS
+	NalKindSEI
+	NalKindAUD
+)
+
+func (k NalKind) String() string {
+	switch k {
+	case NalKindSlice:
+		return "Slice"
+	case NalKindIDR:
+		return "IDR"
+	case NalKindSPS:
+		return "SPS"
+	case NalKindPPS:
+		return "PPS"
+	case NalKindVPS:
+		return "VPS"
+	case NalKindSEI:
+		return "SEI"
+	case NalKindAUD:
+		return "AUD"
+	default:
+		return "Other"
+	}
+}
+
+// NalUnit is a NAL unit of an Annex-B byte stream.
+type NalUnit struct {
+	Codec Codec
+	Type  uint8  // The nal_unit_type of the header.
+	Data  []byte // The NAL unit with header and emulation prevention bytes, without start code.
+}
+
+// Kind returns the codec independent kind of the NAL unit.
+func (n NalUnit) Kind() NalKind {
+	if n.Codec == H265 {
+		switch {
+		case n.Type <= H265NalRASLR:
+			return NalKindSlice
+		case n.Type >= H265NalBLAWLP && n.Type <= H265NalCRA:
+			return NalKindIDR
+		case n.Type == H265NalVPS:
+			return NalKindVPS
+		case n.Type == H265NalSPS:
+			return NalKindSPS
+		case n.Type == H265NalPPS:
+			return NalKindPPS
+		case n.Type == H265NalAUD:
+			return NalKindAUD
+		case n.Type == H265NalSEIPrefix || n.Type == H265NalSEISuffix:
+			return NalKindSEI
+		}
+		return NalKindOther
+	}
+	switch n.Type {
+	case H264NalSlice:
+		return NalKindSlice
+	case H264NalIDR:
+		return NalKindIDR
+	case H264NalSEI:
+		return NalKindSEI
+	case H264NalSPS:
+		return NalKindSPS
+	case H264NalPPS:
+		return NalKindPPS
+	case H264NalAUD:
+		return NalKindAUD
+	}
+	return NalKindOther
+}
+
+// IsKeyframe reports whether the NAL unit is a slice of a picture that can be decoded without previous pictures.
+func (n NalUnit) IsKeyframe() bool {
+	return n.Kind() == NalKindIDR
+}
+
+// IsVCL reports whether the NAL unit contains picture data.
+func (n NalUnit) IsVCL() bool {
+	if n.Codec == H265 {
+		return n.Type < H265NalVPS
+	}
+	return n.Type >= H264NalSlice && n.Type <= H264NalIDR
+}
+
+// RBSP returns the payload after the header with the emulation prevention bytes removed.
+func (n NalUnit) RBSP() []byte {
+	header := headerSize(n.Codec)
+	if len(n.Data) < header {
+		return nil
+	}
+	return Unescape(n.Data[header:])
+}
+
+func (n NalUnit) String() string {
+	return fmt.Sprintf("%s %s (type %d, %d bytes)", n.Codec, n.Kind(), n.Type, len(n.Data))
+}
+
+// Parse splits an Annex-B byte stream into NAL units. The units share the memory of data.
+func Parse(data []byte, codec Codec) []NalUnit {
+	var units []NalUnit
+	for _, nalu := range SplitAnnexB(data) {
+		if len(nalu) < headerSize(codec) {
+			continue
+		}
+		units = append(units, NalUnit{Codec: codec, Type: NalType(nalu, codec), Data: nalu})
+	}
+	return units
+}
+
+// Find returns the first NAL unit of the given kind.
+func Find(units []NalUnit, kind NalKind) (NalUnit, bool) {
+	for _, u := range units {
+		if u.Kind() == kind {
+			return u, true
+		}
+	}
+	return NalUnit{}, false
+}
+
+// IsKeyframe reports whether the access unit contains a keyframe slice, independent of the frame type reported by VDO.
+func IsKeyframe(data []byte, codec Codec) bool {
+	for _, u := range Parse(data, codec) {
+		if u.IsKeyframe() {
+			return true
+		}
+	}
+	return false
+}
+
+// NalType returns the nal_unit_type of a NAL unit without start code.
+func NalType(nalu []byte, codec Codec) uint8 {
+	if len(nalu) == 0 {
+		return 0
+	}
+	if codec == H265 {
+		return nalu[0] >> 1 & 0x3f
+	}
+	return nalu[0] & 0x1f
+}
+
+// SplitAnnexB splits an Annex-B byte stream into NAL units without start codes.
+func SplitAnnexB(data []byte) [][]byte {
+	var nalus [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); {
+		if data[i] == 0 && data[i+1] == 0 && data[i+2] == 1 {
+			if start >= 0 {
+				nalus = appendNalu(nalus, data[start:i])
+			}
+			i += 3
+			start = i
+			continue
+		}
+		i++
+	}
+	if start >= 0 {
+		nalus = appendNalu(nalus, data[start:])
+	}
+	return nalus
+}
+
+// AppendAnnexB appends the NAL units with 4 byte start codes to dst.
+func AppendAnnexB(dst []byte, nalus ...[]byte) []byte {
+	for _, nalu := range nalus {
+		dst = append(dst, 0, 0, 0, 1)
+		dst = append(dst, nalu...)
+	}
+	return dst
+}
+
+// appendNalu appends the NAL unit without the trailing zero bytes that belong to the next start code.
+func appendNalu(nalus [][]byte, nalu []byte) [][]byte {
+	for len(nalu) > 0 && nalu[len(nalu)-1] == 0 {
+		nalu = nalu[:len(nalu)-1]
+	}
+	if len(nalu) == 0 {
+		return nalus
+	}
+	return append(nalus, nalu)
+}
+
+// Unescape removes the emulation prevention bytes of a NAL unit.
+func Unescape(nalu []byte) []byte {
+	out := make([]byte, 0, len(nalu))
+	zeros := 0
+	for _, b := range nalu {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// Escape inserts emulation prevention bytes, so the RBSP contains no start code.
+func Escape(rbsp []byte) []byte {
+	out := make([]byte, 0, len(rbsp)+len(rbsp)/64)
+	zeros := 0
+	for _, b := range rbsp {
+		if zeros >= 2 && b <= 3 {
+			out = append(out, 3)
+			zeros = 0
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func headerSize(codec Codec) int {
+	if codec == H265 {
+		return 2
+	}
+	return 1
+}
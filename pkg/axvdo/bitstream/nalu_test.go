@@ -0,0 +1,108 @@
+package bitstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		name    string
+		rbsp    []byte
+		escaped []byte
+	}{
+		{"no zeros", []byte{1, 2, 3}, []byte{1, 2, 3}},
+		{"start code", []byte{0, 0, 1}, []byte{0, 0, 3, 1}},
+		{"emulation byte", []byte{0, 0, 3}, []byte{0, 0, 3, 3}},
+		{"zero run", []byte{0, 0, 0, 0, 0}, []byte{0, 0, 3, 0, 0, 3, 0}},
+		{"zeros then data", []byte{0, 0, 4, 0, 0, 2}, []byte{0, 0, 4, 0, 0, 3, 2}},
+		{"empty", []byte{}, []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			escaped := Escape(tt.rbsp)
+			assert.Equal(t, tt.escaped, escaped)
+			assert.Equal(t, tt.rbsp, Unescape(escaped))
+		})
+	}
+}
+
+func TestSplitAnnexB(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  []byte
+		nalus [][]byte
+	}{
+		{"4 byte start codes", []byte{0, 0, 0, 1, 0x67, 1, 0, 0, 0, 1, 0x68, 2}, [][]byte{{0x67, 1}, {0x68, 2}}},
+		{"3 byte start codes", []byte{0, 0, 1, 0x67, 1, 0, 0, 1, 0x65, 2, 3}, [][]byte{{0x67, 1}, {0x65, 2, 3}}},
+		{"trailing zeros", []byte{0, 0, 1, 0x06, 5, 0x80, 0, 0, 0, 0, 0, 1, 0x65, 1}, [][]byte{{0x06, 5, 0x80}, {0x65, 1}}},
+		{"leading garbage", []byte{9, 9, 0, 0, 1, 0x41, 7}, [][]byte{{0x41, 7}}},
+		{"escaped payload", []byte{0, 0, 1, 0x65, 0, 0, 3, 1, 0x80}, [][]byte{{0x65, 0, 0, 3, 1, 0x80}}},
+		{"empty units", []byte{0, 0, 1, 0, 0, 1, 0x09, 0xf0}, [][]byte{{0x09, 0xf0}}},
+		{"no start code", []byte{0x65, 1, 2}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.nalus, SplitAnnexB(tt.data))
+		})
+	}
+}
+
+func TestAnnexBRoundTrip(t *testing.T) {
+	// NAL units whose RBSP contains start codes, they must survive escaping, framing and splitting.
+	rbsps := [][]byte{
+		{0x64, 0, 0x28, 0, 0, 1, 0, 0, 0, 0x80},
+		{0xee, 0, 0, 2, 0, 0, 3, 0x80},
+		{0x88, 0x84, 0, 0, 0, 0, 0, 1, 0x80},
+	}
+	headers := []byte{0x67, 0x68, 0x65}
+	var nalus [][]byte
+	for i, rbsp := range rbsps {
+		nalus = append(nalus, append([]byte{headers[i]}, Escape(rbsp)...))
+	}
+
+	data := AppendAnnexB(nil, nalus...)
+	split := SplitAnnexB(data)
+	require.Equal(t, nalus, split)
+
+	units := Parse(data, H264)
+	require.Len(t, units, 3)
+	for i, u := range units {
+		assert.Equal(t, rbsps[i], u.RBSP())
+	}
+	assert.Equal(t, []NalKind{NalKindSPS, NalKindPPS, NalKindIDR}, []NalKind{units[0].Kind(), units[1].Kind(), units[2].Kind()})
+	assert.True(t, IsKeyframe(data, H264))
+}
+
+func TestNalUnitKind(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    Codec
+		header   []byte
+		kind     NalKind
+		keyframe bool
+		vcl      bool
+	}{
+		{"H264 slice", H264, []byte{0x41}, NalKindSlice, false, true},
+		{"H264 IDR", H264, []byte{0x65}, NalKindIDR, true, true},
+		{"H264 SEI", H264, []byte{0x06}, NalKindSEI, false, false},
+		{"H264 SPS", H264, []byte{0x67}, NalKindSPS, false, false},
+		{"H264 AUD", H264, []byte{0x09}, NalKindAUD, false, false},
+		{"H265 trail", H265, []byte{0x02, 0x01}, NalKindSlice, false, true},
+		{"H265 IDR", H265, []byte{0x26, 0x01}, NalKindIDR, true, true},
+		{"H265 CRA", H265, []byte{0x2a, 0x01}, NalKindIDR, true, true},
+		{"H265 VPS", H265, []byte{0x40, 0x01}, NalKindVPS, false, false},
+		{"H265 SPS", H265, []byte{0x42, 0x01}, NalKindSPS, false, false},
+		{"H265 prefix SEI", H265, []byte{0x4e, 0x01}, NalKindSEI, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NalUnit{Codec: tt.codec, Type: NalType(tt.header, tt.codec), Data: tt.header}
+			assert.Equal(t, tt.kind, u.Kind())
+			assert.Equal(t, tt.keyframe, u.IsKeyframe())
+			assert.Equal(t, tt.vcl, u.IsVCL())
+		})
+	}
+}
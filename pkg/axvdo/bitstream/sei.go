@@ -0,0 +1,141 @@
+package bitstream
+
+import "errors"
+
+// SEIUserDataUnregistered is the payload type of user data identified by a UUID.
+const SEIUserDataUnregistered = 5
+
+var (
+	ErrNotSEI     = errors.New("NAL unit is not an SEI")
+	ErrNoNalUnits = errors.New("access unit contains no Annex-B NAL units")
+)
+
+// SEIMessage is a message of an SEI NAL unit.
+type SEIMessage struct {
+	Type    int
+	Payload []byte
+}
+
+// UserData is the payload of an unregistered user data SEI message.
+type UserData struct {
+	UUID [16]byte
+	Data []byte
+}
+
+// ParseSEI decodes the messages of an SEI NAL unit.
+func ParseSEI(unit NalUnit) ([]SEIMessage, error) {
+	if unit.Kind() != NalKindSEI {
+		return nil, ErrNotSEI
+	}
+	rbsp := unit.RBSP()
+	var messages []SEIMessage
+	pos := 0
+	// The RBSP ends with the stop bit in 0x80.
+	for pos < len(rbsp) && !(pos == len(rbsp)-1 && rbsp[pos] == 0x80) {
+		var payloadType, payloadSize int
+		var ok bool
+		if payloadType, pos, ok = seiValue(rbsp, pos); !ok {
+			return messages, ErrTruncated
+		}
+		if payloadSize, pos, ok = seiValue(rbsp, pos); !ok {
+			return messages, ErrTruncated
+		}
+		if pos+payloadSize > len(rbsp) {
+			return messages, ErrTruncated
+		}
+		messages = append(messages, SEIMessage{Type: payloadType, Payload: rbsp[pos : pos+payloadSize]})
+		pos += payloadSize
+	}
+	return messages, nil
+}
+
+// ReadUserData returns the unregistered user data of all SEI NAL units in an access unit.
+func ReadUserData(data []byte, codec Codec) []UserData {
+	var out []UserData
+	for _, unit := range Parse(data, codec) {
+		if unit.Kind() != NalKindSEI {
+			continue
+		}
+		messages, _ := ParseSEI(unit)
+		for _, m := range messages {
+			if m.Type != SEIUserDataUnregistered || len(m.Payload) < 16 {
+				continue
+			}
+			ud := UserData{Data: m.Payload[16:]}
+			copy(ud.UUID[:], m.Payload)
+			out = append(out, ud)
+		}
+	}
+	return out
+}
+
+// FindUserData returns the first unregistered user data with the UUID in an access unit.
+func FindUserData(data []byte, codec Codec, uuid [16]byte) ([]byte, bool) {
+	for _, ud := range ReadUserData(data, codec) {
+		if ud.UUID == uuid {
+			return ud.Data, true
+		}
+	}
+	return nil, false
+}
+
+// UserDataSEI builds an SEI NAL unit without start code that carries unregistered user data.
+// For H.265 it is a prefix SEI.
+func UserDataSEI(codec Codec, uuid [16]byte, data []byte) []byte {
+	var rbsp []byte
+	rbsp = appendSEIValue(rbsp, SEIUserDataUnregistered)
+	rbsp = appendSEIValue(rbsp, 16+len(data))
+	rbsp = append(rbsp, uuid[:]...)
+	rbsp = append(rbsp, data...)
+	rbsp = append(rbsp, 0x80)
+
+	nalu := []byte{H264NalSEI}
+	if codec == H265 {
+		nalu = []byte{H265NalSEIPrefix << 1, 1}
+	}
+	return append(nalu, Escape(rbsp)...)
+}
+
+// InjectUserData returns a copy of the access unit with an unregistered user data SEI inserted before the first slice,
+// for example to carry an own timestamp with the frame. All NAL units of the result use 4 byte start codes.
+func InjectUserData(data []byte, codec Codec, uuid [16]byte, payload []byte) ([]byte, error) {
+	units := Parse(data, codec)
+	if len(units) == 0 {
+		return nil, ErrNoNalUnits
+	}
+	sei := UserDataSEI(codec, uuid, payload)
+	out := make([]byte, 0, len(data)+len(sei)+4*len(units)+4)
+	injected := false
+	for _, unit := range units {
+		if !injected && unit.IsVCL() {
+			out = AppendAnnexB(out, sei)
+			injected = true
+		}
+		out = AppendAnnexB(out, unit.Data)
+	}
+	if !injected {
+		out = AppendAnnexB(out, sei)
+	}
+	return out, nil
+}
+
+// seiValue reads a payload type or size, coded as a sequence of 0xff bytes plus a last byte.
+func seiValue(rbsp []byte, pos int) (int, int, bool) {
+	v := 0
+	for pos < len(rbsp) {
+		b := rbsp[pos]
+		pos++
+		v += int(b)
+		if b != 0xff {
+			return v, pos, true
+		}
+	}
+	return 0, pos, false
+}
+
+func appendSEIValue(dst []byte, v int) []byte {
+	for ; v >= 0xff; v -= 0xff {
+		dst = append(dst, 0xff)
+	}
+	return append(dst, byte(v))
+}
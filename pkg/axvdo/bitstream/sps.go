@@ -0,0 +1,483 @@
+package bitstream
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotParameterSet is returned when a NAL unit of another type is passed to a parameter set parser.
+var ErrNotParameterSet = errors.New("NAL unit is not the expected parameter set")
+
+// ProfileTierLevel is the general profile, tier and level of an H.265 VPS or SPS.
+type ProfileTierLevel struct {
+	ProfileSpace         uint8
+	Tier                 uint8 // 0 is the main tier, 1 the high tier.
+	ProfileIdc           uint8
+	ProfileCompatibility uint32
+	ConstraintFlags      [6]byte
+	LevelIdc             uint8  // 30 times the level number.
+	Raw                  []byte // The 12 bytes as stored in the hvcC box.
+}
+
+// SPS is a decoded sequence parameter set.
+type SPS struct {
+	Codec           Codec
+	ID              uint32
+	ProfileIdc      uint8
+	ConstraintFlags uint8 // H.264 only, the constraint_set flags.
+	// LevelIdc is 10 times the level number for H.264 and 30 times for H.265.
+	LevelIdc        uint8
+	ChromaFormatIdc uint32 // 0 is monochrome, 1 is 4:2:0, 2 is 4:2:2 and 3 is 4:4:4.
+	BitDepthLuma    int
+	BitDepthChroma  int
+	// Width and Height are the displayed resolution, with the cropping window applied.
+	Width  int
+	Height int
+	// NumUnitsInTick and TimeScale are the timing info of the VUI, 0 if the stream does not signal it.
+	NumUnitsInTick uint32
+	TimeScale      uint32
+	// H.265 only.
+	MaxSubLayers      int
+	TemporalIdNesting bool
+	ProfileTierLevel  *ProfileTierLevel
+}
+
+// FrameRate returns the frame rate of the VUI timing info, 0 if the stream does not signal it.
+// VDO streams with dynamic frame rate signal the maximum frame rate.
+func (s *SPS) FrameRate() float64 {
+	if s.NumUnitsInTick == 0 {
+		return 0
+	}
+	if s.Codec == H265 {
+		return float64(s.TimeScale) / float64(s.NumUnitsInTick)
+	}
+	// H.264 counts fields, two ticks per frame.
+	return float64(s.TimeScale) / float64(2*s.NumUnitsInTick)
+}
+
+// Level returns the level number, for example 4.1.
+func (s *SPS) Level() float64 {
+	if s.Codec == H265 {
+		return float64(s.LevelIdc) / 30
+	}
+	return float64(s.LevelIdc) / 10
+}
+
+// Profile returns the name of the profile, for example High or Main 10.
+func (s *SPS) Profile() string {
+	if s.Codec == H265 {
+		return h265ProfileName(s.ProfileIdc)
+	}
+	switch s.ProfileIdc {
+	case 66:
+		if s.ConstraintFlags&0x40 != 0 {
+			return "Constrained Baseline"
+		}
+		return "Baseline"
+	case 77:
+		return "Main"
+	case 88:
+		return "Extended"
+	case 100:
+		return "High"
+	case 110:
+		return "High 10"
+	case 122:
+		return "High 4:2:2"
+	case 244:
+		return "High 4:4:4 Predictive"
+	default:
+		return fmt.Sprintf("Unknown(%d)", s.ProfileIdc)
+	}
+}
+
+func (s *SPS) String() string {
+	return fmt.Sprintf("%s %s@%.1f %dx%d %.2f fps", s.Codec, s.Profile(), s.Level(), s.Width, s.Height, s.FrameRate())
+}
+
+// VPS is a decoded H.265 video parameter set.
+type VPS struct {
+	ID                uint32
+	MaxSubLayers      int
+	TemporalIdNesting bool
+	ProfileTierLevel  *ProfileTierLevel
+	// NumUnitsInTick and TimeScale are the timing info, 0 if the stream does not signal it.
+	NumUnitsInTick uint32
+	TimeScale      uint32
+}
+
+// FrameRate returns the frame rate of the timing info, 0 if the stream does not signal it.
+func (v *VPS) FrameRate() float64 {
+	if v.NumUnitsInTick == 0 {
+		return 0
+	}
+	return float64(v.TimeScale) / float64(v.NumUnitsInTick)
+}
+
+// ParseSPS decodes an SPS NAL unit of the codec, without start code.
+// Errors in the VUI after the resolution are ignored, the timing info is left empty in that case.
+func ParseSPS(nalu []byte, codec Codec) (*SPS, error) {
+	unit := NalUnit{Codec: codec, Type: NalType(nalu, codec), Data: nalu}
+	if unit.Kind() != NalKindSPS {
+		return nil, ErrNotParameterSet
+	}
+	if codec == H265 {
+		return parseH265SPS(unit.RBSP())
+	}
+	return parseH264SPS(unit.RBSP())
+}
+
+// ParseVPS decodes an H.265 VPS NAL unit, without start code.
+func ParseVPS(nalu []byte) (*VPS, error) {
+	unit := NalUnit{Codec: H265, Type: NalType(nalu, H265), Data: nalu}
+	if unit.Kind() != NalKindVPS {
+		return nil, ErrNotParameterSet
+	}
+	r := &bitReader{data: unit.RBSP()}
+	vps := &VPS{}
+	vps.ID = r.u(4)
+	r.skip(2) // vps_base_layer_internal_flag, vps_base_layer_available_flag
+	r.skip(6) // vps_max_layers_minus1
+	maxSubLayersMinus1 := int(r.u(3))
+	vps.MaxSubLayers = maxSubLayersMinus1 + 1
+	vps.TemporalIdNesting = r.flag()
+	r.skip(16) // vps_reserved_0xffff_16bits
+	vps.ProfileTierLevel = readProfileTierLevel(r, maxSubLayersMinus1)
+	orderingInfo := r.flag()
+	for i := 0; i <= maxSubLayersMinus1; i++ {
+		if !orderingInfo && i < maxSubLayersMinus1 {
+			continue
+		}
+		r.ue() // vps_max_dec_pic_buffering_minus1
+		r.ue() // vps_max_num_reorder_pics
+		r.ue() // vps_max_latency_increase_plus1
+	}
+	maxLayerId := int(r.u(6))
+	layerSets := int(r.ue())
+	for i := 1; i <= layerSets && r.err == nil; i++ {
+		r.skip(maxLayerId + 1) // layer_id_included_flag
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.flag() {
+		timing := *r
+		numUnits, timeScale := timing.u(32), timing.u(32)
+		if timing.err == nil {
+			vps.NumUnitsInTick, vps.TimeScale = numUnits, timeScale
+		}
+	}
+	return vps, nil
+}
+
+func parseH264SPS(rbsp []byte) (*SPS, error) {
+	r := &bitReader{data: rbsp}
+	sps := &SPS{Codec: H264, ChromaFormatIdc: 1, BitDepthLuma: 8, BitDepthChroma: 8}
+	sps.ProfileIdc = uint8(r.u(8))
+	sps.ConstraintFlags = uint8(r.u(8))
+	sps.LevelIdc = uint8(r.u(8))
+	sps.ID = r.ue()
+	switch sps.ProfileIdc {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		sps.ChromaFormatIdc = r.ue()
+		if sps.ChromaFormatIdc == 3 {
+			r.skip(1) // separate_colour_plane_flag
+		}
+		sps.BitDepthLuma = int(r.ue()) + 8
+		sps.BitDepthChroma = int(r.ue()) + 8
+		r.skip(1) // qpprime_y_zero_transform_bypass_flag
+		if r.flag() {
+			lists := 8
+			if sps.ChromaFormatIdc == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if !r.flag() {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				skipH264ScalingList(r, size)
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.skip(1) // delta_pic_order_always_zero_flag
+		r.se()    // offset_for_non_ref_pic
+		r.se()    // offset_for_top_to_bottom_field
+		for n := r.ue(); n > 0 && r.err == nil; n-- {
+			r.se()
+		}
+	}
+	r.ue()    // max_num_ref_frames
+	r.skip(1) // gaps_in_frame_num_value_allowed_flag
+	widthMbs := r.ue() + 1
+	heightMapUnits := r.ue() + 1
+	frameMbsOnly := r.u(1)
+	if frameMbsOnly == 0 {
+		r.skip(1) // mb_adaptive_frame_field_flag
+	}
+	r.skip(1) // direct_8x8_inference_flag
+	var cropLeft, cropRight, cropTop, cropBottom uint32
+	if r.flag() {
+		cropLeft, cropRight, cropTop, cropBottom = r.ue(), r.ue(), r.ue(), r.ue()
+	}
+	vui := r.flag()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	cropUnitX, cropUnitY := uint32(1), 2-frameMbsOnly
+	switch sps.ChromaFormatIdc {
+	case 1:
+		cropUnitX, cropUnitY = 2, 2*(2-frameMbsOnly)
+	case 2:
+		cropUnitX = 2
+	}
+	sps.Width = int(widthMbs*16 - cropUnitX*(cropLeft+cropRight))
+	sps.Height = int((2-frameMbsOnly)*heightMapUnits*16 - cropUnitY*(cropTop+cropBottom))
+
+	if vui {
+		readVUITiming(r, sps, false)
+	}
+	return sps, nil
+}
+
+func parseH265SPS(rbsp []byte) (*SPS, error) {
+	r := &bitReader{data: rbsp}
+	sps := &SPS{Codec: H265}
+	r.skip(4) // sps_video_parameter_set_id
+	maxSubLayersMinus1 := int(r.u(3))
+	sps.MaxSubLayers = maxSubLayersMinus1 + 1
+	sps.TemporalIdNesting = r.flag()
+	sps.ProfileTierLevel = readProfileTierLevel(r, maxSubLayersMinus1)
+	sps.ProfileIdc = sps.ProfileTierLevel.ProfileIdc
+	sps.LevelIdc = sps.ProfileTierLevel.LevelIdc
+	sps.ID = r.ue()
+	sps.ChromaFormatIdc = r.ue()
+	if sps.ChromaFormatIdc == 3 {
+		r.skip(1) // separate_colour_plane_flag
+	}
+	width, height := r.ue(), r.ue()
+	var confLeft, confRight, confTop, confBottom uint32
+	if r.flag() {
+		confLeft, confRight, confTop, confBottom = r.ue(), r.ue(), r.ue(), r.ue()
+	}
+	sps.BitDepthLuma = int(r.ue()) + 8
+	sps.BitDepthChroma = int(r.ue()) + 8
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	subWidth, subHeight := uint32(1), uint32(1)
+	switch sps.ChromaFormatIdc {
+	case 1:
+		subWidth, subHeight = 2, 2
+	case 2:
+		subWidth = 2
+	}
+	sps.Width = int(width - subWidth*(confLeft+confRight))
+	sps.Height = int(height - subHeight*(confTop+confBottom))
+
+	log2MaxPocLsb := int(r.ue()) + 4
+	orderingInfo := r.flag()
+	for i := 0; i <= maxSubLayersMinus1; i++ {
+		if !orderingInfo && i < maxSubLayersMinus1 {
+			continue
+		}
+		r.ue() // sps_max_dec_pic_buffering_minus1
+		r.ue() // sps_max_num_reorder_pics
+		r.ue() // sps_max_latency_increase_plus1
+	}
+	r.ue() // log2_min_luma_coding_block_size_minus3
+	r.ue() // log2_diff_max_min_luma_coding_block_size
+	r.ue() // log2_min_luma_transform_block_size_minus2
+	r.ue() // log2_diff_max_min_luma_transform_block_size
+	r.ue() // max_transform_hierarchy_depth_inter
+	r.ue() // max_transform_hierarchy_depth_intra
+	// scaling_list_enabled_flag and sps_scaling_list_data_present_flag
+	if r.flag() && r.flag() {
+		skipH265ScalingListData(r)
+	}
+	r.skip(2)     // amp_enabled_flag, sample_adaptive_offset_enabled_flag
+	if r.flag() { // pcm_enabled_flag
+		r.skip(8)
+		r.ue()
+		r.ue()
+		r.skip(1)
+	}
+	skipH265ShortTermRefPicSets(r)
+	if r.flag() { // long_term_ref_pics_present_flag
+		for n := r.ue(); n > 0 && r.err == nil; n-- {
+			r.skip(log2MaxPocLsb + 1)
+		}
+	}
+	r.skip(2) // sps_temporal_mvp_enabled_flag, strong_intra_smoothing_enabled_flag
+	if r.flag() && r.err == nil {
+		readVUITiming(r, sps, true)
+	}
+	return sps, nil
+}
+
+// readVUITiming reads the VUI up to the timing info into the SPS, errors leave the timing info empty.
+func readVUITiming(r *bitReader, sps *SPS, h265 bool) {
+	if r.flag() && r.u(8) == 255 { // aspect_ratio_info_present_flag, aspect_ratio_idc is Extended_SAR
+		r.skip(32)
+	}
+	if r.flag() { // overscan_info_present_flag
+		r.skip(1)
+	}
+	if r.flag() { // video_signal_type_present_flag
+		r.skip(4)
+		if r.flag() {
+			r.skip(24)
+		}
+	}
+	if r.flag() { // chroma_loc_info_present_flag
+		r.ue()
+		r.ue()
+	}
+	if h265 {
+		r.skip(3)     // neutral_chroma_indication_flag, field_seq_flag, frame_field_info_present_flag
+		if r.flag() { // default_display_window_flag
+			r.ue()
+			r.ue()
+			r.ue()
+			r.ue()
+		}
+	}
+	if !r.flag() { // timing_info_present_flag
+		return
+	}
+	numUnits, timeScale := r.u(32), r.u(32)
+	if r.err == nil {
+		sps.NumUnitsInTick, sps.TimeScale = numUnits, timeScale
+	}
+}
+
+// readProfileTierLevel reads the profile_tier_level structure with profilePresentFlag set.
+func readProfileTierLevel(r *bitReader, maxSubLayersMinus1 int) *ProfileTierLevel {
+	ptl := &ProfileTierLevel{}
+	start := r.pos / 8
+	ptl.ProfileSpace = uint8(r.u(2))
+	ptl.Tier = uint8(r.u(1))
+	ptl.ProfileIdc = uint8(r.u(5))
+	ptl.ProfileCompatibility = r.u(32)
+	for i := range ptl.ConstraintFlags {
+		ptl.ConstraintFlags[i] = uint8(r.u(8))
+	}
+	ptl.LevelIdc = uint8(r.u(8))
+	if r.err == nil {
+		ptl.Raw = append([]byte{}, r.data[start:start+12]...)
+	}
+
+	profilePresent := make([]bool, maxSubLayersMinus1)
+	levelPresent := make([]bool, maxSubLayersMinus1)
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		profilePresent[i] = r.flag()
+		levelPresent[i] = r.flag()
+	}
+	if maxSubLayersMinus1 > 0 {
+		r.skip(2 * (8 - maxSubLayersMinus1))
+	}
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		if profilePresent[i] {
+			r.skip(88)
+		}
+		if levelPresent[i] {
+			r.skip(8)
+		}
+	}
+	return ptl
+}
+
+func skipH264ScalingList(r *bitReader, size int) {
+	last, next := int32(8), int32(8)
+	for j := 0; j < size && r.err == nil; j++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+func skipH265ScalingListData(r *bitReader) {
+	for sizeId := 0; sizeId < 4; sizeId++ {
+		step := 1
+		if sizeId == 3 {
+			step = 3
+		}
+		for matrixId := 0; matrixId < 6; matrixId += step {
+			if !r.flag() { // scaling_list_pred_mode_flag
+				r.ue() // scaling_list_pred_matrix_id_delta
+				continue
+			}
+			coefs := 1 << (4 + sizeId<<1)
+			if coefs > 64 {
+				coefs = 64
+			}
+			if sizeId > 1 {
+				r.se() // scaling_list_dc_coef_minus8
+			}
+			for i := 0; i < coefs && r.err == nil; i++ {
+				r.se()
+			}
+		}
+	}
+}
+
+// skipH265ShortTermRefPicSets skips the st_ref_pic_set structures of the SPS.
+func skipH265ShortTermRefPicSets(r *bitReader) {
+	sets := int(r.ue())
+	if sets > 64 {
+		r.err = ErrTruncated
+		return
+	}
+	numDeltaPocs := make([]int, sets)
+	for i := 0; i < sets && r.err == nil; i++ {
+		if i != 0 && r.flag() { // inter_ref_pic_set_prediction_flag
+			r.skip(1) // delta_rps_sign
+			r.ue()    // abs_delta_rps_minus1
+			for j := 0; j <= numDeltaPocs[i-1]; j++ {
+				used := r.flag()
+				if used || r.flag() { // used_by_curr_pic_flag, use_delta_flag
+					numDeltaPocs[i]++
+				}
+			}
+			continue
+		}
+		negative, positive := int(r.ue()), int(r.ue())
+		if negative > 16 || positive > 16 {
+			r.err = ErrTruncated
+			return
+		}
+		for j := 0; j < negative+positive; j++ {
+			r.ue()    // delta_poc_minus1
+			r.skip(1) // used_by_curr_pic_flag
+		}
+		numDeltaPocs[i] = negative + positive
+	}
+}
+
+func h265ProfileName(idc uint8) string {
+	switch idc {
+	case 1:
+		return "Main"
+	case 2:
+		return "Main 10"
+	case 3:
+		return "Main Still Picture"
+	case 4:
+		return "Range Extensions"
+	default:
+		return fmt.Sprintf("Unknown(%d)", idc)
+	}
+}
@@ -0,0 +1,73 @@
+package bitstream
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSPS(t *testing.T) {
+	tests := []struct {
+		name      string
+		codec     Codec
+		sps       string // Base64 like in the sprop-parameter-sets of an SDP.
+		profile   string
+		level     float64
+		width     int
+		height    int
+		frameRate float64
+		chromaIdc uint32
+		bitDepth  int
+		subLayers int
+	}{
+		// Sent by Axis cameras for a 640x480 baseline stream, the VUI carries no timing info.
+		{"H264 Axis baseline", H264, "Z0IAKeKQFAe2AtwEBAaQeJEV", "Baseline", 4.1, 640, 480, 0, 1, 8, 0},
+		{"H264 main 720p", H264, "Z01AKZpkAoAt/zUBAQFAAAD6AAA6mDoYAGGoAAYagC7y40MADDUAAMNQBd5cKAA=", "Main", 4.1, 1280, 720, 30, 1, 8, 0},
+		// The cropping removes 8 rows of the 1088 coded rows, the RBSP contains an emulation prevention byte.
+		{"H264 high 1080p", H264, "Z2QAKKwbGoB4AiflwFuAgICgAAADACAAAAZR4oRUAA==", "High", 4.0, 1920, 1080, 25, 1, 8, 0},
+		{"H265 main 720p", H265, "QgEBAWAAAAMAkAAAAwAAAwBdoAKAgC0WNrkky/AIAAADAAgAAAMAyEA=", "Main", 3.1, 1280, 720, 25, 1, 8, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nalu, err := base64.StdEncoding.DecodeString(tt.sps)
+			require.NoError(t, err)
+			sps, err := ParseSPS(nalu, tt.codec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.codec, sps.Codec)
+			assert.Equal(t, tt.profile, sps.Profile())
+			assert.InDelta(t, tt.level, sps.Level(), 0.001)
+			assert.Equal(t, tt.width, sps.Width)
+			assert.Equal(t, tt.height, sps.Height)
+			assert.InDelta(t, tt.frameRate, sps.FrameRate(), 0.001)
+			assert.Equal(t, tt.chromaIdc, sps.ChromaFormatIdc)
+			assert.Equal(t, tt.bitDepth, sps.BitDepthLuma)
+			assert.Equal(t, tt.bitDepth, sps.BitDepthChroma)
+			assert.Equal(t, tt.subLayers, sps.MaxSubLayers)
+			if tt.codec == H265 {
+				require.NotNil(t, sps.ProfileTierLevel)
+				assert.Len(t, sps.ProfileTierLevel.Raw, 12)
+			}
+
+			// Parse finds the same SPS in an access unit, trailing zero bytes belong to the byte stream.
+			units := Parse(AppendAnnexB(nil, nalu), tt.codec)
+			unit, found := Find(units, NalKindSPS)
+			require.True(t, found)
+			assert.Equal(t, bytes.TrimRight(nalu, "\x00"), unit.Data)
+		})
+	}
+}
+
+func TestParseSPSErrors(t *testing.T) {
+	nalu, err := base64.StdEncoding.DecodeString("Z0IAKeKQFAe2AtwEBAaQeJEV")
+	require.NoError(t, err)
+
+	_, err = ParseSPS([]byte{0x68, 0xce, 0x3c, 0x80}, H264)
+	assert.ErrorIs(t, err, ErrNotParameterSet)
+	_, err = ParseSPS(nalu, H265)
+	assert.ErrorIs(t, err, ErrNotParameterSet)
+	_, err = ParseSPS(nalu[:6], H264)
+	assert.ErrorIs(t, err, ErrTruncated)
+}
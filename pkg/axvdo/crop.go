@@ -1,12 +1,10 @@
 package axvdo
 
+import "github.com/Cacsjep/goxis/pkg/axvdo/vdoimage"
+
 // CropArea defines the dimensions and position of the crop area.
-type CropArea struct {
-	Width  int
-	Height int
-	X      int
-	Y      int
-}
+// It is defined in vdoimage, so the pure Go image functions can use it without cgo.
+type CropArea = vdoimage.CropArea
 
 // calculateCropDimensions calculates the cropping dimensions and position based on input and stream sizes.
 func CalculateCropDimensions(inputWidth, inputHeight, streamWidth, streamHeight int) CropArea {
@@ -0,0 +1,130 @@
+package axvdo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+)
+
+// ErrNoSPS is returned when an encoded frame carries no SPS, only IDR frames contain the parameter sets.
+var ErrNoSPS = errors.New("frame contains no SPS, use an IDR frame")
+
+// BitstreamCodec returns the bitstream codec of an H.264 or H.265 format.
+func BitstreamCodec(format VdoFormat) (bitstream.Codec, bool) {
+	switch format {
+	case VdoFormatH264:
+		return bitstream.H264, true
+	case VdoFormatH265:
+		return bitstream.H265, true
+	default:
+		return 0, false
+	}
+}
+
+// NalUnits splits the data of an H.264 or H.265 frame into NAL units, the units share the memory of the frame.
+func (f *VideoFrame) NalUnits() ([]bitstream.NalUnit, error) {
+	codec, ok := BitstreamCodec(VdoFrameIsOfFormat(f.Type))
+	if !ok {
+		return nil, fmt.Errorf("frame type %s is not H.264 or H.265", f.Type)
+	}
+	return bitstream.Parse(f.encodedData(), codec), nil
+}
+
+// IsKeyframe reports whether the frame contains a keyframe slice, detected from the NAL unit types instead of the frame type.
+func (f *VideoFrame) IsKeyframe() bool {
+	units, err := f.NalUnits()
+	if err != nil {
+		return false
+	}
+	for _, u := range units {
+		if u.IsKeyframe() {
+			return true
+		}
+	}
+	return false
+}
+
+// SPS decodes the sequence parameter set in the header of an IDR frame.
+func (f *VideoFrame) SPS() (*bitstream.SPS, error) {
+	units, err := f.NalUnits()
+	if err != nil {
+		return nil, err
+	}
+	unit, ok := bitstream.Find(units, bitstream.NalKindSPS)
+	if !ok {
+		return nil, ErrNoSPS
+	}
+	return bitstream.ParseSPS(unit.Data, unit.Codec)
+}
+
+// ValidateFrame checks the SPS of an IDR frame against the configuration the stream was created with.
+// It compares the format, the resolution, the profile and, when the stream signals timing info, the frame rate.
+// With a rotation of 90 or 270 degrees both orientations of the resolution are accepted.
+// All mismatches are returned joined in one error.
+func (c *VideoSteamConfiguration) ValidateFrame(frame *VideoFrame) error {
+	format := VdoFrameIsOfFormat(frame.Type)
+	if c.Format != nil && *c.Format != format {
+		return fmt.Errorf("format mismatch: configured %d, frame type %s", *c.Format, frame.Type)
+	}
+	sps, err := frame.SPS()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if c.Width != nil && c.Height != nil {
+		match := sps.Width == *c.Width && sps.Height == *c.Height
+		if c.Rotation != nil && (*c.Rotation == StreamRotation90 || *c.Rotation == StreamRotation270) {
+			match = match || (sps.Width == *c.Height && sps.Height == *c.Width)
+		}
+		if !match {
+			errs = append(errs, fmt.Errorf("resolution mismatch: configured %dx%d, stream %dx%d", *c.Width, *c.Height, sps.Width, sps.Height))
+		}
+	} else if c.Width != nil && sps.Width != *c.Width {
+		errs = append(errs, fmt.Errorf("width mismatch: configured %d, stream %d", *c.Width, sps.Width))
+	} else if c.Height != nil && sps.Height != *c.Height {
+		errs = append(errs, fmt.Errorf("height mismatch: configured %d, stream %d", *c.Height, sps.Height))
+	}
+
+	if c.Framerate != nil && sps.FrameRate() > 0 && math.Abs(sps.FrameRate()-float64(*c.Framerate)) > 0.5 {
+		errs = append(errs, fmt.Errorf("frame rate mismatch: configured %d, stream %.2f", *c.Framerate, sps.FrameRate()))
+	}
+
+	if profile, ok := c.profileIdc(format); ok && profile != sps.ProfileIdc {
+		errs = append(errs, fmt.Errorf("profile mismatch: configured profile_idc %d, stream %s (%d)", profile, sps.Profile(), sps.ProfileIdc))
+	}
+	return errors.Join(errs...)
+}
+
+// profileIdc returns the profile_idc of the configured profile.
+func (c *VideoSteamConfiguration) profileIdc(format VdoFormat) (uint8, bool) {
+	switch {
+	case format == VdoFormatH264 && c.H264Profile != nil:
+		switch *c.H264Profile {
+		case VdoH264ProfileBaseline:
+			return 66, true
+		case VdoH264ProfileMain:
+			return 77, true
+		case VdoH264ProfileHigh:
+			return 100, true
+		}
+	case format == VdoFormatH265 && c.H265Profile != nil:
+		switch *c.H265Profile {
+		case VdoH265ProfileMain:
+			return 1, true
+		case VdoH265ProfileMain10:
+			return 2, true
+		}
+	}
+	return 0, false
+}
+
+// encodedData returns the data of the frame without the unused capacity of the buffer.
+func (f *VideoFrame) encodedData() []byte {
+	if int(f.Size) <= len(f.Data) {
+		return f.Data[:f.Size]
+	}
+	return f.Data
+}
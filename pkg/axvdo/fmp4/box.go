@@ -0,0 +1,57 @@
+package fmp4
+
+import "encoding/binary"
+
+// boxWriter builds ISO BMFF boxes in memory.
+type boxWriter struct {
+	buf []byte
+}
+
+func (b *boxWriter) u8(v uint8) {
+	b.buf = append(b.buf, v)
+}
+
+func (b *boxWriter) u16(v uint16) {
+	b.buf = binary.BigEndian.AppendUint16(b.buf, v)
+}
+
+func (b *boxWriter) u32(v uint32) {
+	b.buf = binary.BigEndian.AppendUint32(b.buf, v)
+}
+
+func (b *boxWriter) u64(v uint64) {
+	b.buf = binary.BigEndian.AppendUint64(b.buf, v)
+}
+
+func (b *boxWriter) bytes(v []byte) {
+	b.buf = append(b.buf, v...)
+}
+
+func (b *boxWriter) zeros(n int) {
+	b.buf = append(b.buf, make([]byte, n)...)
+}
+
+// box writes a box of the given type, content writes its payload. It returns the offset of the box.
+func (b *boxWriter) box(typ string, content func()) int {
+	start := len(b.buf)
+	b.u32(0)
+	b.buf = append(b.buf, typ...)
+	content()
+	binary.BigEndian.PutUint32(b.buf[start:], uint32(len(b.buf)-start))
+	return start
+}
+
+// fullBox writes a box with version and flags.
+func (b *boxWriter) fullBox(typ string, version uint8, flags uint32, content func()) int {
+	return b.box(typ, func() {
+		b.u32(uint32(version)<<24 | flags&0xffffff)
+		content()
+	})
+}
+
+// matrix writes the unity transformation matrix of mvhd and tkhd.
+func (b *boxWriter) matrix() {
+	for _, v := range []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000} {
+		b.u32(v)
+	}
+}
@@ -0,0 +1,129 @@
+package fmp4
+
+import (
+	"fmt"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+)
+
+// avcDecoderConfig builds the AVCDecoderConfigurationRecord of the avcC box.
+func avcDecoderConfig(sps, pps [][]byte, info *bitstream.SPS) []byte {
+	b := &boxWriter{}
+	b.u8(1)
+	b.bytes(sps[0][1:4]) // profile_idc, constraint flags, level_idc
+	b.u8(0xfc | 3)       // 4 byte NAL unit lengths
+	b.u8(0xe0 | uint8(len(sps)))
+	for _, s := range sps {
+		b.u16(uint16(len(s)))
+		b.bytes(s)
+	}
+	b.u8(uint8(len(pps)))
+	for _, p := range pps {
+		b.u16(uint16(len(p)))
+		b.bytes(p)
+	}
+	switch sps[0][1] {
+	case 100, 110, 122, 144:
+		b.u8(0xfc | uint8(info.ChromaFormatIdc))
+		b.u8(0xf8 | uint8(info.BitDepthLuma-8))
+		b.u8(0xf8 | uint8(info.BitDepthChroma-8))
+		b.u8(0)
+	}
+	return b.buf
+}
+
+// hevcDecoderConfig builds the HEVCDecoderConfigurationRecord of the hvcC box.
+func hevcDecoderConfig(vps, sps, pps [][]byte, info *bitstream.SPS) []byte {
+	b := &boxWriter{}
+	b.u8(1)
+	b.bytes(info.ProfileTierLevel.Raw)
+	b.u16(0xf000) // min_spatial_segmentation_idc
+	b.u8(0xfc)    // parallelismType
+	b.u8(0xfc | uint8(info.ChromaFormatIdc))
+	b.u8(0xf8 | uint8(info.BitDepthLuma-8))
+	b.u8(0xf8 | uint8(info.BitDepthChroma-8))
+	b.u16(0) // avgFrameRate
+	nesting := uint8(0)
+	if info.TemporalIdNesting {
+		nesting = 1
+	}
+	b.u8(uint8(info.MaxSubLayers)<<3 | nesting<<2 | 3)
+	b.u8(3)
+	for _, array := range []struct {
+		nalType uint8
+		nalus   [][]byte
+	}{{bitstream.H265NalVPS, vps}, {bitstream.H265NalSPS, sps}, {bitstream.H265NalPPS, pps}} {
+		b.u8(0x80 | array.nalType)
+		b.u16(uint16(len(array.nalus)))
+		for _, n := range array.nalus {
+			b.u16(uint16(len(n)))
+			b.bytes(n)
+		}
+	}
+	return b.buf
+}
+
+// codecConfig holds the parameter sets of a track.
+type codecConfig struct {
+	vps, sps, pps [][]byte
+	info          *bitstream.SPS
+}
+
+// sampleEntry builds the avc1 or hvc1 sample entry of the stsd box.
+func (c *codecConfig) sampleEntry(b *boxWriter, codec Codec) {
+	typ, configType := "avc1", "avcC"
+	if codec == H265 {
+		typ, configType = "hvc1", "hvcC"
+	}
+	b.box(typ, func() {
+		b.zeros(6)
+		b.u16(1) // data_reference_index
+		b.zeros(16)
+		b.u16(uint16(c.info.Width))
+		b.u16(uint16(c.info.Height))
+		b.u32(0x00480000) // 72 dpi
+		b.u32(0x00480000)
+		b.u32(0)
+		b.u16(1) // frame_count
+		b.zeros(32)
+		b.u16(0x18) // depth
+		b.u16(0xffff)
+		b.box(configType, func() {
+			if codec == H265 {
+				b.bytes(hevcDecoderConfig(c.vps, c.sps, c.pps, c.info))
+			} else {
+				b.bytes(avcDecoderConfig(c.sps, c.pps, c.info))
+			}
+		})
+	})
+}
+
+// codecString returns the RFC 6381 codecs parameter of the track, for example for a MediaSource mime type.
+func (c *codecConfig) codecString(codec Codec) string {
+	if codec == H264 {
+		return fmt.Sprintf("avc1.%02x%02x%02x", c.sps[0][1], c.sps[0][2], c.sps[0][3])
+	}
+	ptl := c.info.ProfileTierLevel.Raw
+	space := []string{"", "A", "B", "C"}[ptl[0]>>6]
+	tier := "L"
+	if ptl[0]&0x20 != 0 {
+		tier = "H"
+	}
+	// The compatibility flags are written in reverse bit order.
+	compat := uint32(ptl[1])<<24 | uint32(ptl[2])<<16 | uint32(ptl[3])<<8 | uint32(ptl[4])
+	var reversed uint32
+	for i := 0; i < 32; i++ {
+		reversed |= (compat >> i & 1) << (31 - i)
+	}
+	s := fmt.Sprintf("hvc1.%s%d.%X.%s%d", space, ptl[0]&0x1f, reversed, tier, ptl[11])
+	// Constraint bytes are appended up to the last non-zero byte.
+	constraints := ptl[5:11]
+	last := len(constraints)
+	for last > 0 && constraints[last-1] == 0 {
+		last--
+	}
+	for _, b := range constraints[:last] {
+		s += fmt.Sprintf(".%X", b)
+	}
+	return s
+}
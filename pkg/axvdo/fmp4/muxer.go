@@ -0,0 +1,417 @@
+// Package fmp4 writes H.264 and H.265 Annex-B access units as fragmented MP4,
+// which plays in browsers (Media Source Extensions), VLC and ffmpeg while it is still being written.
+//
+// The muxer writes an init segment (ftyp and moov) when the first keyframe with parameter sets arrives
+// and a fragment (moof and mdat) for each group of pictures. Samples before the first keyframe are dropped.
+package fmp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+)
+
+// Timescale is the number of ticks per second of the video track.
+const Timescale = 90000
+
+// DefaultSampleDuration is used for the last sample of a fragment written by Flush or Close,
+// when there was no previous sample to derive the duration from.
+const DefaultSampleDuration = Timescale / 30
+
+// Codec is the video codec of a track.
+type Codec = bitstream.Codec
+
+const (
+	H264 = bitstream.H264
+	H265 = bitstream.H265
+)
+
+var (
+	ErrMuxerClosed          = errors.New("muxer is closed")
+	ErrNoNalUnits           = bitstream.ErrNoNalUnits
+	ErrParameterSetsChanged = errors.New("parameter sets changed, start a new muxer")
+)
+
+// sample is an access unit converted to length prefixed NAL units.
+type sample struct {
+	data      []byte
+	keyframe  bool
+	timestamp time.Time
+	duration  uint32
+}
+
+// Muxer writes fragmented MP4 to an io.Writer.
+type Muxer struct {
+	// FragmentDuration is the minimum duration of a fragment. A new fragment starts at the first keyframe
+	// after this duration, 0 starts a fragment at every keyframe.
+	FragmentDuration time.Duration
+
+	mu       sync.Mutex
+	w        io.Writer
+	closer   io.Closer
+	codec    Codec
+	config   *codecConfig
+	pending  []sample
+	start    time.Time
+	sequence uint32
+	duration uint32 // The duration of the last completed sample.
+	written  int64
+	err      error
+	closed   bool
+}
+
+// NewMuxer creates a muxer that writes the fragmented MP4 of the codec to w.
+func NewMuxer(w io.Writer, codec Codec) *Muxer {
+	return &Muxer{w: w, codec: codec}
+}
+
+// NewFileMuxer creates the file at path and a muxer that writes to it. Close closes the file.
+func NewFileMuxer(path string, codec Codec) (*Muxer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	m := NewMuxer(f, codec)
+	m.closer = f
+	return m, nil
+}
+
+// Codec returns the codec of the muxer.
+func (m *Muxer) Codec() Codec {
+	return m.codec
+}
+
+// CodecString returns the RFC 6381 codecs parameter, for example avc1.640029,
+// to build a mime type like video/mp4; codecs="avc1.640029". It is empty until the init segment is written.
+func (m *Muxer) CodecString() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.config == nil {
+		return ""
+	}
+	return m.config.codecString(m.codec)
+}
+
+// Written returns the number of bytes written to the writer.
+func (m *Muxer) Written() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.written
+}
+
+// WriteSample adds an Annex-B access unit captured at timestamp, for example the data of an encoded VideoFrame.
+// The duration of a sample is the difference to the timestamp of the next sample.
+// Samples before the first keyframe carrying SPS and PPS (and VPS for H.265) are dropped.
+func (m *Muxer) WriteSample(data []byte, timestamp time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrMuxerClosed
+	}
+	if m.err != nil {
+		return m.err
+	}
+
+	units := bitstream.Parse(data, m.codec)
+	if len(units) == 0 {
+		return ErrNoNalUnits
+	}
+	s, vps, sps, pps := convert(units)
+	s.timestamp = timestamp
+
+	if s.keyframe && len(sps) > 0 {
+		if m.config == nil {
+			if err := m.init(vps, sps, pps); err != nil {
+				return err
+			}
+		} else if !bytes.Equal(m.config.sps[0], sps[0]) {
+			return ErrParameterSetsChanged
+		}
+	}
+	if m.config == nil || len(s.data) == 0 {
+		return nil
+	}
+
+	if n := len(m.pending); n > 0 {
+		m.pending[n-1].duration = m.durationTo(m.pending[n-1].timestamp, timestamp)
+		m.duration = m.pending[n-1].duration
+		if s.keyframe && timestamp.Sub(m.pending[0].timestamp) >= m.FragmentDuration {
+			if err := m.writeFragment(); err != nil {
+				return err
+			}
+		}
+	}
+	m.pending = append(m.pending, s)
+	return nil
+}
+
+// Flush writes the pending samples as a fragment, the duration of the last sample is estimated.
+// Flushing outside of keyframes creates fragments that do not start with a keyframe, which is valid but
+// may not be seekable in every player.
+func (m *Muxer) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrMuxerClosed
+	}
+	return m.flush()
+}
+
+// Close flushes the pending samples and closes the file of a muxer created by NewFileMuxer.
+func (m *Muxer) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	err := m.flush()
+	if m.closer != nil {
+		if cerr := m.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (m *Muxer) flush() error {
+	if m.err != nil {
+		return m.err
+	}
+	if len(m.pending) == 0 {
+		return nil
+	}
+	duration := m.duration
+	if duration == 0 {
+		duration = DefaultSampleDuration
+	}
+	m.pending[len(m.pending)-1].duration = duration
+	return m.writeFragment()
+}
+
+// convert splits the parameter sets from the NAL units and converts the rest to 4 byte length prefixes.
+func convert(units []bitstream.NalUnit) (s sample, vps, sps, pps [][]byte) {
+	for _, unit := range units {
+		switch unit.Kind() {
+		case bitstream.NalKindVPS:
+			vps = append(vps, unit.Data)
+			continue
+		case bitstream.NalKindSPS:
+			sps = append(sps, unit.Data)
+			continue
+		case bitstream.NalKindPPS:
+			pps = append(pps, unit.Data)
+			continue
+		case bitstream.NalKindAUD:
+			continue
+		case bitstream.NalKindIDR:
+			s.keyframe = true
+		}
+		s.data = binary.BigEndian.AppendUint32(s.data, uint32(len(unit.Data)))
+		s.data = append(s.data, unit.Data...)
+	}
+	return s, vps, sps, pps
+}
+
+// init parses the SPS and writes the init segment.
+func (m *Muxer) init(vps, sps, pps [][]byte) error {
+	if len(pps) == 0 || (m.codec == H265 && len(vps) == 0) {
+		return nil
+	}
+	info, err := bitstream.ParseSPS(sps[0], m.codec)
+	if err != nil {
+		return fmt.Errorf("parse %s SPS: %w", m.codec, err)
+	}
+	config := &codecConfig{vps: copyNalus(vps), sps: copyNalus(sps), pps: copyNalus(pps), info: info}
+	if err := m.write(initSegment(config, m.codec)); err != nil {
+		return err
+	}
+	m.config = config
+	return nil
+}
+
+// durationTo returns the ticks between two timestamps, falling back to the last duration if time went backwards.
+func (m *Muxer) durationTo(from, to time.Time) uint32 {
+	ticks := toTicks(to.Sub(from))
+	if ticks <= 0 {
+		if m.duration > 0 {
+			return m.duration
+		}
+		return 1
+	}
+	return uint32(ticks)
+}
+
+// writeFragment writes the pending samples as moof and mdat box.
+func (m *Muxer) writeFragment() error {
+	if m.start.IsZero() {
+		m.start = m.pending[0].timestamp
+	}
+	baseTime := uint64(0)
+	if d := m.pending[0].timestamp.Sub(m.start); d > 0 {
+		baseTime = uint64(toTicks(d))
+	}
+	m.sequence++
+
+	b := &boxWriter{}
+	var dataOffset int
+	b.box("moof", func() {
+		b.fullBox("mfhd", 0, 0, func() {
+			b.u32(m.sequence)
+		})
+		b.box("traf", func() {
+			b.fullBox("tfhd", 0, 0x020000, func() { // default-base-is-moof
+				b.u32(1)
+			})
+			b.fullBox("tfdt", 1, 0, func() {
+				b.u64(baseTime)
+			})
+			// data-offset, sample-duration, sample-size and sample-flags present.
+			b.fullBox("trun", 0, 0x000701, func() {
+				b.u32(uint32(len(m.pending)))
+				dataOffset = len(b.buf)
+				b.u32(0)
+				for _, s := range m.pending {
+					b.u32(s.duration)
+					b.u32(uint32(len(s.data)))
+					if s.keyframe {
+						b.u32(0x02000000)
+					} else {
+						b.u32(0x01010000) // depends on others, non sync sample
+					}
+				}
+			})
+		})
+	})
+	binary.BigEndian.PutUint32(b.buf[dataOffset:], uint32(len(b.buf)+8))
+
+	size := 8
+	for _, s := range m.pending {
+		size += len(s.data)
+	}
+	b.u32(uint32(size))
+	b.bytes([]byte("mdat"))
+	for _, s := range m.pending {
+		b.bytes(s.data)
+	}
+	m.pending = m.pending[:0]
+	return m.write(b.buf)
+}
+
+// write writes data to the writer, errors are kept and returned by all further calls.
+func (m *Muxer) write(data []byte) error {
+	n, err := m.w.Write(data)
+	m.written += int64(n)
+	if err != nil {
+		m.err = fmt.Errorf("write fragmented mp4: %w", err)
+	}
+	return m.err
+}
+
+// initSegment builds the ftyp and moov box of a single video track.
+func initSegment(config *codecConfig, codec Codec) []byte {
+	b := &boxWriter{}
+	b.box("ftyp", func() {
+		b.bytes([]byte("isom"))
+		b.u32(0x200)
+		b.bytes([]byte("isomiso5iso6mp41"))
+	})
+	b.box("moov", func() {
+		b.fullBox("mvhd", 0, 0, func() {
+			b.u32(0)    // creation_time
+			b.u32(0)    // modification_time
+			b.u32(1000) // timescale
+			b.u32(0)    // duration
+			b.u32(0x00010000)
+			b.u16(0x0100)
+			b.zeros(10)
+			b.matrix()
+			b.zeros(24)
+			b.u32(2) // next_track_ID
+		})
+		b.box("trak", func() {
+			b.fullBox("tkhd", 0, 3, func() { // enabled and in movie
+				b.u32(0)
+				b.u32(0)
+				b.u32(1) // track_ID
+				b.u32(0)
+				b.u32(0) // duration
+				b.zeros(8)
+				b.u16(0) // layer
+				b.u16(0) // alternate_group
+				b.u16(0) // volume
+				b.u16(0)
+				b.matrix()
+				b.u32(uint32(config.info.Width) << 16)
+				b.u32(uint32(config.info.Height) << 16)
+			})
+			b.box("mdia", func() {
+				b.fullBox("mdhd", 0, 0, func() {
+					b.u32(0)
+					b.u32(0)
+					b.u32(Timescale)
+					b.u32(0)
+					b.u16(0x55c4) // und
+					b.u16(0)
+				})
+				b.fullBox("hdlr", 0, 0, func() {
+					b.u32(0)
+					b.bytes([]byte("vide"))
+					b.zeros(12)
+					b.bytes([]byte("VideoHandler\x00"))
+				})
+				b.box("minf", func() {
+					b.fullBox("vmhd", 0, 1, func() {
+						b.zeros(8)
+					})
+					b.box("dinf", func() {
+						b.fullBox("dref", 0, 0, func() {
+							b.u32(1)
+							b.fullBox("url ", 0, 1, func() {}) // media is in the same file
+						})
+					})
+					b.box("stbl", func() {
+						b.fullBox("stsd", 0, 0, func() {
+							b.u32(1)
+							config.sampleEntry(b, codec)
+						})
+						b.fullBox("stts", 0, 0, func() { b.u32(0) })
+						b.fullBox("stsc", 0, 0, func() { b.u32(0) })
+						b.fullBox("stsz", 0, 0, func() { b.u32(0); b.u32(0) })
+						b.fullBox("stco", 0, 0, func() { b.u32(0) })
+					})
+				})
+			})
+		})
+		b.box("mvex", func() {
+			b.fullBox("trex", 0, 0, func() {
+				b.u32(1) // track_ID
+				b.u32(1) // default_sample_description_index
+				b.u32(0)
+				b.u32(0)
+				b.u32(0)
+			})
+		})
+	})
+	return b.buf
+}
+
+// toTicks converts a duration to the timescale without overflowing for long recordings.
+func toTicks(d time.Duration) int64 {
+	return int64(d/time.Second)*Timescale + int64(d%time.Second)*Timescale/int64(time.Second)
+}
+
+func copyNalus(nalus [][]byte) [][]byte {
+	out := make([][]byte, len(nalus))
+	for i, n := range nalus {
+		out[i] = append([]byte{}, n...)
+	}
+	return out
+}
@@ -0,0 +1,293 @@
+package fmp4
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// box is a parsed ISO BMFF box.
+type box struct {
+	typ     string
+	payload []byte
+}
+
+// readBoxes parses the consecutive boxes of data, they must fill it completely.
+func readBoxes(t *testing.T, data []byte) []box {
+	t.Helper()
+	var boxes []box
+	for len(data) > 0 {
+		require.GreaterOrEqual(t, len(data), 8, "box header")
+		size := int(binary.BigEndian.Uint32(data))
+		require.GreaterOrEqual(t, size, 8, "box size")
+		require.LessOrEqual(t, size, len(data), "box %q exceeds its parent", data[4:8])
+		boxes = append(boxes, box{typ: string(data[4:8]), payload: data[8:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+// child returns the payload of the only child box of the type.
+func child(t *testing.T, data []byte, typ string) []byte {
+	t.Helper()
+	var found []byte
+	for _, b := range readBoxes(t, data) {
+		if b.typ == typ {
+			require.Nil(t, found, "duplicate %s box", typ)
+			found = b.payload
+		}
+	}
+	require.NotNil(t, found, "no %s box", typ)
+	return found
+}
+
+// types returns the box types of data in order.
+func types(t *testing.T, data []byte) []string {
+	t.Helper()
+	var out []string
+	for _, b := range readBoxes(t, data) {
+		out = append(out, b.typ)
+	}
+	return out
+}
+
+func mustDecode(s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var (
+	h264SPS     = mustDecode("Z0IAKeKQFAe2AtwEBAaQeJEV") // Baseline 640x480.
+	h264HighSPS = mustDecode("Z2QAKKwbGoB4AiflwFuAgICgAAADACAAAAZR4oRU")
+	h264PPS     = mustDecode("aM48gA==")
+	h265SPS     = mustDecode("QgEBAWAAAAMAkAAAAwAAAwBdoAKAgC0WNrkky/AIAAADAAgAAAMAyEA=") // Main 1280x720.
+	h265VPS     = []byte{0x40, 0x01, 0x0c, 0x01, 0xff, 0xff, 0x01, 0x60}
+	h265PPS     = []byte{0x44, 0x01, 0xc1, 0x72, 0xb4, 0x62, 0x40}
+)
+
+// accessUnit builds an Annex-B access unit of an H.264 stream with an AUD, keyframes carry sps and the PPS.
+func accessUnit(sps []byte, payload byte) []byte {
+	if sps == nil {
+		return bitstream.AppendAnnexB(nil, []byte{0x09, 0x30}, []byte{0x41, 0x9a, payload})
+	}
+	return bitstream.AppendAnnexB(nil, []byte{0x09, 0x10}, sps, h264PPS, []byte{0x65, 0x88, payload})
+}
+
+func TestInitSegmentH264(t *testing.T) {
+	tests := []struct {
+		name          string
+		sps           []byte
+		width, height int
+		codecString   string
+		chroma        []byte // The trailing chroma and bit depth fields of the avcC of high profiles.
+	}{
+		{"baseline", h264SPS, 640, 480, "avc1.420029", nil},
+		{"high", h264HighSPS, 1920, 1080, "avc1.640028", []byte{0xfd, 0xf8, 0xf8, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			m := NewMuxer(&out, H264)
+			assert.Empty(t, m.CodecString())
+			require.NoError(t, m.WriteSample(accessUnit(tt.sps, 1), time.Now()))
+			assert.Equal(t, tt.codecString, m.CodecString())
+			assert.Equal(t, int64(out.Len()), m.Written())
+
+			// The fragment is written with the next keyframe or Flush, so only the init segment is out.
+			data := out.Bytes()
+			require.Equal(t, []string{"ftyp", "moov"}, types(t, data))
+			assert.Equal(t, []byte("isom"), child(t, data, "ftyp")[:4])
+
+			moov := child(t, data, "moov")
+			assert.Equal(t, []string{"mvhd", "trak", "mvex"}, types(t, moov))
+			trak := child(t, moov, "trak")
+			tkhd := child(t, trak, "tkhd")
+			assert.Equal(t, uint32(1), binary.BigEndian.Uint32(tkhd[12:]), "track_ID")
+			assert.Equal(t, uint32(tt.width<<16), binary.BigEndian.Uint32(tkhd[76:]))
+			assert.Equal(t, uint32(tt.height<<16), binary.BigEndian.Uint32(tkhd[80:]))
+
+			mdia := child(t, trak, "mdia")
+			assert.Equal(t, uint32(Timescale), binary.BigEndian.Uint32(child(t, mdia, "mdhd")[12:]))
+			assert.Equal(t, []byte("vide"), child(t, mdia, "hdlr")[8:12])
+			stbl := child(t, child(t, mdia, "minf"), "stbl")
+			assert.Equal(t, []string{"stsd", "stts", "stsc", "stsz", "stco"}, types(t, stbl))
+
+			stsd := child(t, stbl, "stsd")
+			assert.Equal(t, uint32(1), binary.BigEndian.Uint32(stsd[4:]), "entry_count")
+			avc1 := child(t, stsd[8:], "avc1")
+			assert.Equal(t, uint16(tt.width), binary.BigEndian.Uint16(avc1[24:]))
+			assert.Equal(t, uint16(tt.height), binary.BigEndian.Uint16(avc1[26:]))
+
+			sps, pps := tt.sps, h264PPS
+			want := []byte{1, sps[1], sps[2], sps[3], 0xff, 0xe1}
+			want = binary.BigEndian.AppendUint16(want, uint16(len(sps)))
+			want = append(want, sps...)
+			want = append(want, 1)
+			want = binary.BigEndian.AppendUint16(want, uint16(len(pps)))
+			want = append(want, pps...)
+			want = append(want, tt.chroma...)
+			assert.Equal(t, want, child(t, avc1[78:], "avcC"))
+
+			mvex := child(t, moov, "mvex")
+			assert.Equal(t, uint32(1), binary.BigEndian.Uint32(child(t, mvex, "trex")[4:]), "track_ID")
+		})
+	}
+}
+
+func TestInitSegmentH265(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMuxer(&out, H265)
+	sps := h265SPS
+	au := bitstream.AppendAnnexB(nil, h265VPS, sps, h265PPS, []byte{0x26, 0x01, 0xaf, 0x01})
+	require.NoError(t, m.WriteSample(au, time.Now()))
+	assert.Equal(t, "hvc1.1.6.L93.90", m.CodecString())
+
+	data := out.Bytes()
+	require.Equal(t, []string{"ftyp", "moov"}, types(t, data))
+	stbl := child(t, child(t, child(t, child(t, child(t, data, "moov"), "trak"), "mdia"), "minf"), "stbl")
+	hvc1 := child(t, child(t, stbl, "stsd")[8:], "hvc1")
+	assert.Equal(t, uint16(1280), binary.BigEndian.Uint16(hvc1[24:]))
+	assert.Equal(t, uint16(720), binary.BigEndian.Uint16(hvc1[26:]))
+
+	hvcC := child(t, hvc1[78:], "hvcC")
+	assert.Equal(t, byte(1), hvcC[0])
+	assert.Equal(t, bitstream.Unescape(sps[2:])[1:13], hvcC[1:13], "profile_tier_level")
+	assert.Equal(t, byte(0xfd), hvcC[16], "chroma_format_idc")
+	assert.Equal(t, byte(3), hvcC[21]&3, "lengthSizeMinusOne")
+	require.Equal(t, byte(3), hvcC[22], "numOfArrays")
+	arrays := hvcC[23:]
+	for _, want := range []struct {
+		typ  byte
+		nalu []byte
+	}{{bitstream.H265NalVPS, h265VPS}, {bitstream.H265NalSPS, sps}, {bitstream.H265NalPPS, h265PPS}} {
+		assert.Equal(t, 0x80|want.typ, arrays[0])
+		assert.Equal(t, uint16(1), binary.BigEndian.Uint16(arrays[1:]))
+		n := int(binary.BigEndian.Uint16(arrays[3:]))
+		assert.Equal(t, want.nalu, arrays[5:5+n])
+		arrays = arrays[5+n:]
+	}
+	assert.Empty(t, arrays)
+}
+
+// fragment is a parsed moof and mdat pair.
+type fragment struct {
+	sequence   uint32
+	baseTime   uint64
+	durations  []uint32
+	sizes      []uint32
+	flags      []uint32
+	dataOffset uint32
+	moofSize   int
+	mdat       []byte
+}
+
+func readFragment(t *testing.T, data []byte) fragment {
+	t.Helper()
+	boxes := readBoxes(t, data)
+	require.Len(t, boxes, 2)
+	require.Equal(t, "moof", boxes[0].typ)
+	require.Equal(t, "mdat", boxes[1].typ)
+	f := fragment{moofSize: len(boxes[0].payload) + 8, mdat: boxes[1].payload}
+
+	moof := boxes[0].payload
+	require.Equal(t, []string{"mfhd", "traf"}, types(t, moof))
+	f.sequence = binary.BigEndian.Uint32(child(t, moof, "mfhd")[4:])
+	traf := child(t, moof, "traf")
+	require.Equal(t, []string{"tfhd", "tfdt", "trun"}, types(t, traf))
+	tfhd := child(t, traf, "tfhd")
+	assert.Equal(t, uint32(0x020000), binary.BigEndian.Uint32(tfhd), "default-base-is-moof")
+	assert.Equal(t, uint32(1), binary.BigEndian.Uint32(tfhd[4:]), "track_ID")
+	tfdt := child(t, traf, "tfdt")
+	assert.Equal(t, byte(1), tfdt[0], "version")
+	f.baseTime = binary.BigEndian.Uint64(tfdt[4:])
+
+	trun := child(t, traf, "trun")
+	assert.Equal(t, uint32(0x000701), binary.BigEndian.Uint32(trun))
+	count := int(binary.BigEndian.Uint32(trun[4:]))
+	f.dataOffset = binary.BigEndian.Uint32(trun[8:])
+	require.Len(t, trun, 12+12*count)
+	for i := 0; i < count; i++ {
+		entry := trun[12+12*i:]
+		f.durations = append(f.durations, binary.BigEndian.Uint32(entry))
+		f.sizes = append(f.sizes, binary.BigEndian.Uint32(entry[4:]))
+		f.flags = append(f.flags, binary.BigEndian.Uint32(entry[8:]))
+	}
+	return f
+}
+
+func TestFragments(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMuxer(&out, H264)
+	start := time.Unix(1700000000, 0)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	// Samples before the first keyframe are dropped.
+	require.NoError(t, m.WriteSample(accessUnit(nil, 9), at(-40)))
+	assert.Zero(t, m.Written())
+
+	require.NoError(t, m.WriteSample(accessUnit(h264SPS, 1), at(0)))
+	initSize := out.Len()
+	require.NoError(t, m.WriteSample(accessUnit(nil, 2), at(40)))
+	require.NoError(t, m.WriteSample(accessUnit(nil, 3), at(80)))
+	assert.Equal(t, initSize, out.Len(), "no fragment before the next keyframe")
+	require.NoError(t, m.WriteSample(accessUnit(h264SPS, 4), at(120)))
+
+	first := readFragment(t, out.Bytes()[initSize:])
+	assert.Equal(t, uint32(1), first.sequence)
+	assert.Zero(t, first.baseTime)
+	assert.Equal(t, []uint32{3600, 3600, 3600}, first.durations)
+	assert.Equal(t, []uint32{0x02000000, 0x01010000, 0x01010000}, first.flags)
+	assert.Equal(t, uint32(first.moofSize+8), first.dataOffset, "data offset points to the mdat payload")
+
+	// The mdat holds the samples as length prefixed NAL units, without AUD and parameter sets.
+	want := []byte{0, 0, 0, 3, 0x65, 0x88, 1, 0, 0, 0, 3, 0x41, 0x9a, 2, 0, 0, 0, 3, 0x41, 0x9a, 3}
+	assert.Equal(t, want, first.mdat)
+	assert.Equal(t, []uint32{7, 7, 7}, first.sizes)
+
+	fragmentEnd := out.Len()
+	require.NoError(t, m.Close())
+	second := readFragment(t, out.Bytes()[fragmentEnd:])
+	assert.Equal(t, uint32(2), second.sequence)
+	assert.Equal(t, uint64(120*Timescale/1000), second.baseTime)
+	assert.Equal(t, []uint32{3600}, second.durations, "the last sample keeps the previous duration")
+	assert.Equal(t, []uint32{0x02000000}, second.flags)
+	assert.Equal(t, []byte{0, 0, 0, 3, 0x65, 0x88, 4}, second.mdat)
+
+	assert.ErrorIs(t, m.WriteSample(accessUnit(h264SPS, 5), at(160)), ErrMuxerClosed)
+	assert.Equal(t, int64(out.Len()), m.Written())
+}
+
+func TestFragmentDuration(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMuxer(&out, H264)
+	m.FragmentDuration = time.Second
+	start := time.Unix(1700000000, 0)
+
+	require.NoError(t, m.WriteSample(accessUnit(h264SPS, 1), start))
+	initSize := out.Len()
+	// A keyframe within FragmentDuration does not start a new fragment.
+	require.NoError(t, m.WriteSample(accessUnit(h264SPS, 2), start.Add(500*time.Millisecond)))
+	assert.Equal(t, initSize, out.Len())
+	require.NoError(t, m.WriteSample(accessUnit(h264SPS, 3), start.Add(time.Second)))
+
+	f := readFragment(t, out.Bytes()[initSize:])
+	assert.Equal(t, []uint32{Timescale / 2, Timescale / 2}, f.durations)
+	assert.Equal(t, []uint32{0x02000000, 0x02000000}, f.flags)
+}
+
+func TestParameterSetsChanged(t *testing.T) {
+	m := NewMuxer(&bytes.Buffer{}, H264)
+	now := time.Now()
+	require.NoError(t, m.WriteSample(accessUnit(h264SPS, 1), now))
+	assert.ErrorIs(t, m.WriteSample(accessUnit(h264HighSPS, 2), now.Add(time.Second)), ErrParameterSetsChanged)
+	assert.ErrorIs(t, m.WriteSample([]byte{1, 2, 3}, now.Add(2*time.Second)), ErrNoNalUnits)
+}
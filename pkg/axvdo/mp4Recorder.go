@@ -0,0 +1,55 @@
+package axvdo
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/fmp4"
+)
+
+// MP4Recorder writes encoded H.264 or H.265 VideoFrames as fragmented MP4, which plays in browsers and VLC.
+// The codec configuration is built from the parameter sets in the HeaderData of the first IDR frame,
+// a fragment is written at every keyframe.
+type MP4Recorder struct {
+	*fmp4.Muxer
+}
+
+// NewMP4Recorder creates a recorder that writes the frames of a stream with the given format to w.
+func NewMP4Recorder(w io.Writer, format VdoFormat) (*MP4Recorder, error) {
+	codec, err := mp4Codec(format)
+	if err != nil {
+		return nil, err
+	}
+	return &MP4Recorder{Muxer: fmp4.NewMuxer(w, codec)}, nil
+}
+
+// NewMP4FileRecorder creates the file at path and a recorder that writes the frames of a stream with the given format to it.
+// Close flushes the last fragment and closes the file.
+func NewMP4FileRecorder(path string, format VdoFormat) (*MP4Recorder, error) {
+	codec, err := mp4Codec(format)
+	if err != nil {
+		return nil, err
+	}
+	muxer, err := fmp4.NewFileMuxer(path, codec)
+	if err != nil {
+		return nil, err
+	}
+	return &MP4Recorder{Muxer: muxer}, nil
+}
+
+// WriteFrame adds an encoded frame, the sample duration is taken from the timestamps of the frames.
+// Frames with an error are skipped, frames before the first IDR frame are dropped.
+func (r *MP4Recorder) WriteFrame(frame *VideoFrame) error {
+	if frame.Error != nil {
+		return nil
+	}
+	return r.WriteSample(frame.encodedData(), frame.Timestamp)
+}
+
+func mp4Codec(format VdoFormat) (fmp4.Codec, error) {
+	codec, ok := BitstreamCodec(format)
+	if !ok {
+		return 0, fmt.Errorf("MP4 recording needs an H.264 or H.265 stream, got format %d", format)
+	}
+	return codec, nil
+}
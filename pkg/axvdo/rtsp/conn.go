@@ -0,0 +1,331 @@
+package rtsp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxRequestBody = 64 << 10
+
+// request is a parsed RTSP request.
+type request struct {
+	method string
+	url    string
+	header textproto.MIMEHeader
+}
+
+// conn is an RTSP control connection.
+type conn struct {
+	server *Server
+	nc     net.Conn
+	br     *bufio.Reader
+	wmu    sync.Mutex // Serializes responses and interleaved packets.
+}
+
+func newConn(s *Server, nc net.Conn) *conn {
+	return &conn{server: s, nc: nc, br: bufio.NewReader(nc)}
+}
+
+func (c *conn) write(b []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.nc.Write(b)
+	return err
+}
+
+func (c *conn) serve() {
+	defer func() {
+		c.nc.Close()
+		c.server.removeConn(c)
+	}()
+	for {
+		req, err := c.readRequest()
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				c.server.logf("RTSP connection %s: %s", c.nc.RemoteAddr(), err.Error())
+			}
+			return
+		}
+		if !c.handle(req) {
+			return
+		}
+	}
+}
+
+// readRequest reads the next request, interleaved packets of the client like RTCP receiver reports are skipped.
+func (c *conn) readRequest() (*request, error) {
+	for {
+		b, err := c.br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if b[0] != '$' {
+			break
+		}
+		var header [4]byte
+		if _, err := io.ReadFull(c.br, header[:]); err != nil {
+			return nil, err
+		}
+		if _, err := c.br.Discard(int(binary.BigEndian.Uint16(header[2:]))); err != nil {
+			return nil, err
+		}
+	}
+
+	tp := textproto.NewReader(c.br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Fields(line)
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "RTSP/") {
+		return nil, fmt.Errorf("malformed request line %q", line)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n, _ := strconv.Atoi(header.Get("Content-Length")); n > 0 {
+		if n > maxRequestBody {
+			return nil, fmt.Errorf("request body of %d bytes is too large", n)
+		}
+		if _, err := c.br.Discard(n); err != nil {
+			return nil, err
+		}
+	}
+	return &request{method: parts[0], url: parts[1], header: header}, nil
+}
+
+// respond writes a response with the CSeq of the request, headers are pairs of name and value.
+func (c *conn) respond(req *request, status int, headers []string, body string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "RTSP/1.0 %d %s\r\n", status, statusText(status))
+	fmt.Fprintf(&b, "CSeq: %s\r\n", req.header.Get("CSeq"))
+	b.WriteString("Server: goxis\r\n")
+	for i := 0; i+1 < len(headers); i += 2 {
+		fmt.Fprintf(&b, "%s: %s\r\n", headers[i], headers[i+1])
+	}
+	if body != "" {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(body))
+	}
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	return c.write([]byte(b.String()))
+}
+
+// handle answers a request, it returns false when the connection should be closed.
+func (c *conn) handle(req *request) bool {
+	if sess, ok := c.requestSession(req); ok {
+		sess.touch()
+	}
+
+	var err error
+	switch req.method {
+	case "OPTIONS":
+		err = c.respond(req, 200, []string{"Public", "OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN, GET_PARAMETER"}, "")
+	case "DESCRIBE":
+		err = c.handleDescribe(req)
+	case "SETUP":
+		err = c.handleSetup(req)
+	case "PLAY":
+		err = c.handlePlay(req)
+	case "TEARDOWN":
+		if sess, ok := c.requestSession(req); ok {
+			sess.close()
+		}
+		err = c.respond(req, 200, nil, "")
+	case "GET_PARAMETER", "SET_PARAMETER":
+		err = c.respond(req, 200, nil, "")
+	default:
+		err = c.respond(req, 501, nil, "")
+	}
+	return err == nil
+}
+
+func (c *conn) handleDescribe(req *request) error {
+	st, found := c.server.streamForURL(req.url)
+	if !found {
+		return c.respond(req, 404, nil, "")
+	}
+	if !st.waitReady(c.server.DescribeTimeout) {
+		return c.respond(req, 503, []string{"Retry-After", "1"}, "")
+	}
+	params, _ := st.Parameters()
+	host, _, _ := net.SplitHostPort(c.nc.LocalAddr().String())
+	sdp := SessionDescription(c.server.Name, host, st.Codec, DefaultPayloadType, params)
+	base := strings.TrimSuffix(req.url, "/") + "/"
+	return c.respond(req, 200, []string{"Content-Type", "application/sdp", "Content-Base", base}, sdp)
+}
+
+func (c *conn) handleSetup(req *request) error {
+	st, found := c.server.streamForURL(req.url)
+	if !found {
+		return c.respond(req, 404, nil, "")
+	}
+	if _, ok := c.requestSession(req); ok {
+		// The stream has a single track, a second SETUP in the same session is not possible.
+		return c.respond(req, 459, nil, "")
+	}
+
+	transport := parseTransport(req.header.Get("Transport"))
+	sess := newSession(newSessionID(), st, c)
+	var reply string
+	switch {
+	case transport.interleaved:
+		sess.interleaved = true
+		sess.rtpChannel, sess.rtcpChannel = transport.channels[0], transport.channels[1]
+		reply = fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", sess.rtpChannel, sess.rtcpChannel)
+	case transport.clientPorts[0] != 0:
+		serverPorts, err := c.setupUDP(sess, transport.clientPorts)
+		if err != nil {
+			c.server.logf("RTSP setup of UDP transport failed: %s", err.Error())
+			return c.respond(req, 500, nil, "")
+		}
+		reply = fmt.Sprintf("RTP/AVP;unicast;client_port=%d-%d;server_port=%d-%d",
+			transport.clientPorts[0], transport.clientPorts[1], serverPorts[0], serverPorts[1])
+	default:
+		return c.respond(req, 461, nil, "")
+	}
+	reply += fmt.Sprintf(";ssrc=%08X", sess.packetizer.SSRC)
+
+	if err := c.server.addSession(sess); err != nil {
+		sess.close()
+		if errors.Is(err, ErrTooManySessions) {
+			c.server.logf("RTSP setup of %s from %s refused: %s", st.Path, c.nc.RemoteAddr(), err.Error())
+			return c.respond(req, 453, nil, "")
+		}
+		return c.respond(req, 503, nil, "")
+	}
+	return c.respond(req, 200, []string{
+		"Transport", reply,
+		"Session", fmt.Sprintf("%s;timeout=%d", sess.id, int(SessionTimeout.Seconds())),
+	}, "")
+}
+
+// setupUDP opens the server ports of a UDP session and returns them.
+func (c *conn) setupUDP(sess *session, clientPorts [2]int) ([2]int, error) {
+	local := c.nc.LocalAddr().(*net.TCPAddr)
+	remote := c.nc.RemoteAddr().(*net.TCPAddr)
+	var err error
+	if sess.udpRTP, err = net.ListenUDP("udp", &net.UDPAddr{IP: local.IP}); err != nil {
+		return [2]int{}, err
+	}
+	if sess.udpRTCP, err = net.ListenUDP("udp", &net.UDPAddr{IP: local.IP}); err != nil {
+		sess.udpRTP.Close()
+		return [2]int{}, err
+	}
+	sess.clientRTP = &net.UDPAddr{IP: remote.IP, Port: clientPorts[0]}
+	sess.clientRTCP = &net.UDPAddr{IP: remote.IP, Port: clientPorts[1]}
+	return [2]int{sess.udpRTP.LocalAddr().(*net.UDPAddr).Port, sess.udpRTCP.LocalAddr().(*net.UDPAddr).Port}, nil
+}
+
+func (c *conn) handlePlay(req *request) error {
+	sess, ok := c.requestSession(req)
+	if !ok {
+		return c.respond(req, 454, nil, "")
+	}
+	url := strings.TrimSuffix(req.url, "/")
+	if !strings.HasSuffix(url, trackControl) {
+		url += "/" + trackControl
+	}
+	headers := []string{"Session", sess.id, "Range", "npt=now-"}
+	if !sess.playing.Load() {
+		// The packetizer is owned by the session goroutine once it plays.
+		rtpInfo := fmt.Sprintf("url=%s;seq=%d;rtptime=%d", url, sess.packetizer.Sequence, sess.rtpTimestamp(time.Now()))
+		headers = append(headers, "RTP-Info", rtpInfo)
+	}
+	// The response must reach the client before the first interleaved packet.
+	if err := c.respond(req, 200, headers, ""); err != nil {
+		return err
+	}
+	sess.play()
+	return nil
+}
+
+// requestSession returns the session of the Session header.
+func (c *conn) requestSession(req *request) (*session, bool) {
+	id, _, _ := strings.Cut(req.header.Get("Session"), ";")
+	if id == "" {
+		return nil, false
+	}
+	return c.server.getSession(strings.TrimSpace(id))
+}
+
+// transport is the client part of a Transport header.
+type transport struct {
+	interleaved bool
+	channels    [2]byte
+	clientPorts [2]int
+}
+
+// parseTransport parses the first transport specification of a Transport header.
+func parseTransport(header string) transport {
+	t := transport{channels: [2]byte{0, 1}}
+	spec, _, _ := strings.Cut(header, ",")
+	for i, param := range strings.Split(spec, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch {
+		case i == 0:
+			t.interleaved = strings.HasSuffix(strings.ToUpper(key), "/TCP")
+		case key == "interleaved":
+			if a, b, ok := parseRange(value); ok && a < 255 {
+				t.channels = [2]byte{byte(a), byte(b)}
+			}
+		case key == "client_port":
+			if a, b, ok := parseRange(value); ok {
+				t.clientPorts = [2]int{a, b}
+			}
+		}
+	}
+	return t
+}
+
+// parseRange parses a range like 0-1, a single value n is the range n-(n+1).
+func parseRange(value string) (int, int, bool) {
+	first, second, found := strings.Cut(value, "-")
+	a, err := strconv.Atoi(first)
+	if err != nil || a < 0 || a > 65535 {
+		return 0, 0, false
+	}
+	if !found {
+		return a, a + 1, true
+	}
+	b, err := strconv.Atoi(second)
+	if err != nil || b < 0 || b > 65535 {
+		return 0, 0, false
+	}
+	return a, b, true
+}
+
+func statusText(status int) string {
+	switch status {
+	case 200:
+		return "OK"
+	case 404:
+		return "Not Found"
+	case 453:
+		return "Not Enough Bandwidth"
+	case 454:
+		return "Session Not Found"
+	case 459:
+		return "Aggregate Operation Not Allowed"
+	case 461:
+		return "Unsupported Transport"
+	case 500:
+		return "Internal Server Error"
+	case 501:
+		return "Not Implemented"
+	case 503:
+		return "Service Unavailable"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,154 @@
+package rtsp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+)
+
+const (
+	// ClockRate is the RTP clock rate of H.264 and H.265.
+	ClockRate = 90000
+	// DefaultPayloadType is the dynamic payload type used for the video track.
+	DefaultPayloadType = 96
+	// DefaultMTU is the maximum size of an RTP packet including its header.
+	DefaultMTU = 1400
+	// MinMTU is the smallest MTU that fits the RTP header, the largest fragmentation unit header and one byte of payload.
+	MinMTU = rtpHeaderSize + 3 + 1
+
+	rtpHeaderSize  = 12
+	h264NalFUA     = 28
+	h265NalFU      = 49
+	fuStartBit     = 0x80
+	fuEndBit       = 0x40
+	rtcpSenderType = 200
+)
+
+// ErrInvalidMTU is returned by Packetize when the MTU of the Packetizer is below MinMTU.
+var ErrInvalidMTU = errors.New("rtsp: MTU is too small for RTP packets")
+
+// Packetizer splits access units into RTP packets, H.264 per RFC 6184 and H.265 per RFC 7798.
+// NAL units that fit into the MTU are sent as single NAL unit packets, larger ones as fragmentation units.
+type Packetizer struct {
+	Codec       bitstream.Codec
+	PayloadType uint8
+	SSRC        uint32
+	MTU         int
+	Sequence    uint16 // Sequence number of the next packet.
+}
+
+// NewPacketizer creates a packetizer with a random SSRC and initial sequence number.
+func NewPacketizer(codec bitstream.Codec) *Packetizer {
+	return &Packetizer{
+		Codec:       codec,
+		PayloadType: DefaultPayloadType,
+		SSRC:        randUint32(),
+		MTU:         DefaultMTU,
+		Sequence:    uint16(randUint32()),
+	}
+}
+
+// Packetize returns the RTP packets of an access unit with the given RTP timestamp.
+// Access unit delimiters are skipped, the marker bit is set on the last packet.
+func (p *Packetizer) Packetize(units []bitstream.NalUnit, timestamp uint32) ([][]byte, error) {
+	if p.MTU < MinMTU {
+		return nil, fmt.Errorf("%w: %d is below %d", ErrInvalidMTU, p.MTU, MinMTU)
+	}
+	var nalus [][]byte
+	for _, u := range units {
+		if u.Kind() != bitstream.NalKindAUD {
+			nalus = append(nalus, u.Data)
+		}
+	}
+
+	var packets [][]byte
+	maxPayload := p.MTU - rtpHeaderSize
+	for i, nalu := range nalus {
+		last := i == len(nalus)-1
+		if len(nalu) <= maxPayload {
+			packets = append(packets, p.packet(nalu, timestamp, last))
+			continue
+		}
+		packets = append(packets, p.fragment(nalu, timestamp, last, maxPayload)...)
+	}
+	return packets, nil
+}
+
+// fragment splits a NAL unit into FU-A (H.264) or FU (H.265) packets.
+func (p *Packetizer) fragment(nalu []byte, timestamp uint32, last bool, maxPayload int) [][]byte {
+	var header []byte
+	var payload []byte
+	if p.Codec == bitstream.H265 {
+		nalType := nalu[0] >> 1 & 0x3f
+		header = []byte{nalu[0]&0x81 | h265NalFU<<1, nalu[1], nalType}
+		payload = nalu[2:]
+	} else {
+		header = []byte{nalu[0]&0xe0 | h264NalFUA, nalu[0] & 0x1f}
+		payload = nalu[1:]
+	}
+	fuHeader := len(header) - 1
+	chunk := maxPayload - len(header)
+
+	var packets [][]byte
+	for start := 0; start < len(payload); start += chunk {
+		end := start + chunk
+		if end > len(payload) {
+			end = len(payload)
+		}
+		fu := append([]byte{}, header...)
+		if start == 0 {
+			fu[fuHeader] |= fuStartBit
+		}
+		if end == len(payload) {
+			fu[fuHeader] |= fuEndBit
+		}
+		fu = append(fu, payload[start:end]...)
+		packets = append(packets, p.packet(fu, timestamp, last && end == len(payload)))
+	}
+	return packets
+}
+
+func (p *Packetizer) packet(payload []byte, timestamp uint32, marker bool) []byte {
+	pkt := make([]byte, rtpHeaderSize, rtpHeaderSize+len(payload))
+	pkt[0] = 0x80 // version 2
+	pkt[1] = p.PayloadType & 0x7f
+	if marker {
+		pkt[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(pkt[2:], p.Sequence)
+	binary.BigEndian.PutUint32(pkt[4:], timestamp)
+	binary.BigEndian.PutUint32(pkt[8:], p.SSRC)
+	p.Sequence++
+	return append(pkt, payload...)
+}
+
+// SenderReport builds an RTCP sender report that maps the RTP timestamp to the wall clock time t.
+func SenderReport(ssrc uint32, t time.Time, timestamp uint32, packets, octets uint32) []byte {
+	pkt := make([]byte, 28)
+	pkt[0] = 0x80
+	pkt[1] = rtcpSenderType
+	binary.BigEndian.PutUint16(pkt[2:], 6) // length in 32 bit words minus one
+	binary.BigEndian.PutUint32(pkt[4:], ssrc)
+	ntpSeconds := uint64(t.Unix()) + 2208988800 // NTP counts from 1900
+	ntpFraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	binary.BigEndian.PutUint64(pkt[8:], ntpSeconds<<32|ntpFraction)
+	binary.BigEndian.PutUint32(pkt[16:], timestamp)
+	binary.BigEndian.PutUint32(pkt[20:], packets)
+	binary.BigEndian.PutUint32(pkt[24:], octets)
+	return pkt
+}
+
+// ticks converts a duration to the RTP clock rate without overflowing for long running streams.
+func ticks(d time.Duration) uint32 {
+	return uint32(int64(d/time.Second)*ClockRate + int64(d%time.Second)*ClockRate/int64(time.Second))
+}
+
+func randUint32() uint32 {
+	var b [4]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
@@ -0,0 +1,128 @@
+package rtsp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rtpPacket is a parsed RTP packet without CSRCs and extensions.
+type rtpPacket struct {
+	marker      bool
+	payloadType uint8
+	sequence    uint16
+	timestamp   uint32
+	ssrc        uint32
+	payload     []byte
+}
+
+func parseRTP(t *testing.T, pkt []byte) rtpPacket {
+	t.Helper()
+	require.GreaterOrEqual(t, len(pkt), rtpHeaderSize)
+	require.Equal(t, byte(0x80), pkt[0], "version 2 without padding, extension and CSRCs")
+	return rtpPacket{
+		marker:      pkt[1]&0x80 != 0,
+		payloadType: pkt[1] & 0x7f,
+		sequence:    binary.BigEndian.Uint16(pkt[2:]),
+		timestamp:   binary.BigEndian.Uint32(pkt[4:]),
+		ssrc:        binary.BigEndian.Uint32(pkt[8:]),
+		payload:     pkt[rtpHeaderSize:],
+	}
+}
+
+// payload returns n bytes of a NAL unit payload without start codes.
+func payload(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i%250 + 4)
+	}
+	return b
+}
+
+func units(codec bitstream.Codec, nalus ...[]byte) []bitstream.NalUnit {
+	return bitstream.Parse(bitstream.AppendAnnexB(nil, nalus...), codec)
+}
+
+func TestPacketizeSingleNalUnits(t *testing.T) {
+	p := &Packetizer{Codec: bitstream.H264, PayloadType: DefaultPayloadType, SSRC: 0x11223344, MTU: DefaultMTU, Sequence: 65534}
+	sps, pps, idr := []byte{0x67, 0x42, 0x00, 0x29}, []byte{0x68, 0xce, 0x3c, 0x80}, append([]byte{0x65}, payload(DefaultMTU-rtpHeaderSize-1)...)
+	packets, err := p.Packetize(units(bitstream.H264, []byte{0x09, 0x10}, sps, pps, idr), 1234)
+	require.NoError(t, err)
+	require.Len(t, packets, 3, "the AUD is skipped and the IDR fits exactly")
+
+	for i, want := range [][]byte{sps, pps, idr} {
+		pkt := parseRTP(t, packets[i])
+		assert.Equal(t, i == 2, pkt.marker, "marker of packet %d", i)
+		assert.Equal(t, uint8(DefaultPayloadType), pkt.payloadType)
+		assert.Equal(t, uint16(65534+i), pkt.sequence, "the sequence number wraps")
+		assert.Equal(t, uint32(1234), pkt.timestamp)
+		assert.Equal(t, uint32(0x11223344), pkt.ssrc)
+		assert.Equal(t, want, pkt.payload)
+	}
+	assert.Equal(t, uint16(1), p.Sequence)
+}
+
+func TestPacketizeFragmentationUnits(t *testing.T) {
+	tests := []struct {
+		name   string
+		codec  bitstream.Codec
+		header []byte // The NAL unit header of the fragmented unit.
+		fu     []byte // The payload header of the fragmentation units, without the FU header.
+		fuType byte   // The type in the FU header.
+		mtu    int
+		size   int
+	}{
+		{"H264 FU-A", bitstream.H264, []byte{0x65}, []byte{0x60 | h264NalFUA}, bitstream.H264NalIDR, 100, 1000},
+		{"H264 FU-A nri kept", bitstream.H264, []byte{0x41}, []byte{0x40 | h264NalFUA}, bitstream.H264NalSlice, DefaultMTU, 5000},
+		{"H264 FU-A minimal MTU", bitstream.H264, []byte{0x65}, []byte{0x60 | h264NalFUA}, bitstream.H264NalIDR, MinMTU, 10},
+		{"H265 FU", bitstream.H265, []byte{0x26, 0x01}, []byte{h265NalFU << 1, 0x01}, bitstream.H265NalIDRWRADL, 100, 1000},
+		{"H265 FU temporal id", bitstream.H265, []byte{0x02, 0x02}, []byte{h265NalFU << 1, 0x02}, 1, DefaultMTU, 5000},
+		{"H265 FU minimal MTU", bitstream.H265, []byte{0x26, 0x01}, []byte{h265NalFU << 1, 0x01}, bitstream.H265NalIDRWRADL, MinMTU, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Packetizer{Codec: tt.codec, PayloadType: DefaultPayloadType, MTU: tt.mtu}
+			nalu := append(append([]byte{}, tt.header...), payload(tt.size)...)
+			packets, err := p.Packetize(units(tt.codec, nalu), 90000)
+			require.NoError(t, err)
+			require.Greater(t, len(packets), 1)
+
+			var reassembled []byte
+			for i, raw := range packets {
+				assert.LessOrEqual(t, len(raw), tt.mtu)
+				pkt := parseRTP(t, raw)
+				first, last := i == 0, i == len(packets)-1
+				assert.Equal(t, last, pkt.marker, "marker of packet %d", i)
+				assert.Equal(t, uint16(i), pkt.sequence)
+
+				require.Greater(t, len(pkt.payload), len(tt.fu)+1)
+				assert.Equal(t, tt.fu, pkt.payload[:len(tt.fu)])
+				fuHeader := pkt.payload[len(tt.fu)]
+				assert.Equal(t, first, fuHeader&fuStartBit != 0, "start bit of packet %d", i)
+				assert.Equal(t, last, fuHeader&fuEndBit != 0, "end bit of packet %d", i)
+				assert.Equal(t, tt.fuType, fuHeader&0x3f)
+				reassembled = append(reassembled, pkt.payload[len(tt.fu)+1:]...)
+			}
+			assert.True(t, bytes.Equal(nalu[len(tt.header):], reassembled), "the fragments carry the NAL unit payload in order")
+		})
+	}
+}
+
+func TestPacketizeInvalidMTU(t *testing.T) {
+	for _, mtu := range []int{-1, 0, rtpHeaderSize, MinMTU - 1} {
+		for _, codec := range []bitstream.Codec{bitstream.H264, bitstream.H265} {
+			p := NewPacketizer(codec)
+			p.MTU = mtu
+			header := []byte{0x65}
+			if codec == bitstream.H265 {
+				header = []byte{0x26, 0x01}
+			}
+			_, err := p.Packetize(units(codec, append(header, payload(100)...)), 0)
+			assert.ErrorIs(t, err, ErrInvalidMTU, "MTU %d %s", mtu, codec)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package rtsp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+)
+
+// trackControl is the control attribute of the single video track.
+const trackControl = "trackID=0"
+
+// ParameterSets are the VPS, SPS and PPS NAL units of a stream, without start codes.
+type ParameterSets struct {
+	VPS [][]byte // H.265 only.
+	SPS [][]byte
+	PPS [][]byte
+}
+
+// MediaDescription returns the SDP media section of a video track with the given payload type,
+// including the fmtp parameters built from the parameter sets.
+func MediaDescription(codec bitstream.Codec, payloadType uint8, params ParameterSets) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "m=video 0 RTP/AVP %d\r\n", payloadType)
+	if codec == bitstream.H265 {
+		fmt.Fprintf(&b, "a=rtpmap:%d H265/%d\r\n", payloadType, ClockRate)
+		fmt.Fprintf(&b, "a=fmtp:%d sprop-vps=%s;sprop-sps=%s;sprop-pps=%s\r\n", payloadType,
+			encodeParameterSets(params.VPS), encodeParameterSets(params.SPS), encodeParameterSets(params.PPS))
+	} else {
+		fmt.Fprintf(&b, "a=rtpmap:%d H264/%d\r\n", payloadType, ClockRate)
+		fmtp := fmt.Sprintf("packetization-mode=1;sprop-parameter-sets=%s,%s",
+			encodeParameterSets(params.SPS), encodeParameterSets(params.PPS))
+		if len(params.SPS) > 0 && len(params.SPS[0]) >= 4 {
+			fmtp += fmt.Sprintf(";profile-level-id=%02X%02X%02X", params.SPS[0][1], params.SPS[0][2], params.SPS[0][3])
+		}
+		fmt.Fprintf(&b, "a=fmtp:%d %s\r\n", payloadType, fmtp)
+	}
+	fmt.Fprintf(&b, "a=control:%s\r\n", trackControl)
+	return b.String()
+}
+
+// SessionDescription returns a complete SDP of a single video track served from the host address.
+func SessionDescription(name, host string, codec bitstream.Codec, payloadType uint8, params ParameterSets) string {
+	ipVersion := "IP4"
+	if strings.Contains(host, ":") {
+		ipVersion = "IP6"
+	}
+	var b strings.Builder
+	b.WriteString("v=0\r\n")
+	fmt.Fprintf(&b, "o=- %d 1 IN %s %s\r\n", randUint32(), ipVersion, host)
+	fmt.Fprintf(&b, "s=%s\r\n", name)
+	fmt.Fprintf(&b, "c=IN %s %s\r\n", ipVersion, host)
+	b.WriteString("t=0 0\r\n")
+	b.WriteString("a=control:*\r\n")
+	b.WriteString("a=range:npt=now-\r\n")
+	b.WriteString(MediaDescription(codec, payloadType, params))
+	return b.String()
+}
+
+func encodeParameterSets(nalus [][]byte) string {
+	encoded := make([]string, len(nalus))
+	for i, n := range nalus {
+		encoded[i] = base64.StdEncoding.EncodeToString(n)
+	}
+	return strings.Join(encoded, ",")
+}
@@ -0,0 +1,92 @@
+package rtsp
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustDecode(s string) []byte {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestMediaDescription(t *testing.T) {
+	tests := []struct {
+		name   string
+		codec  bitstream.Codec
+		params ParameterSets
+		rtpmap string
+		fmtp   string
+	}{
+		{
+			"H264",
+			bitstream.H264,
+			ParameterSets{SPS: [][]byte{mustDecode("Z0IAKeKQFAe2AtwEBAaQeJEV")}, PPS: [][]byte{mustDecode("aM48gA==")}},
+			"a=rtpmap:96 H264/90000",
+			"a=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z0IAKeKQFAe2AtwEBAaQeJEV,aM48gA==;profile-level-id=420029",
+		},
+		{
+			"H264 two PPS",
+			bitstream.H264,
+			ParameterSets{SPS: [][]byte{mustDecode("Z2QAKKwbGoB4AiflwFuAgICgAAADACAAAAZR4oRU")}, PPS: [][]byte{{0x68, 0xee, 0x3c, 0xb0}, {0x68, 0x53, 0x8f, 0x2c}}},
+			"a=rtpmap:96 H264/90000",
+			"a=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z2QAKKwbGoB4AiflwFuAgICgAAADACAAAAZR4oRU,aO48sA==,aFOPLA==;profile-level-id=640028",
+		},
+		{
+			"H265",
+			bitstream.H265,
+			ParameterSets{
+				VPS: [][]byte{{0x40, 0x01, 0x0c, 0x01, 0xff, 0xff}},
+				SPS: [][]byte{mustDecode("QgEBAWAAAAMAkAAAAwAAAwBdoAKAgC0WNrkky/AIAAADAAgAAAMAyEA=")},
+				PPS: [][]byte{{0x44, 0x01, 0xc1, 0x72, 0xb4, 0x62, 0x40}},
+			},
+			"a=rtpmap:96 H265/90000",
+			"a=fmtp:96 sprop-vps=QAEMAf//;sprop-sps=QgEBAWAAAAMAkAAAAwAAAwBdoAKAgC0WNrkky/AIAAADAAgAAAMAyEA=;sprop-pps=RAHBcrRiQA==",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := strings.Split(MediaDescription(tt.codec, DefaultPayloadType, tt.params), "\r\n")
+			assert.Equal(t, []string{
+				"m=video 0 RTP/AVP 96",
+				tt.rtpmap,
+				tt.fmtp,
+				"a=control:" + trackControl,
+				"",
+			}, lines)
+		})
+	}
+}
+
+func TestSessionDescription(t *testing.T) {
+	params := ParameterSets{SPS: [][]byte{mustDecode("Z0IAKeKQFAe2AtwEBAaQeJEV")}, PPS: [][]byte{mustDecode("aM48gA==")}}
+	tests := []struct {
+		host       string
+		connection string
+	}{
+		{"192.168.0.90", "c=IN IP4 192.168.0.90"},
+		{"fe80::1", "c=IN IP6 fe80::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			sdp := SessionDescription("goxis", tt.host, bitstream.H264, DefaultPayloadType, params)
+			require.True(t, strings.HasSuffix(sdp, "\r\n"))
+			lines := strings.Split(strings.TrimSuffix(sdp, "\r\n"), "\r\n")
+			require.GreaterOrEqual(t, len(lines), 7)
+			assert.Equal(t, "v=0", lines[0])
+			assert.Regexp(t, `^o=- \d+ 1 IN IP[46] `+tt.host+`$`, lines[1])
+			assert.Equal(t, "s=goxis", lines[2])
+			assert.Equal(t, tt.connection, lines[3])
+			assert.Contains(t, lines, "a=control:*")
+			assert.Contains(t, lines, "a=fmtp:96 packetization-mode=1;sprop-parameter-sets=Z0IAKeKQFAe2AtwEBAaQeJEV,aM48gA==;profile-level-id=420029")
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Package rtsp serves H.264 and H.265 access units to RTSP clients like a VMS, VLC or ffmpeg.
+//
+// It contains the RTP packetization of RFC 6184 and RFC 7798, the SDP generation from the parameter sets
+// and a minimal RTSP 1.0 server with OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN and GET_PARAMETER
+// that delivers RTP interleaved in the RTSP connection or over UDP. It is pure Go without cgo.
+package rtsp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+)
+
+const (
+	// DefaultDescribeTimeout is how long a DESCRIBE waits for the first keyframe of a stream.
+	DefaultDescribeTimeout = 5 * time.Second
+	// DefaultMaxSessions is the number of sessions a Server accepts in total.
+	DefaultMaxSessions = 32
+	// DefaultMaxConnSessions is the number of sessions a single RTSP connection may set up.
+	DefaultMaxConnSessions = 4
+)
+
+var (
+	ErrServerClosed    = errors.New("rtsp: server closed")
+	ErrStreamRemoved   = errors.New("rtsp: stream removed")
+	ErrTooManySessions = errors.New("rtsp: too many sessions")
+)
+
+// Server is an RTSP server that serves the streams added with AddStream.
+type Server struct {
+	// Name is the session name of the SDP.
+	Name string
+	// DescribeTimeout is how long a DESCRIBE waits for the parameter sets of a stream, default is DefaultDescribeTimeout.
+	DescribeTimeout time.Duration
+	// MaxSessions caps the sessions of all clients, default is DefaultMaxSessions, 0 disables the cap.
+	// Every session copies the frames and may hold two UDP sockets, so the cap protects the device.
+	MaxSessions int
+	// MaxConnSessions caps the sessions set up by a single RTSP connection, default is DefaultMaxConnSessions, 0 disables the cap.
+	MaxConnSessions int
+	// Logf receives errors of connections and sessions, nil discards them.
+	Logf func(format string, args ...any)
+
+	mu        sync.Mutex
+	streams   map[string]*Stream
+	sessions  map[string]*session
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	closed    bool
+}
+
+// NewServer creates a server without streams.
+func NewServer() *Server {
+	return &Server{
+		Name:            "goxis",
+		DescribeTimeout: DefaultDescribeTimeout,
+		MaxSessions:     DefaultMaxSessions,
+		MaxConnSessions: DefaultMaxConnSessions,
+		streams:         make(map[string]*Stream),
+		sessions:        make(map[string]*session),
+		listeners:       make(map[net.Listener]struct{}),
+		conns:           make(map[*conn]struct{}),
+	}
+}
+
+// AddStream registers a stream under the path, for example "live" serves rtsp://host:port/live.
+func (s *Server) AddStream(path string, codec bitstream.Codec) (*Stream, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, errors.New("rtsp: stream path must not be empty")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.streams[path]; exists {
+		return nil, fmt.Errorf("rtsp: stream %s already exists", path)
+	}
+	st := newStream(path, codec)
+	s.streams[path] = st
+	return st, nil
+}
+
+// GetStream returns the stream registered under the path.
+func (s *Server) GetStream(path string) (*Stream, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, found := s.streams[strings.Trim(path, "/")]
+	return st, found
+}
+
+// RemoveStream unregisters the stream and ends its sessions.
+func (s *Server) RemoveStream(path string) {
+	s.mu.Lock()
+	st, found := s.streams[strings.Trim(path, "/")]
+	delete(s.streams, strings.Trim(path, "/"))
+	s.mu.Unlock()
+	if found {
+		st.remove()
+	}
+}
+
+// Serve accepts RTSP connections on the listener until it fails or the server is closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+	}()
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		c := newConn(s, nc)
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return ErrServerClosed
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		go c.serve()
+	}
+}
+
+// ListenAndServe listens on the TCP address and serves connections.
+func (s *Server) ListenAndServe(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Close stops all listeners, connections and sessions. The streams stay registered but receive no clients anymore.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	for l := range s.listeners {
+		if cerr := l.Close(); err == nil {
+			err = cerr
+		}
+	}
+	conns := s.conns
+	s.conns = make(map[*conn]struct{})
+	sessions := s.sessions
+	s.sessions = make(map[string]*session)
+	s.mu.Unlock()
+
+	for c := range conns {
+		c.nc.Close()
+	}
+	for _, sess := range sessions {
+		sess.close()
+	}
+	return err
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Logf != nil {
+		s.Logf(format, args...)
+	}
+}
+
+// streamForURL returns the stream of a request URL, the control suffix of the track is ignored.
+func (s *Server) streamForURL(rawURL string) (*Stream, bool) {
+	path := rawURL
+	if i := strings.Index(path, "://"); i >= 0 {
+		path = path[i+3:]
+		if j := strings.Index(path, "/"); j >= 0 {
+			path = path[j:]
+		} else {
+			path = ""
+		}
+	}
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), "/"+trackControl)
+	return s.GetStream(path)
+}
+
+// addSession registers the session, it fails when the server is closed or a session cap is reached.
+func (s *Server) addSession(sess *session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	if s.MaxSessions > 0 && len(s.sessions) >= s.MaxSessions {
+		return ErrTooManySessions
+	}
+	if s.MaxConnSessions > 0 {
+		owned := 0
+		for _, other := range s.sessions {
+			if other.conn == sess.conn {
+				owned++
+			}
+		}
+		if owned >= s.MaxConnSessions {
+			return ErrTooManySessions
+		}
+	}
+	s.sessions[sess.id] = sess
+	return nil
+}
+
+func (s *Server) getSession(id string) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, found := s.sessions[id]
+	return sess, found
+}
+
+func (s *Server) removeSession(sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions[sess.id] == sess {
+		delete(s.sessions, sess.id)
+	}
+}
+
+func (s *Server) removeConn(c *conn) {
+	s.mu.Lock()
+	delete(s.conns, c)
+	var owned []*session
+	for _, sess := range s.sessions {
+		// Interleaved sessions need the connection, UDP sessions that never played are abandoned.
+		if sess.conn == c && (sess.interleaved || !sess.playing.Load()) {
+			owned = append(owned, sess)
+		}
+	}
+	s.mu.Unlock()
+	for _, sess := range owned {
+		sess.close()
+	}
+}
+
+func newSessionID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
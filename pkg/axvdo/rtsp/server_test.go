@@ -0,0 +1,49 @@
+package rtsp
+
+import (
+	"testing"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionCaps(t *testing.T) {
+	s := NewServer()
+	s.MaxSessions = 3
+	s.MaxConnSessions = 2
+	st, err := s.AddStream("live", bitstream.H264)
+	require.NoError(t, err)
+	a, b := &conn{server: s}, &conn{server: s}
+
+	add := func(c *conn) (*session, error) {
+		sess := newSession(newSessionID(), st, c)
+		return sess, s.addSession(sess)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := add(a)
+		require.NoError(t, err, "session %d of a", i)
+	}
+	_, err = add(a)
+	assert.ErrorIs(t, err, ErrTooManySessions, "per connection cap")
+
+	last, err := add(b)
+	require.NoError(t, err)
+	_, err = add(b)
+	assert.ErrorIs(t, err, ErrTooManySessions, "server cap")
+
+	// Closed sessions free their slot.
+	last.close()
+	_, err = add(b)
+	assert.NoError(t, err)
+
+	s.MaxSessions, s.MaxConnSessions = 0, 0
+	for i := 0; i < 10; i++ {
+		_, err := add(a)
+		require.NoError(t, err, "uncapped session %d", i)
+	}
+
+	require.NoError(t, s.Close())
+	_, err = add(a)
+	assert.ErrorIs(t, err, ErrServerClosed)
+}
@@ -0,0 +1,178 @@
+package rtsp
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// SessionQueueDepth is the number of frames queued for a session, a session that falls behind
+	// drops frames and resumes at the next keyframe.
+	SessionQueueDepth = 60
+	// SessionTimeout is announced to clients, UDP sessions must send a keep-alive request within this time.
+	SessionTimeout = 60 * time.Second
+
+	senderReportInterval = 5 * time.Second
+)
+
+// session is the delivery of a stream to a client, over the RTSP connection (interleaved) or UDP.
+type session struct {
+	id         string
+	server     *Server
+	stream     *Stream
+	conn       *conn // The RTSP connection that created the session, interleaved packets are written to it.
+	packetizer *Packetizer
+	rtpBase    uint32 // Random offset of the RTP timestamps.
+
+	interleaved bool
+	rtpChannel  byte
+	rtcpChannel byte
+	udpRTP      *net.UDPConn
+	udpRTCP     *net.UDPConn
+	clientRTP   *net.UDPAddr
+	clientRTCP  *net.UDPAddr
+
+	queue     chan accessUnit
+	dropped   atomic.Bool
+	lastSeen  atomic.Int64 // Unix nano of the last request of the client.
+	playing   atomic.Bool
+	done      chan struct{}
+	closeOnce sync.Once
+	packets   uint32
+	octets    uint32
+}
+
+func newSession(id string, stream *Stream, c *conn) *session {
+	s := &session{
+		id:         id,
+		server:     c.server,
+		stream:     stream,
+		conn:       c,
+		packetizer: NewPacketizer(stream.Codec),
+		rtpBase:    randUint32(),
+		queue:      make(chan accessUnit, SessionQueueDepth),
+		done:       make(chan struct{}),
+	}
+	s.touch()
+	return s
+}
+
+// touch records a request of the client for the session timeout.
+func (s *session) touch() {
+	s.lastSeen.Store(time.Now().UnixNano())
+}
+
+// enqueue hands an access unit to the session without blocking, a full queue drops it.
+func (s *session) enqueue(au accessUnit) {
+	select {
+	case s.queue <- au:
+	default:
+		s.dropped.Store(true)
+	}
+}
+
+// play starts sending the queued access units, beginning with the next keyframe.
+func (s *session) play() {
+	if s.playing.Swap(true) {
+		return
+	}
+	if !s.stream.addSession(s) {
+		s.close()
+		return
+	}
+	go s.run()
+}
+
+// rtpTimestamp returns the RTP timestamp of a wall clock time of the stream.
+func (s *session) rtpTimestamp(t time.Time) uint32 {
+	return s.rtpBase + s.stream.rtpTimestamp(t)
+}
+
+func (s *session) run() {
+	defer s.stream.removeSession(s)
+	timeout := time.NewTicker(SessionTimeout / 4)
+	defer timeout.Stop()
+
+	waitKeyframe := true
+	var lastReport time.Time
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-timeout.C:
+			if !s.interleaved && time.Since(time.Unix(0, s.lastSeen.Load())) > SessionTimeout {
+				s.server.logf("RTSP session %s of %s timed out", s.id, s.stream.Path)
+				s.close()
+				return
+			}
+		case au := <-s.queue:
+			if s.dropped.Swap(false) {
+				waitKeyframe = true
+			}
+			if waitKeyframe && !au.keyframe {
+				continue
+			}
+			waitKeyframe = false
+
+			timestamp := s.rtpTimestamp(au.timestamp)
+			packets, err := s.packetizer.Packetize(au.units, timestamp)
+			if err != nil {
+				s.server.logf("RTSP session %s of %s ends: %s", s.id, s.stream.Path, err.Error())
+				s.close()
+				return
+			}
+			for _, pkt := range packets {
+				if err := s.send(pkt, false); err != nil {
+					s.server.logf("RTSP session %s of %s ends: %s", s.id, s.stream.Path, err.Error())
+					s.close()
+					return
+				}
+				s.packets++
+				s.octets += uint32(len(pkt) - rtpHeaderSize)
+			}
+			if time.Since(lastReport) >= senderReportInterval {
+				lastReport = time.Now()
+				s.send(SenderReport(s.packetizer.SSRC, au.timestamp, timestamp, s.packets, s.octets), true)
+			}
+		}
+	}
+}
+
+// send writes an RTP or RTCP packet to the client.
+func (s *session) send(pkt []byte, rtcp bool) error {
+	if s.interleaved {
+		channel := s.rtpChannel
+		if rtcp {
+			channel = s.rtcpChannel
+		}
+		frame := make([]byte, 4, 4+len(pkt))
+		frame[0] = '$'
+		frame[1] = channel
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(pkt)))
+		return s.conn.write(append(frame, pkt...))
+	}
+	if rtcp {
+		_, err := s.udpRTCP.WriteToUDP(pkt, s.clientRTCP)
+		return err
+	}
+	_, err := s.udpRTP.WriteToUDP(pkt, s.clientRTP)
+	return err
+}
+
+// close stops the session and releases its UDP sockets.
+func (s *session) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.stream.removeSession(s)
+		s.server.removeSession(s)
+		if s.udpRTP != nil {
+			s.udpRTP.Close()
+		}
+		if s.udpRTCP != nil {
+			s.udpRTCP.Close()
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package rtsp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/bitstream"
+)
+
+// accessUnit is a frame queued for the sessions of a stream.
+type accessUnit struct {
+	units     []bitstream.NalUnit
+	timestamp time.Time
+	keyframe  bool
+}
+
+// Stream is a video track served under a path of the Server. Frames written to it are sent to all playing sessions.
+type Stream struct {
+	Path  string
+	Codec bitstream.Codec
+
+	mu       sync.Mutex
+	params   ParameterSets
+	ready    chan struct{} // Closed when the parameter sets of the first keyframe are known.
+	epoch    time.Time     // Timestamp of the first access unit, the origin of the RTP timestamps.
+	sessions map[*session]struct{}
+	removed  bool
+}
+
+func newStream(path string, codec bitstream.Codec) *Stream {
+	return &Stream{Path: path, Codec: codec, ready: make(chan struct{}), sessions: make(map[*session]struct{})}
+}
+
+// WriteAccessUnit sends an Annex-B access unit captured at timestamp to all playing sessions without blocking.
+// The parameter sets for the SDP are taken from the keyframes, a session starts with the next keyframe.
+// The NAL units are shared with the sessions, data must not be modified afterwards.
+func (st *Stream) WriteAccessUnit(data []byte, timestamp time.Time) error {
+	units := bitstream.Parse(data, st.Codec)
+	if len(units) == 0 {
+		return bitstream.ErrNoNalUnits
+	}
+	au := accessUnit{units: units, timestamp: timestamp}
+	var params ParameterSets
+	for _, u := range units {
+		switch u.Kind() {
+		case bitstream.NalKindIDR:
+			au.keyframe = true
+		case bitstream.NalKindVPS:
+			params.VPS = append(params.VPS, append([]byte{}, u.Data...))
+		case bitstream.NalKindSPS:
+			params.SPS = append(params.SPS, append([]byte{}, u.Data...))
+		case bitstream.NalKindPPS:
+			params.PPS = append(params.PPS, append([]byte{}, u.Data...))
+		}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.removed {
+		return ErrStreamRemoved
+	}
+	if au.keyframe && len(params.SPS) > 0 && len(params.PPS) > 0 && (st.Codec != bitstream.H265 || len(params.VPS) > 0) {
+		st.params = params
+		select {
+		case <-st.ready:
+		default:
+			close(st.ready)
+		}
+	}
+	if st.epoch.IsZero() {
+		st.epoch = timestamp
+	}
+	for s := range st.sessions {
+		s.enqueue(au)
+	}
+	return nil
+}
+
+// Parameters returns the parameter sets of the last keyframe and whether a keyframe was written yet.
+func (st *Stream) Parameters() (ParameterSets, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	select {
+	case <-st.ready:
+		return st.params, true
+	default:
+		return ParameterSets{}, false
+	}
+}
+
+// Sessions returns the number of playing sessions.
+func (st *Stream) Sessions() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return len(st.sessions)
+}
+
+// rtpTimestamp returns the ticks of timestamp since the first access unit.
+func (st *Stream) rtpTimestamp(timestamp time.Time) uint32 {
+	st.mu.Lock()
+	epoch := st.epoch
+	st.mu.Unlock()
+	if epoch.IsZero() {
+		return 0
+	}
+	return ticks(timestamp.Sub(epoch))
+}
+
+// waitReady waits until the parameter sets are known.
+func (st *Stream) waitReady(timeout time.Duration) bool {
+	select {
+	case <-st.ready:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (st *Stream) addSession(s *session) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.removed {
+		return false
+	}
+	st.sessions[s] = struct{}{}
+	return true
+}
+
+func (st *Stream) removeSession(s *session) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.sessions, s)
+}
+
+// remove ends all sessions, further writes fail with ErrStreamRemoved.
+func (st *Stream) remove() {
+	st.mu.Lock()
+	st.removed = true
+	sessions := st.sessions
+	st.sessions = make(map[*session]struct{})
+	st.mu.Unlock()
+	for s := range sessions {
+		s.close()
+	}
+}
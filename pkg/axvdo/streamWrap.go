@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"reflect"
 	"time"
+
+	"github.com/Cacsjep/goxis/pkg/axvdo/vdoimage"
 )
 
 type StreamRotation int
@@ -192,6 +194,20 @@ func (f *VideoFrame) String() string {
 		f.SequenceNbr, f.Timestamp.Format("2006-01-02 15:04:05"), f.Size, f.Type.String())
 }
 
+// ImageFrame returns the data of the frame as a raw vdoimage.Frame with the given pixel format and resolution of the stream,
+// which converts it into Go images, for example:
+//
+//	img, err := frame.ImageFrame(vdoimage.FormatNV12, 640, 480, 0).Image()
+//
+// stride is the number of bytes per row of the first plane, 0 if the rows are not padded, see vdoimage.Frame.
+func (f *VideoFrame) ImageFrame(format vdoimage.PixelFormat, width, height, stride int) vdoimage.Frame {
+	data := f.Data
+	if int(f.Size) <= len(data) {
+		data = data[:f.Size]
+	}
+	return vdoimage.Frame{Format: format, Width: width, Height: height, Stride: stride, Data: data}
+}
+
 func (f *VideoFrame) HeaderData() []byte {
 	return f.Data[:f.HeaderSize]
 }
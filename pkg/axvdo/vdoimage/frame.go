@@ -0,0 +1,358 @@
+// Package vdoimage converts raw video frames into Go images and scales them, without cgo or a larod preprocessing device.
+//
+// The package has no dependency on the Axis libraries, so pipelines built on it can be tested on any machine.
+package vdoimage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// PixelFormat is the memory layout of a raw frame.
+type PixelFormat int
+
+const (
+	FormatNV12      PixelFormat = iota // Y plane followed by an interleaved CbCr plane with half resolution, the vdo YUV default.
+	FormatYUV420                       // Planar Y, Cb and Cr planes, the chroma planes have half resolution (I420).
+	FormatY800                         // Y plane only, 8-bit grayscale.
+	FormatRGB                          // Interleaved 8-bit R, G, B.
+	FormatPlanarRGB                    // Separate 8-bit R, G and B planes.
+)
+
+func (f PixelFormat) String() string {
+	switch f {
+	case FormatNV12:
+		return "NV12"
+	case FormatYUV420:
+		return "YUV420"
+	case FormatY800:
+		return "Y800"
+	case FormatRGB:
+		return "RGB"
+	case FormatPlanarRGB:
+		return "PlanarRGB"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(f))
+	}
+}
+
+// Frame is a raw frame, for example the Data of an axvdo.VideoFrame.
+//
+// Stride is the number of bytes per row of the first plane, it is derived from Width if 0.
+// The chroma rows of FormatNV12 use the stride rounded up to an even number, the chroma planes of FormatYUV420
+// half of the stride rounded up, so an odd width holds the last chroma sample without padding.
+type Frame struct {
+	Format PixelFormat
+	Width  int
+	Height int
+	Stride int
+	Data   []byte
+}
+
+// stride returns the stride of the first plane.
+func (f Frame) stride() int {
+	if f.Stride > 0 {
+		return f.Stride
+	}
+	if f.Format == FormatRGB {
+		return f.Width * 3
+	}
+	return f.Width
+}
+
+// chromaStride returns the stride of the chroma rows of YUV formats.
+func (f Frame) chromaStride() int {
+	s := f.stride()
+	if f.Format == FormatYUV420 {
+		return (s + 1) / 2
+	}
+	return (s + 1) &^ 1
+}
+
+// Size returns the number of bytes the frame occupies.
+func (f Frame) Size() int {
+	s := f.stride()
+	ch := (f.Height + 1) / 2
+	switch f.Format {
+	case FormatNV12:
+		return s*f.Height + f.chromaStride()*ch
+	case FormatYUV420:
+		return s*f.Height + 2*f.chromaStride()*ch
+	case FormatPlanarRGB:
+		return 3 * s * f.Height
+	}
+	return s * f.Height
+}
+
+// validate checks that the frame has dimensions and enough data.
+func (f Frame) validate() error {
+	if f.Width <= 0 || f.Height <= 0 {
+		return fmt.Errorf("invalid frame size %dx%d", f.Width, f.Height)
+	}
+	minStride := f.Width
+	if f.Format == FormatRGB {
+		minStride = f.Width * 3
+	}
+	if f.stride() < minStride {
+		return fmt.Errorf("stride %d is smaller than a row of %d bytes", f.stride(), minStride)
+	}
+	if f.Format < FormatNV12 || f.Format > FormatPlanarRGB {
+		return fmt.Errorf("unsupported pixel format %s", f.Format)
+	}
+	if len(f.Data) < f.Size() {
+		return fmt.Errorf("invalid %s data length: got %d, expected %d", f.Format, len(f.Data), f.Size())
+	}
+	return nil
+}
+
+// Image converts the frame into an image.Image.
+// YUV formats result in an *image.YCbCr, Y800 in an *image.Gray and RGB formats in an *image.RGBA.
+// YUV420 and Y800 images share the memory of Data.
+func (f Frame) Image() (image.Image, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	switch f.Format {
+	case FormatNV12, FormatYUV420:
+		return f.ycbcr(), nil
+	case FormatY800:
+		return f.gray(), nil
+	}
+	return f.rgba(), nil
+}
+
+// Gray returns the luma of the frame as an *image.Gray, RGB formats are converted with the ITU-R BT.601 weights.
+// For YUV and Y800 frames the image shares the memory of Data.
+func (f Frame) Gray() (*image.Gray, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	switch f.Format {
+	case FormatNV12, FormatYUV420, FormatY800:
+		return f.gray(), nil
+	}
+	return ToGray(f.rgba()), nil
+}
+
+// RGB converts the frame into a buffer of interleaved 8-bit RGB without padding, the input layout of most larod models.
+func (f Frame) RGB() ([]byte, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	s := f.stride()
+	out := make([]byte, f.Width*f.Height*3)
+	switch f.Format {
+	case FormatRGB:
+		for y := 0; y < f.Height; y++ {
+			copy(out[y*f.Width*3:(y+1)*f.Width*3], f.Data[y*s:])
+		}
+	case FormatPlanarRGB:
+		plane := s * f.Height
+		for y := 0; y < f.Height; y++ {
+			for x := 0; x < f.Width; x++ {
+				i, o := y*s+x, (y*f.Width+x)*3
+				out[o], out[o+1], out[o+2] = f.Data[i], f.Data[plane+i], f.Data[2*plane+i]
+			}
+		}
+	case FormatY800:
+		for y := 0; y < f.Height; y++ {
+			for x := 0; x < f.Width; x++ {
+				v := f.Data[y*s+x]
+				o := (y*f.Width + x) * 3
+				out[o], out[o+1], out[o+2] = v, v, v
+			}
+		}
+	default:
+		img := f.ycbcr()
+		for y := 0; y < f.Height; y++ {
+			for x := 0; x < f.Width; x++ {
+				r, g, b := color.YCbCrToRGB(img.Y[img.YOffset(x, y)], img.Cb[img.COffset(x, y)], img.Cr[img.COffset(x, y)])
+				o := (y*f.Width + x) * 3
+				out[o], out[o+1], out[o+2] = r, g, b
+			}
+		}
+	}
+	return out, nil
+}
+
+// PlanarRGB converts the frame into a buffer of separate R, G and B planes without padding.
+func (f Frame) PlanarRGB() ([]byte, error) {
+	rgb, err := f.RGB()
+	if err != nil {
+		return nil, err
+	}
+	return interleavedToPlanar(rgb, f.Width, f.Height), nil
+}
+
+func (f Frame) gray() *image.Gray {
+	s := f.stride()
+	return &image.Gray{Pix: f.Data[:s*f.Height], Stride: s, Rect: image.Rect(0, 0, f.Width, f.Height)}
+}
+
+func (f Frame) ycbcr() *image.YCbCr {
+	s := f.stride()
+	ch := (f.Height + 1) / 2
+	ySize := s * f.Height
+	cs := f.chromaStride()
+	if f.Format == FormatYUV420 {
+		return &image.YCbCr{
+			Y:              f.Data[:ySize],
+			Cb:             f.Data[ySize : ySize+cs*ch],
+			Cr:             f.Data[ySize+cs*ch : ySize+2*cs*ch],
+			YStride:        s,
+			CStride:        cs,
+			SubsampleRatio: image.YCbCrSubsampleRatio420,
+			Rect:           image.Rect(0, 0, f.Width, f.Height),
+		}
+	}
+
+	// NV12 interleaves the chroma samples, they are split into separate planes.
+	img := image.NewYCbCr(image.Rect(0, 0, f.Width, f.Height), image.YCbCrSubsampleRatio420)
+	for y := 0; y < f.Height; y++ {
+		copy(img.Y[y*img.YStride:y*img.YStride+f.Width], f.Data[y*s:])
+	}
+	uv := f.Data[ySize:]
+	cw := (f.Width + 1) / 2
+	for y := 0; y < ch; y++ {
+		for x := 0; x < cw; x++ {
+			i := y*img.CStride + x
+			img.Cb[i] = uv[y*cs+2*x]
+			img.Cr[i] = uv[y*cs+2*x+1]
+		}
+	}
+	return img
+}
+
+func (f Frame) rgba() *image.RGBA {
+	s := f.stride()
+	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+	plane := s * f.Height
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			o := y*img.Stride + x*4
+			if f.Format == FormatRGB {
+				i := y*s + x*3
+				img.Pix[o], img.Pix[o+1], img.Pix[o+2] = f.Data[i], f.Data[i+1], f.Data[i+2]
+			} else {
+				i := y*s + x
+				img.Pix[o], img.Pix[o+1], img.Pix[o+2] = f.Data[i], f.Data[plane+i], f.Data[2*plane+i]
+			}
+			img.Pix[o+3] = 0xff
+		}
+	}
+	return img
+}
+
+// NV12ToImage converts an NV12 frame without row padding into an *image.YCbCr.
+func NV12ToImage(data []byte, width, height int) (*image.YCbCr, error) {
+	img, err := Frame{Format: FormatNV12, Width: width, Height: height, Data: data}.Image()
+	if err != nil {
+		return nil, err
+	}
+	return img.(*image.YCbCr), nil
+}
+
+// YUV420ToImage converts a planar YUV 4:2:0 frame without row padding into an *image.YCbCr that shares data.
+func YUV420ToImage(data []byte, width, height int) (*image.YCbCr, error) {
+	img, err := Frame{Format: FormatYUV420, Width: width, Height: height, Data: data}.Image()
+	if err != nil {
+		return nil, err
+	}
+	return img.(*image.YCbCr), nil
+}
+
+// Y800ToGray converts a Y800 frame without row padding into an *image.Gray that shares data.
+func Y800ToGray(data []byte, width, height int) (*image.Gray, error) {
+	return Frame{Format: FormatY800, Width: width, Height: height, Data: data}.Gray()
+}
+
+// RGBToImage converts interleaved 8-bit RGB without row padding into an *image.RGBA.
+func RGBToImage(data []byte, width, height int) (*image.RGBA, error) {
+	img, err := Frame{Format: FormatRGB, Width: width, Height: height, Data: data}.Image()
+	if err != nil {
+		return nil, err
+	}
+	return img.(*image.RGBA), nil
+}
+
+// PlanarRGBToImage converts planar 8-bit RGB without row padding into an *image.RGBA.
+func PlanarRGBToImage(data []byte, width, height int) (*image.RGBA, error) {
+	img, err := Frame{Format: FormatPlanarRGB, Width: width, Height: height, Data: data}.Image()
+	if err != nil {
+		return nil, err
+	}
+	return img.(*image.RGBA), nil
+}
+
+// ToRGB converts any image into a buffer of interleaved 8-bit RGB without padding.
+func ToRGB(img image.Image) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := make([]byte, w*h*3)
+	switch src := img.(type) {
+	case *image.RGBA:
+		for y := 0; y < h; y++ {
+			row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			for x := 0; x < w; x++ {
+				o := (y*w + x) * 3
+				out[o], out[o+1], out[o+2] = row[x*4], row[x*4+1], row[x*4+2]
+			}
+		}
+	case *image.Gray:
+		for y := 0; y < h; y++ {
+			row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			for x := 0; x < w; x++ {
+				o := (y*w + x) * 3
+				out[o], out[o+1], out[o+2] = row[x], row[x], row[x]
+			}
+		}
+	default:
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := color.RGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+				o := (y*w + x) * 3
+				out[o], out[o+1], out[o+2] = c.R, c.G, c.B
+			}
+		}
+	}
+	return out
+}
+
+// ToPlanarRGB converts any image into a buffer of separate 8-bit R, G and B planes without padding.
+func ToPlanarRGB(img image.Image) []byte {
+	b := img.Bounds()
+	return interleavedToPlanar(ToRGB(img), b.Dx(), b.Dy())
+}
+
+// ToGray converts any image into an *image.Gray, an *image.Gray is returned as is.
+func ToGray(img image.Image) *image.Gray {
+	switch src := img.(type) {
+	case *image.Gray:
+		return src
+	case *image.YCbCr:
+		b := src.Rect
+		gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+		for y := 0; y < b.Dy(); y++ {
+			copy(gray.Pix[y*gray.Stride:(y+1)*gray.Stride], src.Y[src.YOffset(b.Min.X, b.Min.Y+y):])
+		}
+		return gray
+	}
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			gray.Pix[y*gray.Stride+x] = color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+		}
+	}
+	return gray
+}
+
+func interleavedToPlanar(rgb []byte, width, height int) []byte {
+	plane := width * height
+	out := make([]byte, plane*3)
+	for i := 0; i < plane; i++ {
+		out[i], out[plane+i], out[2*plane+i] = rgb[i*3], rgb[i*3+1], rgb[i*3+2]
+	}
+	return out
+}
@@ -0,0 +1,248 @@
+package vdoimage
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pad is written into the padding of test frames, it must never show up in a converted image.
+const pad = 0xee
+
+// yuvFrame builds a YUV frame where luma is y*width+x+1, Cb is 100+cy*cw+cx and Cr is 200+cy*cw+cx.
+func yuvFrame(format PixelFormat, width, height, stride int) Frame {
+	f := Frame{Format: format, Width: width, Height: height, Stride: stride}
+	f.Data = make([]byte, f.Size())
+	for i := range f.Data {
+		f.Data[i] = pad
+	}
+	s, cs := f.stride(), f.chromaStride()
+	cw, ch := (width+1)/2, (height+1)/2
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			f.Data[y*s+x] = byte(y*width + x + 1)
+		}
+	}
+	chroma := f.Data[s*height:]
+	for y := 0; y < ch; y++ {
+		for x := 0; x < cw; x++ {
+			cb, cr := byte(100+y*cw+x), byte(200+y*cw+x)
+			if format == FormatNV12 {
+				chroma[y*cs+2*x], chroma[y*cs+2*x+1] = cb, cr
+			} else {
+				chroma[y*cs+x], chroma[cs*ch+y*cs+x] = cb, cr
+			}
+		}
+	}
+	return f
+}
+
+// rgbFrame builds an interleaved RGB frame where the pixel at x, y is (x, y, x+y+1).
+func rgbFrame(width, height, stride int) Frame {
+	f := Frame{Format: FormatRGB, Width: width, Height: height, Stride: stride}
+	f.Data = make([]byte, f.Size())
+	for i := range f.Data {
+		f.Data[i] = pad
+	}
+	s := f.stride()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*s + x*3
+			f.Data[i], f.Data[i+1], f.Data[i+2] = byte(x), byte(y), byte(x+y+1)
+		}
+	}
+	return f
+}
+
+func TestFrameSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+		size  int
+	}{
+		{"NV12 even", Frame{Format: FormatNV12, Width: 4, Height: 2}, 8 + 4},
+		{"NV12 odd", Frame{Format: FormatNV12, Width: 3, Height: 3}, 9 + 2*4},
+		{"NV12 padded", Frame{Format: FormatNV12, Width: 3, Height: 2, Stride: 8}, 16 + 8},
+		{"YUV420 even", Frame{Format: FormatYUV420, Width: 4, Height: 4}, 16 + 2*2*2},
+		{"YUV420 odd", Frame{Format: FormatYUV420, Width: 3, Height: 3}, 9 + 2*2*2},
+		{"YUV420 padded", Frame{Format: FormatYUV420, Width: 3, Height: 3, Stride: 8}, 24 + 2*4*2},
+		{"Y800 padded", Frame{Format: FormatY800, Width: 3, Height: 2, Stride: 4}, 8},
+		{"RGB", Frame{Format: FormatRGB, Width: 3, Height: 2}, 18},
+		{"PlanarRGB", Frame{Format: FormatPlanarRGB, Width: 3, Height: 2}, 18},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.size, tt.frame.Size())
+		})
+	}
+}
+
+func TestFrameYUVImage(t *testing.T) {
+	tests := []struct {
+		name          string
+		format        PixelFormat
+		width, height int
+		stride        int
+	}{
+		{"NV12 even", FormatNV12, 4, 4, 0},
+		{"NV12 odd", FormatNV12, 3, 3, 0},
+		{"NV12 padded", FormatNV12, 3, 3, 6},
+		{"YUV420 even", FormatYUV420, 4, 4, 0},
+		{"YUV420 odd", FormatYUV420, 5, 3, 0},
+		{"YUV420 padded", FormatYUV420, 3, 3, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := yuvFrame(tt.format, tt.width, tt.height, tt.stride)
+			img, err := f.Image()
+			require.NoError(t, err)
+			ycbcr, ok := img.(*image.YCbCr)
+			require.True(t, ok)
+			assert.Equal(t, image.Rect(0, 0, tt.width, tt.height), ycbcr.Bounds())
+			cw := (tt.width + 1) / 2
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					c := y/2*cw + x/2
+					want := color.YCbCr{Y: byte(y*tt.width + x + 1), Cb: byte(100 + c), Cr: byte(200 + c)}
+					assert.Equal(t, want, ycbcr.YCbCrAt(x, y), "pixel %d,%d", x, y)
+				}
+			}
+
+			gray, err := f.Gray()
+			require.NoError(t, err)
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					assert.Equal(t, byte(y*tt.width+x+1), gray.GrayAt(x, y).Y)
+				}
+			}
+		})
+	}
+}
+
+func TestFrameRGB(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		stride        int
+	}{
+		{"packed", 3, 2, 0},
+		{"padded", 3, 2, 12},
+		{"odd", 5, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := rgbFrame(tt.width, tt.height, tt.stride)
+			rgb, err := f.RGB()
+			require.NoError(t, err)
+			require.Len(t, rgb, tt.width*tt.height*3)
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					o := (y*tt.width + x) * 3
+					assert.Equal(t, []byte{byte(x), byte(y), byte(x + y + 1)}, rgb[o:o+3], "pixel %d,%d", x, y)
+				}
+			}
+
+			img, err := f.Image()
+			require.NoError(t, err)
+			assert.Equal(t, color.RGBA{R: 1, G: 1, B: 3, A: 0xff}, img.At(1, 1))
+
+			// Interleaved and planar buffers convert into each other without loss.
+			planar := ToPlanarRGB(img)
+			back, err := PlanarRGBToImage(planar, tt.width, tt.height)
+			require.NoError(t, err)
+			assert.Equal(t, rgb, ToRGB(back))
+		})
+	}
+}
+
+func TestFrameValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+	}{
+		{"no size", Frame{Format: FormatNV12, Data: make([]byte, 16)}},
+		{"negative size", Frame{Format: FormatRGB, Width: -2, Height: 2, Data: make([]byte, 16)}},
+		{"short data", Frame{Format: FormatNV12, Width: 3, Height: 3, Data: make([]byte, 16)}},
+		{"stride below width", Frame{Format: FormatRGB, Width: 3, Height: 1, Stride: 8, Data: make([]byte, 16)}},
+		{"unknown format", Frame{Format: PixelFormat(42), Width: 1, Height: 1, Data: make([]byte, 16)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.frame.Image()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 4, 2))
+	for i := range gray.Pix {
+		gray.Pix[i] = byte(i * 10)
+	}
+
+	t.Run("identity", func(t *testing.T) {
+		assert.Equal(t, gray.Pix, ResizeGray(gray, 4, 2).Pix)
+	})
+	t.Run("uniform", func(t *testing.T) {
+		rgba := image.NewRGBA(image.Rect(0, 0, 5, 3))
+		for i := range rgba.Pix {
+			rgba.Pix[i] = 77
+		}
+		for _, v := range ResizeRGBA(rgba, 2, 7).Pix {
+			assert.Equal(t, byte(77), v)
+		}
+	})
+	t.Run("negative", func(t *testing.T) {
+		assert.True(t, ResizeGray(gray, -4, 2).Bounds().Empty())
+		assert.True(t, ResizeRGBA(image.NewRGBA(image.Rect(0, 0, 2, 2)), 2, -1).Bounds().Empty())
+	})
+
+	rgbTests := []struct {
+		name                string
+		srcWidth, srcHeight int
+		width, height       int
+		wantErr             bool
+	}{
+		{"downscale", 4, 2, 2, 1, false},
+		{"upscale odd", 3, 3, 5, 7, false},
+		{"negative target", 4, 2, -2, -1, true},
+		{"negative source", -4, -2, 2, 1, true},
+		{"zero target", 4, 2, 0, 1, true},
+	}
+	for _, tt := range rgbTests {
+		t.Run("RGB "+tt.name, func(t *testing.T) {
+			src := make([]byte, max(tt.srcWidth*tt.srcHeight*3, 0))
+			for i := range src {
+				src[i] = 42
+			}
+			out, err := ResizeRGB(src, tt.srcWidth, tt.srcHeight, tt.width, tt.height)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, out, tt.width*tt.height*3)
+			for _, v := range out {
+				assert.Equal(t, byte(42), v)
+			}
+		})
+	}
+}
+
+func TestCrop(t *testing.T) {
+	f := yuvFrame(FormatNV12, 4, 4, 0)
+	img, err := f.Image()
+	require.NoError(t, err)
+
+	cropped, err := Crop(img, CropArea{X: 1, Y: 2, Width: 2, Height: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 2, cropped.Bounds().Dx())
+	assert.Equal(t, 2, cropped.Bounds().Dy())
+	assert.Equal(t, img.At(1, 2), cropped.At(cropped.Bounds().Min.X, cropped.Bounds().Min.Y))
+
+	_, err = Crop(img, CropArea{X: 3, Y: 3, Width: 2, Height: 2})
+	assert.Error(t, err)
+}
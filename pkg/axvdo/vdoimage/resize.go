@@ -0,0 +1,155 @@
+package vdoimage
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// CropArea defines the dimensions and position of the crop area.
+type CropArea struct {
+	Width  int
+	Height int
+	X      int
+	Y      int
+}
+
+// Rect returns the crop area as an image.Rectangle.
+func (c CropArea) Rect() image.Rectangle {
+	return image.Rect(c.X, c.Y, c.X+c.Width, c.Y+c.Height)
+}
+
+// Crop returns the part of img inside the crop area, relative to the bounds of img.
+// The result shares the pixels of img if the image type supports SubImage.
+func Crop(img image.Image, area CropArea) (image.Image, error) {
+	b := img.Bounds()
+	rect := area.Rect().Add(b.Min)
+	if area.Width <= 0 || area.Height <= 0 || !rect.In(b) {
+		return nil, fmt.Errorf("crop area %dx%d+%d+%d is outside of the %dx%d image", area.Width, area.Height, area.X, area.Y, b.Dx(), b.Dy())
+	}
+	if sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect), nil
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, area.Width, area.Height))
+	draw.Draw(dst, dst.Rect, img, rect.Min, draw.Src)
+	return dst, nil
+}
+
+// Resize scales img to width x height with bilinear interpolation.
+// Gray images stay *image.Gray, all other images result in an *image.RGBA.
+func Resize(img image.Image, width, height int) image.Image {
+	if gray, ok := img.(*image.Gray); ok {
+		return ResizeGray(gray, width, height)
+	}
+	return ResizeRGBA(toRGBA(img), width, height)
+}
+
+// ResizeGray scales a gray image to width x height with bilinear interpolation, a negative size results in an empty image.
+func ResizeGray(src *image.Gray, width, height int) *image.Gray {
+	width, height = max(width, 0), max(height, 0)
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	b := src.Rect
+	bilinear(src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], b.Dx(), b.Dy(), src.Stride, 1, dst.Pix, width, height, dst.Stride)
+	return dst
+}
+
+// ResizeRGBA scales an RGBA image to width x height with bilinear interpolation, a negative size results in an empty image.
+func ResizeRGBA(src *image.RGBA, width, height int) *image.RGBA {
+	width, height = max(width, 0), max(height, 0)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	b := src.Rect
+	bilinear(src.Pix[src.PixOffset(b.Min.X, b.Min.Y):], b.Dx(), b.Dy(), src.Stride, 4, dst.Pix, width, height, dst.Stride)
+	return dst
+}
+
+// ResizeRGB scales a buffer of interleaved 8-bit RGB without padding with bilinear interpolation.
+func ResizeRGB(rgb []byte, srcWidth, srcHeight, width, height int) ([]byte, error) {
+	if srcWidth <= 0 || srcHeight <= 0 || width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid resize from %dx%d to %dx%d", srcWidth, srcHeight, width, height)
+	}
+	if len(rgb) < srcWidth*srcHeight*3 {
+		return nil, fmt.Errorf("invalid RGB data length: got %d, expected %d", len(rgb), srcWidth*srcHeight*3)
+	}
+	dst := make([]byte, width*height*3)
+	bilinear(rgb, srcWidth, srcHeight, srcWidth*3, 3, dst, width, height, width*3)
+	return dst, nil
+}
+
+// CropResize crops img and scales the result to width x height, for example to feed a model with a fixed input size.
+// Use axvdo.CalculateCropDimensions to get a crop area that keeps the aspect ratio of the model input.
+func CropResize(img image.Image, area CropArea, width, height int) (image.Image, error) {
+	cropped, err := Crop(img, area)
+	if err != nil {
+		return nil, err
+	}
+	return Resize(cropped, width, height), nil
+}
+
+// toRGBA returns img as an *image.RGBA, converting it if needed.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// bilinear scales src with ch interleaved 8-bit channels into dst using 8-bit fixed point weights.
+// Pixel centers are aligned, so scaling by an integer factor samples between the source pixels.
+func bilinear(src []byte, sw, sh, sstride, ch int, dst []byte, dw, dh, dstride int) {
+	if sw <= 0 || sh <= 0 || dw <= 0 || dh <= 0 {
+		return
+	}
+	xi, xw := bilinearWeights(sw, dw)
+	yi, yw := bilinearWeights(sh, dh)
+
+	for y := 0; y < dh; y++ {
+		y0 := yi[y]
+		y1 := y0
+		if y0+1 < sh {
+			y1 = y0 + 1
+		}
+		row0 := src[y0*sstride:]
+		row1 := src[y1*sstride:]
+		wy := yw[y]
+		out := dst[y*dstride:]
+		for x := 0; x < dw; x++ {
+			x0 := xi[x] * ch
+			x1 := x0
+			if xi[x]+1 < sw {
+				x1 = x0 + ch
+			}
+			wx := xw[x]
+			for c := 0; c < ch; c++ {
+				top := int(row0[x0+c])*(256-wx) + int(row0[x1+c])*wx
+				bottom := int(row1[x0+c])*(256-wx) + int(row1[x1+c])*wx
+				out[x*ch+c] = uint8((top*(256-wy) + bottom*wy + 1<<15) >> 16)
+			}
+		}
+	}
+}
+
+// bilinearWeights returns for each destination position the left source index and the weight of the right neighbor in 1/256.
+func bilinearWeights(srcSize, dstSize int) ([]int, []int) {
+	index := make([]int, dstSize)
+	weight := make([]int, dstSize)
+	scale := float64(srcSize) / float64(dstSize)
+	for i := 0; i < dstSize; i++ {
+		pos := (float64(i)+0.5)*scale - 0.5
+		if pos < 0 {
+			pos = 0
+		}
+		i0 := int(pos)
+		if i0 >= srcSize-1 {
+			index[i] = srcSize - 1
+			continue
+		}
+		index[i] = i0
+		weight[i] = int((pos - float64(i0)) * 256)
+	}
+	return index, weight
+}
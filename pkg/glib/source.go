@@ -0,0 +1,139 @@
+package glib
+
+/*
+#cgo pkg-config: glib-2.0
+#include <glib.h>
+extern gboolean GoSourceCallback(gpointer user_data);
+extern void GoSourceDestroy(gpointer user_data);
+*/
+import "C"
+import (
+	"math"
+	"runtime/cgo"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Source is a handle to a callback attached to the default main context, like a timeout or idle source.
+// The callback is invoked on the thread that runs the GMainLoop, so it does not race with other callbacks
+// dispatched by the main loop, for example from axevent or axparameter.
+type Source struct {
+	source  *C.GSource // Referenced until the source is destroyed, so Remove never uses a stale source id.
+	mu      sync.Mutex
+	removed bool
+}
+
+type sourceData struct {
+	fn     func() bool
+	source *Source
+}
+
+//export GoSourceCallback
+func GoSourceCallback(user_data C.gpointer) C.gboolean {
+	data := cgo.Handle(user_data).Value().(*sourceData)
+	if data.fn() {
+		return C.TRUE
+	}
+	return C.FALSE
+}
+
+//export GoSourceDestroy
+func GoSourceDestroy(user_data C.gpointer) {
+	h := cgo.Handle(user_data)
+	data := h.Value().(*sourceData)
+	data.source.mu.Lock()
+	data.source.removed = true
+	source := data.source.source
+	data.source.source = nil
+	data.source.mu.Unlock()
+	if source != nil {
+		C.g_source_unref(source)
+	}
+	h.Delete()
+}
+
+// newSource attaches the source to the default main context with fn as callback, it takes over the reference of source.
+func newSource(fn func() bool, source *C.GSource, priority C.gint) *Source {
+	s := &Source{source: source}
+	handle := cgo.NewHandle(&sourceData{fn: fn, source: s})
+	C.g_source_set_priority(source, priority)
+	C.g_source_set_callback(
+		source,
+		(C.GSourceFunc)(unsafe.Pointer(C.GoSourceCallback)),
+		(C.gpointer)(unsafe.Pointer(handle)),
+		(C.GDestroyNotify)(unsafe.Pointer(C.GoSourceDestroy)),
+	)
+	// The lock keeps a callback that finishes immediately from releasing the source before it is attached.
+	s.mu.Lock()
+	C.g_source_attach(source, nil)
+	s.mu.Unlock()
+	return s
+}
+
+// timeoutMilliseconds converts the interval to milliseconds, rounding up so a positive interval never becomes 0.
+func timeoutMilliseconds(interval time.Duration) C.guint {
+	if interval <= 0 {
+		return 0
+	}
+	ms := (interval + time.Millisecond - 1) / time.Millisecond
+	if ms > math.MaxUint32 {
+		ms = math.MaxUint32
+	}
+	return C.guint(ms)
+}
+
+// TimeoutAdd calls fn every interval on the main loop until fn returns false or the source is removed.
+// The interval has a resolution of milliseconds, shorter intervals are rounded up.
+//
+// https://docs.gtk.org/glib/func.timeout_add_full.html
+func TimeoutAdd(interval time.Duration, fn func() bool) *Source {
+	return newSource(fn, C.g_timeout_source_new(timeoutMilliseconds(interval)), C.G_PRIORITY_DEFAULT)
+}
+
+// IdleAdd calls fn on the main loop whenever there are no higher priority events pending,
+// until fn returns false or the source is removed.
+//
+// https://docs.gtk.org/glib/func.idle_add_full.html
+func IdleAdd(fn func() bool) *Source {
+	return newSource(fn, C.g_idle_source_new(), C.G_PRIORITY_DEFAULT_IDLE)
+}
+
+// InvokeOnMainLoop calls fn once on the main loop, with the same priority as other main loop events.
+// Use it to hand work from a goroutine to the main loop thread, the call can be cancelled until it runs.
+func InvokeOnMainLoop(fn func()) *Source {
+	return newSource(func() bool {
+		fn()
+		return false
+	}, C.g_idle_source_new(), C.G_PRIORITY_DEFAULT)
+}
+
+// Remove detaches the source from the main loop, its callback is not called anymore.
+// It returns false if the source was already removed or its callback finished.
+//
+// https://docs.gtk.org/glib/method.Source.destroy.html
+func (s *Source) Remove() bool {
+	s.mu.Lock()
+	if s.removed || s.source == nil {
+		s.mu.Unlock()
+		return false
+	}
+	s.removed = true
+	// The own reference keeps the source valid when the main loop destroys it concurrently.
+	source := C.g_source_ref(s.source)
+	s.mu.Unlock()
+	defer C.g_source_unref(source)
+	if C.g_source_is_destroyed(source) != 0 {
+		return false
+	}
+	// The destroy notify runs synchronously, so the lock must not be held.
+	C.g_source_destroy(source)
+	return true
+}
+
+// IsActive reports whether the source is still attached to the main loop.
+func (s *Source) IsActive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.removed
+}